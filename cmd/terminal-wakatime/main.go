@@ -1,22 +1,38 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/template"
 	"time"
+	"unicode/utf8"
 
+	"github.com/hackclub/terminal-wakatime/pkg/accounting"
 	"github.com/hackclub/terminal-wakatime/pkg/config"
+	"github.com/hackclub/terminal-wakatime/pkg/doctor"
 	"github.com/hackclub/terminal-wakatime/pkg/monitor"
 	"github.com/hackclub/terminal-wakatime/pkg/shell"
+	"github.com/hackclub/terminal-wakatime/pkg/ssh"
+	"github.com/hackclub/terminal-wakatime/pkg/summaries"
+	"github.com/hackclub/terminal-wakatime/pkg/tracker"
 	"github.com/hackclub/terminal-wakatime/pkg/updater"
 	"github.com/hackclub/terminal-wakatime/pkg/wakatime"
+	"github.com/hackclub/terminal-wakatime/pkg/watcher"
 	"github.com/spf13/cobra"
 )
 
 var (
 	cfg     *config.Config
+	cfgErr  error
 	verbose bool
 )
 
@@ -31,7 +47,11 @@ func execute() error {
 	var err error
 	cfg, err = config.NewConfig()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		if cfg == nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		// Only doctor runs with a broken config file so it can report it
+		cfgErr = err
 	}
 
 	rootCmd := &cobra.Command{
@@ -43,10 +63,15 @@ and sends it to WakaTime for time tracking and analytics.
 It monitors terminal activity across multiple shells (Bash, Zsh, Fish, etc.)
 and detects when you're working on files, using coding tools, or connecting
 to remote systems.`,
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if cfgErr != nil && cmd.Name() != "doctor" {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("failed to load config: %w", cfgErr)
+			}
 			if verbose {
 				cfg.Debug = true
 			}
+			return nil
 		},
 	}
 
@@ -54,14 +79,24 @@ to remote systems.`,
 
 	// Add subcommands
 	rootCmd.AddCommand(initCmd())
+	rootCmd.AddCommand(installCmd())
+	rootCmd.AddCommand(uninstallCmd())
 	rootCmd.AddCommand(configCmd())
 	rootCmd.AddCommand(heartbeatCmd())
 	rootCmd.AddCommand(trackCmd())
+	rootCmd.AddCommand(watchCmd())
+	rootCmd.AddCommand(sshCmd())
 	rootCmd.AddCommand(statusCmd())
+	rootCmd.AddCommand(promptCmd())
+	rootCmd.AddCommand(reportCmd())
+	rootCmd.AddCommand(todayCmd())
+	rootCmd.AddCommand(weekCmd())
 	rootCmd.AddCommand(testCmd())
 	rootCmd.AddCommand(depsCmd())
 	rootCmd.AddCommand(debugCmd())
+	rootCmd.AddCommand(doctorCmd())
 	rootCmd.AddCommand(updateCmd())
+	rootCmd.AddCommand(changelogCmd())
 	rootCmd.AddCommand(versionCmd())
 
 	return rootCmd.Execute()
@@ -94,6 +129,10 @@ Optionally specify the shell type: terminal-wakatime init fish`,
 				// Auto-detect shell
 				integration = shell.NewIntegrationWithConfig(binPath, minCommandTimeSeconds)
 			}
+			if cfg.WatchEditors {
+				integration.SetWatchEditors(tracker.EditorCommands())
+			}
+			integration.SetSSHForward(cfg.SSHForward)
 
 			hooks := integration.GenerateHooks()
 			fmt.Print(hooks)
@@ -104,6 +143,235 @@ Optionally specify the shell type: terminal-wakatime init fish`,
 	return cmd
 }
 
+func installCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Add shell integration to your shell configuration file",
+		Long: `Add the terminal-wakatime hooks to your shell configuration file.
+
+The integration is written between marker comments so running install again
+repairs it in place (for example after moving the binary) instead of adding a
+second copy. Lines added by older versions of install.sh are migrated.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInstallCommand(cmd, args)
+		},
+	}
+
+	cmd.Flags().String("shell", "", "Shell to configure (bash, zsh, fish); detected if omitted")
+	cmd.Flags().Bool("dry-run", false, "Show the changes without writing them")
+
+	return cmd
+}
+
+func runInstallCommand(cmd *cobra.Command, args []string) error {
+	shellName, _ := cmd.Flags().GetString("shell")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	binPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	var integration *shell.Integration
+	if shellName != "" {
+		if !isSupportedShell(shellName) {
+			return fmt.Errorf("unsupported shell %q (expected bash, zsh or fish)", shellName)
+		}
+		integration = shell.NewIntegrationForShell(binPath, shellName)
+	} else {
+		integration = shell.NewIntegration(binPath)
+	}
+
+	edit, err := integration.PlanInstall()
+	if err != nil {
+		return fmt.Errorf("failed to plan install: %w", err)
+	}
+
+	if !edit.Changed() {
+		fmt.Printf("✓ Shell integration is already installed in %s\n", edit.Path)
+		return nil
+	}
+
+	printRCEdit(edit, dryRun)
+	if dryRun {
+		return nil
+	}
+
+	if err := edit.Apply(); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Shell integration installed for %s\n", integration.GetShellName())
+	fmt.Println("Open a new terminal (or re-source the file) to start tracking.")
+	return nil
+}
+
+func uninstallCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove shell integration and optionally installed files",
+		Long: `Remove the terminal-wakatime hooks from the bash, zsh and fish configuration files.
+
+By default only shell configuration is changed. Use --remove-binary,
+--remove-cli and --remove-state (or --all) to also delete files. The
+wakatime-cli binary may be shared with other WakaTime plugins, and
+~/.wakatime.cfg is never removed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runUninstallCommand(cmd, args)
+		},
+	}
+
+	cmd.Flags().Bool("dry-run", false, "Show what would be removed without changing anything")
+	cmd.Flags().Bool("remove-binary", false, "Also remove the terminal-wakatime binary")
+	cmd.Flags().Bool("remove-cli", false, "Also remove the wakatime-cli binary")
+	cmd.Flags().Bool("remove-state", false, "Also remove terminal-wakatime state files")
+	cmd.Flags().Bool("all", false, "Remove the binary, wakatime-cli and state files too")
+
+	return cmd
+}
+
+func runUninstallCommand(cmd *cobra.Command, args []string) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	removeBinary, _ := cmd.Flags().GetBool("remove-binary")
+	removeCLI, _ := cmd.Flags().GetBool("remove-cli")
+	removeState, _ := cmd.Flags().GetBool("remove-state")
+
+	if all, _ := cmd.Flags().GetBool("all"); all {
+		removeBinary = true
+		removeCLI = true
+		removeState = true
+	}
+
+	binPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	// install.sh configures every shell, so clean up all of them
+	seen := make(map[string]bool)
+	changed := 0
+	for _, shellName := range []string{"bash", "zsh", "fish"} {
+		edits, err := shell.NewIntegrationForShell(binPath, shellName).PlanUninstall()
+		if err != nil {
+			return fmt.Errorf("failed to plan uninstall: %w", err)
+		}
+
+		for _, edit := range edits {
+			if seen[edit.Path] {
+				continue
+			}
+			seen[edit.Path] = true
+			changed++
+
+			printRCEdit(edit, dryRun)
+			if !dryRun {
+				if err := edit.Apply(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if changed == 0 {
+		fmt.Println("No shell integration found")
+	}
+
+	var paths []string
+	if removeState {
+		paths = append(paths, stateFiles()...)
+	}
+	if removeCLI {
+		wakatimeCLI := wakatime.NewCLI(cfg)
+		paths = append(paths, wakatimeCLI.BinaryPath(), wakatimeCLI.PreviousBinaryPath())
+
+		// The shared link would dangle once our binary is gone
+		if target, err := os.Readlink(wakatimeCLI.SharedBinaryPath()); err == nil && target == wakatimeCLI.BinaryPath() {
+			paths = append(paths, wakatimeCLI.SharedBinaryPath())
+		}
+	}
+	if removeBinary {
+		if manager := updater.ManagedBy(binPath); manager == updater.ManagerNix || manager == updater.ManagerHomebrew {
+			fmt.Printf("Skipping %s: it is managed by %s\n", binPath, manager)
+		} else {
+			paths = append(paths, binPath)
+		}
+	}
+
+	for _, path := range paths {
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+
+		if dryRun {
+			fmt.Printf("Would remove %s\n", path)
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		fmt.Printf("Removed %s\n", path)
+	}
+
+	if !dryRun {
+		fmt.Println("✓ terminal-wakatime uninstalled. Open a new terminal to unload the hooks.")
+	}
+
+	return nil
+}
+
+// stateFiles lists the files terminal-wakatime keeps in the WakaTime directory
+func stateFiles() []string {
+	names := []string{
+		monitor.CommandLogFile,
+		updater.LastCheckFile,
+		updater.UpdateInfoFile,
+		updater.TempBinaryFile,
+		updater.PreviousBinaryFile,
+		updater.RollbackFile,
+		updater.AvailableUpdateFile,
+		updater.ChangelogFile,
+		wakatime.LastUpdateCheckFile,
+		tracker.LastHeartbeatFile,
+		tracker.DailySummaryFile,
+		tracker.JournalFile,
+		tracker.ProjectLanguageCacheFile,
+		summaries.CacheFile,
+		wakatime.InstallLockFile,
+		wakatime.InstallFailureFile,
+		wakatime.AdoptedBinaryFile,
+	}
+
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, filepath.Join(cfg.WakaTimeDir(), name))
+	}
+	return append(paths, tracker.JournalFiles(cfg)...)
+}
+
+func printRCEdit(edit *shell.RCEdit, dryRun bool) {
+	if dryRun {
+		fmt.Printf("Would update %s:\n", edit.Path)
+	} else {
+		fmt.Printf("Updating %s:\n", edit.Path)
+	}
+
+	for _, line := range edit.Removed {
+		fmt.Printf("  - %s\n", line)
+	}
+	for _, line := range edit.Added {
+		fmt.Printf("  + %s\n", line)
+	}
+}
+
+func isSupportedShell(name string) bool {
+	switch strings.ToLower(name) {
+	case "bash", "zsh", "fish":
+		return true
+	}
+	return false
+}
+
 func configCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "config",
@@ -120,6 +388,11 @@ func configCmd() *cobra.Command {
 	cmd.Flags().Bool("debug", false, "Enable debug mode")
 	cmd.Flags().Bool("show", false, "Show current configuration")
 	cmd.Flags().Bool("disable-editor-suggestions", false, "Disable editor plugin suggestions")
+	cmd.Flags().String("update-channel", "", "Set the self-update channel (stable, prerelease, off)")
+	cmd.Flags().Bool("update-notify-only", false, "Announce new versions instead of installing them")
+	cmd.Flags().Bool("watch-editors", false, "Watch the project for saved files while an editor runs (takes effect in new shells)")
+	cmd.Flags().Bool("tmux-session-project", false, "Name the project after the tmux session commands run in")
+	cmd.Flags().Bool("ssh-forward", false, "Run ssh through terminal-wakatime to forward remote heartbeats (takes effect in new shells)")
 
 	return cmd
 }
@@ -162,6 +435,34 @@ func runConfigCommand(cmd *cobra.Command, args []string) error {
 		modified = true
 	}
 
+	if channel, _ := cmd.Flags().GetString("update-channel"); channel != "" {
+		if err := config.ValidateUpdateChannel(channel); err != nil {
+			return err
+		}
+		cfg.UpdateChannel = strings.ToLower(channel)
+		modified = true
+	}
+
+	if notifyOnly, _ := cmd.Flags().GetBool("update-notify-only"); notifyOnly {
+		cfg.UpdateNotifyOnly = true
+		modified = true
+	}
+
+	if cmd.Flags().Changed("watch-editors") {
+		cfg.WatchEditors, _ = cmd.Flags().GetBool("watch-editors")
+		modified = true
+	}
+
+	if cmd.Flags().Changed("tmux-session-project") {
+		cfg.TmuxSessionProject, _ = cmd.Flags().GetBool("tmux-session-project")
+		modified = true
+	}
+
+	if cmd.Flags().Changed("ssh-forward") {
+		cfg.SSHForward, _ = cmd.Flags().GetBool("ssh-forward")
+		modified = true
+	}
+
 	if modified {
 		if err := cfg.Save(); err != nil {
 			return fmt.Errorf("failed to save config: %w", err)
@@ -185,6 +486,15 @@ func showConfig() error {
 	fmt.Printf("Project: %s\n", cfg.Project)
 	fmt.Printf("Disable Editor Suggestions: %t\n", cfg.DisableEditorSuggestions)
 
+	updateChannel := cfg.UpdateChannel
+	if cfg.UpdateNotifyOnly {
+		updateChannel += " (notify only)"
+	}
+	fmt.Printf("Update Channel: %s\n", updateChannel)
+	fmt.Printf("Watch Editors: %t\n", cfg.WatchEditors)
+	fmt.Printf("Tmux Session Project: %t\n", cfg.TmuxSessionProject)
+	fmt.Printf("SSH Forward: %t\n", cfg.SSHForward)
+
 	if len(cfg.Exclude) > 0 {
 		fmt.Printf("Exclude: %s\n", strings.Join(cfg.Exclude, ", "))
 	}
@@ -243,7 +553,7 @@ func runHeartbeatCommand(cmd *cobra.Command, args []string) error {
 	branch, _ := cmd.Flags().GetString("branch")
 	isWrite, _ := cmd.Flags().GetBool("write")
 
-	return wakatimeCLI.SendHeartbeat(entity, entityType, category, language, project, branch, isWrite, nil, nil, nil, nil, nil)
+	return wakatimeCLI.SendHeartbeat(entity, entityType, category, language, project, branch, "", time.Time{}, isWrite, false, nil, nil, nil, nil, nil)
 }
 
 func trackCmd() *cobra.Command {
@@ -258,6 +568,7 @@ func trackCmd() *cobra.Command {
 	cmd.Flags().String("command", "", "Command that was executed")
 	cmd.Flags().Int("duration", 0, "Duration in seconds")
 	cmd.Flags().String("pwd", "", "Working directory")
+	cmd.Flags().String("git-before", "", "HEAD and upstream commits captured before a git command (\"-\" if missing)")
 
 	return cmd
 }
@@ -291,47 +602,636 @@ func runTrackCommand(cmd *cobra.Command, args []string) error {
 	}
 
 	mon := monitor.NewMonitor(cfg)
+	if gitBefore, _ := cmd.Flags().GetString("git-before"); gitBefore != "" {
+		mon.SetGitSnapshot(tracker.ParseGitSnapshot(gitBefore))
+	}
 	return mon.ProcessCommand(command, time.Duration(duration)*time.Second, pwd)
 }
 
-func statusCmd() *cobra.Command {
+// A watcher never outlives this, even if its shell's exit goes unnoticed
+const watchMaxLifetime = 12 * time.Hour
+
+func watchCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "status",
-		Short: "Show current status and recent activity",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			mon := monitor.NewMonitor(cfg)
-			status, err := mon.GetStatus()
-			if err != nil {
-				return err
+		Use:   "watch",
+		Short: "Send a heartbeat for each file saved in the project",
+		Long: `Watch the current project and send a write heartbeat for each file saved,
+skipping files ignored by git or excluded in the config.
+
+With watch_editors enabled, the shell hooks start a watcher for each editor
+command and stop it when the editor exits. Run it by hand to watch until
+interrupted.`,
+		RunE: runWatchCommand,
+	}
+
+	cmd.Flags().String("command", "", "Command being run; the watcher only starts for editors")
+	cmd.Flags().String("pwd", "", "Working directory (default: current directory)")
+	cmd.Flags().Int("shell-pid", 0, "Shell the watcher belongs to; it stops when the shell exits")
+	cmd.Flags().Bool("stop", false, "Stop the watcher of --shell-pid")
+
+	return cmd
+}
+
+func runWatchCommand(cmd *cobra.Command, args []string) error {
+	command, _ := cmd.Flags().GetString("command")
+	pwd, _ := cmd.Flags().GetString("pwd")
+	shellPid, _ := cmd.Flags().GetInt("shell-pid")
+	stop, _ := cmd.Flags().GetBool("stop")
+
+	if stop {
+		if shellPid <= 0 {
+			return fmt.Errorf("--stop requires --shell-pid")
+		}
+		// The watcher may still be starting, so give its pid file a moment
+		stopWatcher(shellPid, time.Second)
+		return nil
+	}
+
+	if pwd == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		pwd = wd
+	}
+
+	dir, editor := pwd, ""
+	if command != "" {
+		fields := strings.Fields(command)
+		if len(fields) == 0 || !tracker.IsEditor(filepath.Base(fields[0])) {
+			return nil
+		}
+		dir, editor = editorTargetDir(fields[1:], pwd), filepath.Base(fields[0])
+	}
+
+	root := tracker.ProjectRoot(dir)
+	if root == "" {
+		if command != "" {
+			return nil // no project to scope the watch to
+		}
+		root = dir
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, watchMaxLifetime)
+	defer cancelTimeout()
+
+	if shellPid > 0 {
+		// One watcher per shell: replace whatever the last editor left
+		stopWatcher(shellPid, 0)
+
+		pidFile := watchPidFile(shellPid)
+		if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", pidFile, err)
+		}
+		defer removeOwnPidFile(pidFile)
+
+		go func() {
+			ticker := time.NewTicker(5 * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if !watcher.ProcessAlive(shellPid) {
+						cancel()
+						return
+					}
+				}
 			}
+		}()
+	}
 
-			fmt.Println("Terminal WakaTime Status:")
-			fmt.Println("========================")
+	w, err := watcher.New(root, cfg.IsExcluded)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", root, err)
+	}
+	defer w.Close()
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Watching %s\n", w.Root())
+	}
+
+	mon := monitor.NewMonitor(cfg)
+	return w.Run(ctx, func(path string) {
+		if err := mon.ProcessFileEdit(editor, path, true); err != nil && verbose {
+			fmt.Fprintf(os.Stderr, "Failed to send heartbeat for %s: %v\n", path, err)
+		}
+	})
+}
 
-			for key, value := range status {
-				fmt.Printf("%s: %v\n", formatKey(key), value)
+// editorTargetDir returns the directory of the first file or directory an
+// editor was given, so `vim ~/src/app` watches that project
+func editorTargetDir(args []string, pwd string) string {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "+") {
+			continue
+		}
+
+		path := arg
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(pwd, path)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			return path
+		}
+		return filepath.Dir(path)
+	}
+	return pwd
+}
+
+func watchPidFile(shellPid int) string {
+	return filepath.Join(cfg.WakaTimeDir(), fmt.Sprintf("watch-%d.pid", shellPid))
+}
+
+// stopWatcher terminates the watcher of a shell, waiting up to wait for
+// its pid file to appear
+func stopWatcher(shellPid int, wait time.Duration) {
+	pidFile := watchPidFile(shellPid)
+	deadline := time.Now().Add(wait)
+
+	for {
+		info, err := os.Stat(pidFile)
+		if err == nil {
+			data, readErr := os.ReadFile(pidFile)
+			os.Remove(pidFile)
+
+			// A pid file older than any watcher can live is stale, and its
+			// pid may belong to an unrelated process by now
+			if readErr != nil || time.Since(info.ModTime()) > watchMaxLifetime {
+				return
 			}
 
-			// Show recent commands
-			recentCommands, err := mon.GetRecentCommands(5)
-			if err == nil && len(recentCommands) > 0 {
-				fmt.Println("\nRecent Commands:")
-				fmt.Println("================")
-				for _, cmd := range recentCommands {
-					fmt.Printf("%s: %s (duration: %v)\n",
-						cmd.Timestamp.Format("15:04:05"),
-						truncateString(cmd.Command, 50),
-						cmd.Duration)
+			pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil || pid == os.Getpid() {
+				return
+			}
+			if process, err := os.FindProcess(pid); err == nil {
+				if process.Signal(syscall.SIGTERM) != nil {
+					process.Kill()
 				}
 			}
+			return
+		}
 
-			return nil
+		if !os.IsNotExist(err) || time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// removeOwnPidFile removes a watcher's pid file unless a newer watcher
+// has already replaced it
+func removeOwnPidFile(pidFile string) {
+	data, err := os.ReadFile(pidFile)
+	if err == nil && strings.TrimSpace(string(data)) == strconv.Itoa(os.Getpid()) {
+		os.Remove(pidFile)
+	}
+}
+
+func sshCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "ssh [ssh arguments]",
+		Short: "Run ssh, forwarding the remote machine's heartbeats through this one",
+		Long: `Run ssh with a socket forwarded to the remote machine. A terminal-wakatime
+there hands its heartbeats over the socket, and they are sent from here with
+the remote project, branch and hostname, so the remote machine needs no API
+key. Without the socket, or if this end doesn't answer, the remote sends
+them itself.
+
+With ssh_forward enabled, the shell hooks run ssh through this command.`,
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSSHCommand(args)
 		},
 	}
+}
+
+func runSSHCommand(args []string) error {
+	sshArgs := args
+
+	var stopForwarding func()
+	if parsed, err := ssh.ParseCommand(args); err == nil && !parsed.NoSession {
+		stopForwarding, sshArgs = forwardHeartbeats(parsed, args)
+	}
+
+	command := exec.Command("ssh", sshArgs...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	// Ctrl-C is for ssh; only it should decide whether to exit
+	signal.Notify(make(chan os.Signal, 1), os.Interrupt)
+
+	err := command.Run()
+	if stopForwarding != nil {
+		stopForwarding()
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		os.Exit(exitErr.ExitCode())
+	}
+	return err
+}
+
+// The remote counts a heartbeat as sent once it is acknowledged, so after the
+// session the ones still queued are sent before exiting, for up to this long
+const forwardDrainTimeout = 30 * time.Second
+
+// forwardHeartbeats serves forwarded heartbeats on a local socket and
+// returns the function that stops serving, with the ssh arguments that
+// forward the socket to the destination. If the socket can't be created,
+// ssh runs without forwarding.
+func forwardHeartbeats(parsed *ssh.Command, args []string) (func(), []string) {
+	home, _ := os.UserHomeDir()
+	dest := parsed.Destination(home)
+
+	if err := os.MkdirAll(cfg.WakaTimeDir(), 0755); err != nil {
+		return nil, args
+	}
+
+	id := strconv.FormatInt(time.Now().UnixNano(), 36)
+	localSocket := filepath.Join(cfg.WakaTimeDir(), "ssh-forward-"+id+".sock")
+
+	listener, err := net.Listen("unix", localSocket)
+	if err != nil {
+		return nil, args
+	}
+	served := make(chan struct{})
+	go func() {
+		tracker.NewTracker(cfg).ServeForwarded(listener)
+		close(served)
+	}()
+
+	stop := func() {
+		listener.Close()
+		select {
+		case <-served:
+		case <-time.After(forwardDrainTimeout):
+		}
+	}
+
+	remoteSocket := tracker.ForwardSocketPath(dest.User, id)
+	return stop, append([]string{"-R", remoteSocket + ":" + localSocket}, args...)
+}
+
+func statusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show current status and recent activity",
+		Long: `Show current status and recent activity.
+
+Use --json for a stable machine-readable schema, or --format with a Go
+template over the same fields, e.g.
+
+  terminal-wakatime status --format '{{.TodayTime}} on {{.Project}}'`,
+		RunE: runStatusCommand,
+	}
+
+	cmd.Flags().Bool("json", false, "Print status as JSON")
+	cmd.Flags().String("format", "", "Format status using a Go template")
 
 	return cmd
 }
 
+func runStatusCommand(cmd *cobra.Command, args []string) error {
+	asJSON, _ := cmd.Flags().GetBool("json")
+	format, _ := cmd.Flags().GetString("format")
+
+	if asJSON && format != "" {
+		return fmt.Errorf("--json and --format cannot be used together")
+	}
+
+	var tmpl *template.Template
+	if format != "" {
+		var err error
+		tmpl, err = template.New("status").Parse(format)
+		if err != nil {
+			return fmt.Errorf("invalid --format template: %w", err)
+		}
+	}
+
+	mon := monitor.NewMonitor(cfg)
+	status, err := mon.GetStatus()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case asJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	case tmpl != nil:
+		if err := tmpl.Execute(os.Stdout, status); err != nil {
+			return fmt.Errorf("failed to render --format template: %w", err)
+		}
+		if !strings.HasSuffix(format, "\n") {
+			fmt.Println()
+		}
+		return nil
+	}
+
+	fmt.Println("Terminal WakaTime Status:")
+	fmt.Println("========================")
+
+	for _, field := range statusFields(status) {
+		fmt.Printf("%s: %s\n", field[0], field[1])
+	}
+
+	// Show recent commands
+	recentCommands, err := mon.GetRecentCommands(5)
+	if err == nil && len(recentCommands) > 0 {
+		fmt.Println("\nRecent Commands:")
+		fmt.Println("================")
+		for _, cmd := range recentCommands {
+			fmt.Printf("%s: %s (duration: %v)\n",
+				cmd.Timestamp.Format("15:04:05"),
+				truncateString(cmd.Command, 50),
+				cmd.Duration)
+		}
+	}
+
+	return nil
+}
+
+// statusFields returns the human-readable status lines in a fixed order
+func statusFields(status *monitor.Status) [][2]string {
+	orUnknown := func(value string) string {
+		if value == "" {
+			return "unknown"
+		}
+		return value
+	}
+
+	lastHeartbeat := "never"
+	if status.LastHeartbeat != nil {
+		lastHeartbeat = fmt.Sprintf("%s (%s ago)",
+			status.LastHeartbeat.Local().Format("2006-01-02 15:04:05"),
+			time.Since(*status.LastHeartbeat).Round(time.Second))
+	}
+
+	cliVersion := "not installed"
+	if status.WakaTimeCLIInstalled {
+		cliVersion = orUnknown(status.WakaTimeCLIVersion)
+	}
+
+	return [][2]string{
+		{"Today", orUnknown(status.TodayTime)},
+		{"Last Heartbeat", lastHeartbeat},
+		{"Project", orUnknown(status.Project)},
+		{"Branch", orUnknown(status.Branch)},
+		{"Queued Heartbeats", fmt.Sprintf("%d", status.QueueSize)},
+		{"WakaTime CLI", cliVersion},
+		{"Plugin Version", status.PluginVersion},
+		{"API Key Configured", fmt.Sprintf("%t", status.APIKeyConfigured)},
+		{"Debug Enabled", fmt.Sprintf("%t", status.DebugEnabled)},
+		{"Heartbeat Frequency", status.HeartbeatFrequency},
+		{"Recent Commands", fmt.Sprintf("%d", status.RecentCommands)},
+	}
+}
+
+// DefaultPromptFormat renders e.g. "⏱ 3h12m api"
+const DefaultPromptFormat = "⏱ {{.Time}}{{if .Project}} {{.Project}}{{end}}"
+
+// PromptSegment is the data available to prompt --format templates
+type PromptSegment struct {
+	Time        string
+	Seconds     int
+	Project     string
+	ProjectTime string
+}
+
+func promptCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prompt",
+		Short: "Print today's coding time for shell prompts and status lines",
+		Long: `Print today's coding time, e.g. "⏱ 3h12m api", for use in PS1, starship
+custom modules or tmux status lines.
+
+The time comes from a local summary updated whenever a heartbeat is sent, so
+this never touches the network or runs wakatime-cli. It is an approximation;
+use "terminal-wakatime status" for the time reported by WakaTime. Nothing is
+printed until something has been tracked today.
+
+Template fields: .Time, .Seconds, .Project, .ProjectTime`,
+		Args: cobra.NoArgs,
+		RunE: runPromptCommand,
+	}
+
+	cmd.Flags().String("format", DefaultPromptFormat, "Format the segment using a Go template")
+
+	return cmd
+}
+
+func runPromptCommand(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+
+	tmpl, err := template.New("prompt").Parse(format)
+	if err != nil {
+		return fmt.Errorf("invalid --format template: %w", err)
+	}
+
+	summary, err := tracker.LoadDailySummary(cfg)
+	if err != nil {
+		// Never break the user's prompt over a bad state file
+		return nil
+	}
+
+	if summary.Total() < time.Minute {
+		return nil
+	}
+
+	segment := PromptSegment{
+		Time:        formatPromptDuration(summary.Total()),
+		Seconds:     int(summary.Total().Seconds()),
+		Project:     summary.LastProject,
+		ProjectTime: formatPromptDuration(summary.ProjectTotal(summary.LastProject)),
+	}
+
+	if err := tmpl.Execute(os.Stdout, segment); err != nil {
+		return fmt.Errorf("failed to render --format template: %w", err)
+	}
+	fmt.Println()
+	return nil
+}
+
+// formatPromptDuration formats d compactly, e.g. "45m" or "3h12m"
+func formatPromptDuration(d time.Duration) string {
+	minutes := int(d.Minutes())
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+}
+
+func reportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Summarize locally recorded activity",
+		Long: `Summarize the activity terminal-wakatime has recorded on this machine,
+without contacting WakaTime.
+
+Heartbeats from the local journal are turned into durations the same way
+WakaTime does: the gap between two heartbeats counts as activity unless it
+is longer than 15 minutes.
+
+--since and --until accept YYYY-MM-DD, RFC 3339 timestamps, "today",
+"yesterday", or ages such as 7d or 36h.
+
+Examples:
+  terminal-wakatime report
+  terminal-wakatime report --since 7d --by day,project
+  terminal-wakatime report --since 2024-05-01 --until 2024-06-01 --by project,category --output markdown`,
+		Args: cobra.NoArgs,
+		RunE: runReportCommand,
+	}
+
+	cmd.Flags().String("since", "today", "Start of the period (inclusive)")
+	cmd.Flags().String("until", "now", "End of the period (exclusive)")
+	cmd.Flags().String("by", "project", "Comma separated dimensions: "+strings.Join(reportDimensionNames(), ", "))
+	cmd.Flags().StringP("output", "o", accounting.FormatTable, "Output format: "+strings.Join(accounting.Formats, ", "))
+
+	return cmd
+}
+
+func runReportCommand(cmd *cobra.Command, args []string) error {
+	sinceValue, _ := cmd.Flags().GetString("since")
+	untilValue, _ := cmd.Flags().GetString("until")
+	by, _ := cmd.Flags().GetString("by")
+	output, _ := cmd.Flags().GetString("output")
+
+	now := time.Now()
+	since, err := accounting.ParseTime(sinceValue, now)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	until, err := accounting.ParseTime(untilValue, now)
+	if err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
+	}
+	if !since.Before(until) {
+		return fmt.Errorf("--since must be before --until")
+	}
+
+	dims, err := accounting.ParseDimensions(by)
+	if err != nil {
+		return fmt.Errorf("invalid --by: %w", err)
+	}
+
+	report, err := accounting.Load(cfg, since, until, dims)
+	if err != nil {
+		return fmt.Errorf("failed to read activity journal: %w", err)
+	}
+
+	return report.Write(os.Stdout, output)
+}
+
+func reportDimensionNames() []string {
+	names := make([]string, len(accounting.Dimensions))
+	for i, dim := range accounting.Dimensions {
+		names[i] = string(dim)
+	}
+	return names
+}
+
+func todayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "today",
+		Short: "Show today's time from WakaTime by project and language",
+		Long: `Show today's coding time as recorded by WakaTime, charted by project and
+language. Results are cached for a few minutes so repeated calls are instant;
+use --refresh to bypass the cache.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSummaryCommand(cmd, "Today", false)
+		},
+	}
+
+	addSummaryFlags(cmd)
+	return cmd
+}
+
+func weekCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "week",
+		Short: "Show the last 7 days from WakaTime by day, project and language",
+		Long: `Show the last 7 days of coding time as recorded by WakaTime, charted by
+day, project and language. Results are cached for a few minutes so repeated
+calls are instant; use --refresh to bypass the cache.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSummaryCommand(cmd, "Last 7 Days", true)
+		},
+	}
+
+	addSummaryFlags(cmd)
+	return cmd
+}
+
+func addSummaryFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("refresh", false, "Fetch fresh data instead of using the cache")
+	cmd.Flags().Bool("json", false, "Print the summary as JSON")
+	cmd.Flags().Int("limit", 10, "Maximum number of projects and languages to chart")
+}
+
+func runSummaryCommand(cmd *cobra.Command, title string, week bool) error {
+	refresh, _ := cmd.Flags().GetBool("refresh")
+	asJSON, _ := cmd.Flags().GetBool("json")
+	limit, _ := cmd.Flags().GetInt("limit")
+
+	client := summaries.NewClient(cfg)
+
+	var summary *summaries.Summary
+	var err error
+	if week {
+		summary, err = client.Week(refresh)
+	} else {
+		summary, err = client.Today(refresh)
+	}
+
+	if summary == nil {
+		cmd.SilenceUsage = true
+		return err
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; showing data from %s\n",
+			err, summary.FetchedAt.Local().Format("2006-01-02 15:04"))
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summary)
+	}
+
+	fmt.Printf("%s: %s", title, summaries.FormatDuration(summary.Total()))
+	if summary.Cached {
+		fmt.Printf("  (cached %s ago)", time.Since(summary.FetchedAt).Round(time.Second))
+	}
+	fmt.Println()
+
+	if week && len(summary.Days) > 0 {
+		fmt.Println()
+		summaries.RenderBars(os.Stdout, "Days", summaries.DayBars(summary.Days), summaries.DefaultBarWidth, 0)
+	}
+
+	fmt.Println()
+	summaries.RenderBars(os.Stdout, "Projects", summaries.ItemBars(summary.Projects), summaries.DefaultBarWidth, limit)
+	fmt.Println()
+	summaries.RenderBars(os.Stdout, "Languages", summaries.ItemBars(summary.Languages), summaries.DefaultBarWidth, limit)
+
+	return nil
+}
+
 func testCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "test",
@@ -367,6 +1267,21 @@ func depsCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "deps",
 		Short: "Manage dependencies (wakatime-cli)",
+		Long: `Manage the wakatime-cli binary used to send heartbeats.
+
+By default it is downloaded from GitHub and verified against the release
+checksums. For mirrors set wakatime_cli_releases_url in ~/.wakatime.cfg (or
+TERMINAL_WAKATIME_CLI_RELEASES_URL); proxy and ssl_certs_file are honoured,
+as are the standard HTTPS_PROXY and NO_PROXY variables.
+
+Air-gapped machines can install from a downloaded archive with --from-file,
+or adopt an existing wakatime-cli with --use-system (searches PATH) or
+--use-system=/path/to/wakatime-cli.
+
+--version installs a specific release and pins it (wakatime_cli_version in
+~/.wakatime.cfg) so automatic updates hold it there; --version latest removes
+the pin. The binary replaced by an install is kept, and --rollback switches
+back to it.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runDepsCommand(cmd, args)
 		},
@@ -374,28 +1289,184 @@ func depsCmd() *cobra.Command {
 
 	cmd.Flags().Bool("status", false, "Check dependency status")
 	cmd.Flags().Bool("reinstall", false, "Force reinstall dependencies")
+	cmd.Flags().String("from-file", "", "Install wakatime-cli from a release archive (.zip or .tar.gz)")
+	cmd.Flags().String("sha256", "", "Expected SHA-256 of the --from-file archive")
+	cmd.Flags().String("use-system", "", "Use an existing wakatime-cli instead of downloading one")
+	cmd.Flags().Lookup("use-system").NoOptDefVal = useSystemSearchPath
+	cmd.Flags().String("version", "", "Install and pin a wakatime-cli release (e.g. v1.98.0, or latest)")
+	cmd.Flags().Bool("list", false, "List available wakatime-cli releases")
+	cmd.Flags().Bool("rollback", false, "Switch back to the wakatime-cli replaced by the last install")
 
 	return cmd
 }
 
+// pinCLIVersion saves wakatime_cli_version; an empty version removes the pin
+func pinCLIVersion(version string) error {
+	cfg.WakaTimeCLIVersion = version
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("failed to save wakatime_cli_version: %w", err)
+	}
+	return nil
+}
+
+func runDepsList(wakatimeCLI *wakatime.CLI) error {
+	releases, err := wakatimeCLI.ListReleases(20)
+	if err != nil {
+		return fmt.Errorf("failed to list releases: %w", err)
+	}
+
+	installed, _ := wakatimeCLI.Version()
+	pinned := wakatimeCLI.PinnedVersion()
+
+	for _, release := range releases {
+		marker := " "
+		if release.TagName == installed {
+			marker = "*"
+		}
+
+		var notes []string
+		if release.TagName == installed {
+			notes = append(notes, "installed")
+		}
+		if release.TagName == pinned {
+			notes = append(notes, "pinned")
+		}
+		if release.PreRelease {
+			notes = append(notes, "prerelease")
+		}
+
+		line := fmt.Sprintf("%s %-10s", marker, release.TagName)
+		if !release.PublishedAt.IsZero() {
+			line += "  " + release.PublishedAt.Format("2006-01-02")
+		}
+		if len(notes) > 0 {
+			line += "  (" + strings.Join(notes, ", ") + ")"
+		}
+		fmt.Println(line)
+	}
+
+	return nil
+}
+
+// useSystemSearchPath is the --use-system value meaning "look it up on PATH"
+const useSystemSearchPath = "PATH"
+
 func runDepsCommand(cmd *cobra.Command, args []string) error {
 	wakatimeCLI := wakatime.NewCLI(cfg)
 
 	status, _ := cmd.Flags().GetBool("status")
 	reinstall, _ := cmd.Flags().GetBool("reinstall")
+	fromFile, _ := cmd.Flags().GetString("from-file")
+	sha256, _ := cmd.Flags().GetString("sha256")
+	useSystem, _ := cmd.Flags().GetString("use-system")
+	version, _ := cmd.Flags().GetString("version")
+	list, _ := cmd.Flags().GetBool("list")
+	rollback, _ := cmd.Flags().GetBool("rollback")
+
+	if fromFile != "" && useSystem != "" {
+		return fmt.Errorf("--from-file and --use-system cannot be used together")
+	}
+	if sha256 != "" && fromFile == "" {
+		return fmt.Errorf("--sha256 requires --from-file")
+	}
 
 	if status {
 		if wakatimeCLI.IsInstalled() {
 			fmt.Printf("✓ WakaTime CLI is installed at: %s\n", wakatimeCLI.BinaryPath())
+			if wakatimeCLI.IsSystemBinary() {
+				if target, err := filepath.EvalSymlinks(wakatimeCLI.BinaryPath()); err == nil {
+					fmt.Printf("  Using system wakatime-cli: %s (not updated automatically)\n", target)
+				}
+			}
 		} else {
 			fmt.Println("✗ WakaTime CLI is not installed")
 		}
+		if pinned := wakatimeCLI.PinnedVersion(); pinned != "" {
+			fmt.Printf("  Pinned to %s\n", pinned)
+		}
+		return nil
+	}
+
+	if list {
+		return runDepsList(wakatimeCLI)
+	}
+
+	if rollback {
+		installed, err := wakatimeCLI.Rollback()
+		if err != nil {
+			return fmt.Errorf("failed to roll back: %w", err)
+		}
+
+		// Hold the rolled back version, or the next update check would undo it
+		if err := pinCLIVersion(installed); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Rolled back to wakatime-cli %s (pinned; use `deps --version latest` to resume updates)\n", installed)
+		return nil
+	}
+
+	if version != "" {
+		tag := wakatime.NormalizeVersion(version)
+		if tag == "" {
+			fmt.Println("Installing the latest WakaTime CLI...")
+		} else {
+			fmt.Printf("Installing WakaTime CLI %s...\n", tag)
+		}
+
+		if err := wakatimeCLI.InstallVersion(tag); err != nil {
+			return fmt.Errorf("failed to install wakatime-cli: %w", err)
+		}
+		if err := pinCLIVersion(tag); err != nil {
+			return err
+		}
+
+		if tag == "" {
+			fmt.Println("✓ Installed; wakatime-cli will follow the latest release")
+		} else {
+			fmt.Printf("✓ Installed and pinned wakatime-cli %s\n", tag)
+		}
+		return nil
+	}
+
+	if fromFile != "" {
+		fmt.Printf("Installing WakaTime CLI from %s...\n", fromFile)
+		verified, err := wakatimeCLI.InstallFromArchive(fromFile, sha256)
+		if err != nil {
+			return fmt.Errorf("failed to install from archive: %w", err)
+		}
+		if !verified {
+			fmt.Fprintf(os.Stderr, "Warning: %s was not verified; pass --sha256 or put %s next to it\n", fromFile, wakatime.ChecksumsAsset)
+		}
+		fmt.Println("✓ Dependencies installed successfully")
+		return nil
+	}
+
+	if useSystem != "" {
+		path := useSystem
+		if path == useSystemSearchPath {
+			path = ""
+		}
+
+		target, err := wakatimeCLI.UseSystem(path)
+		if err != nil {
+			return fmt.Errorf("failed to use system wakatime-cli: %w", err)
+		}
+		fmt.Printf("✓ Using %s (updates are left to its installer)\n", target)
 		return nil
 	}
 
+	// An explicit install shouldn't wait out the backoff from failed
+	// background attempts
+	wakatimeCLI.ResetInstallBackoff()
+
 	if reinstall {
-		// Remove existing binary
-		os.Remove(wakatimeCLI.BinaryPath())
+		fmt.Println("Reinstalling WakaTime CLI...")
+		if err := wakatimeCLI.Reinstall(); err != nil {
+			return fmt.Errorf("failed to reinstall dependencies: %w", err)
+		}
+
+		fmt.Println("✓ Dependencies installed successfully")
+		return nil
 	}
 
 	fmt.Println("Installing/updating WakaTime CLI...")
@@ -487,15 +1558,59 @@ func runDebugCommand(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func formatKey(key string) string {
-	// Convert snake_case to Title Case
-	parts := strings.Split(key, "_")
-	for i, part := range parts {
-		if part != "" {
-			parts[i] = strings.Title(part)
+func doctorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that tracking works end to end",
+		Long: `Run health checks on the shell hooks, configuration, wakatime-cli and the
+WakaTime API, and suggest a fix for anything that fails.
+
+The round-trip check sends a single heartbeat for "terminal-wakatime doctor"
+to the configured api_url. Use --offline to skip the network checks.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDoctorCommand(cmd, args)
+		},
+	}
+
+	cmd.Flags().Bool("offline", false, "Skip checks that contact the WakaTime API")
+
+	return cmd
+}
+
+func runDoctorCommand(cmd *cobra.Command, args []string) error {
+	offline, _ := cmd.Flags().GetBool("offline")
+
+	doc := doctor.NewDoctor(cfg, cfgErr, getExecutablePath())
+	doc.Offline = offline
+
+	fmt.Println("Terminal WakaTime Doctor:")
+	fmt.Println("=========================")
+
+	failures := 0
+	for _, result := range doc.Run() {
+		symbol := "✓"
+		switch result.Status {
+		case doctor.StatusWarn:
+			symbol = "!"
+		case doctor.StatusFail:
+			symbol = "✗"
+			failures++
+		case doctor.StatusSkip:
+			symbol = "-"
+		}
+
+		fmt.Printf("%s %s: %s\n", symbol, result.Name, result.Detail)
+		if result.Fix != "" && result.Status != doctor.StatusOK {
+			fmt.Printf("    Fix: %s\n", result.Fix)
 		}
 	}
-	return strings.Join(parts, " ")
+
+	if failures > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d check(s) failed", failures)
+	}
+
+	return nil
 }
 
 func truncateString(s string, maxLen int) string {
@@ -516,9 +1631,15 @@ func updateCmd() *cobra.Command {
 
 This command will check GitHub for newer versions and update the binary if available.
 Normally updates happen automatically in the background, but this command allows
-manual updates and testing.`,
+manual updates and testing.
+
+Updates are verified against the release's checksums_sha256.txt, and a new
+binary that fails to run "version" is replaced by the previous one right away.
+The previous binary is kept; --rollback switches back to it and skips the
+version rolled back from until a newer release is published.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			force, _ := cmd.Flags().GetBool("force")
+			rollback, _ := cmd.Flags().GetBool("rollback")
 
 			binaryPath, err := os.Executable()
 			if err != nil {
@@ -526,6 +1647,28 @@ manual updates and testing.`,
 			}
 
 			upd := updater.NewUpdater(cfg.PluginVersion(), cfg.WakaTimeDir(), binaryPath)
+			upd.SetReleasesURL(cfg.UpdateReleasesURL)
+			client, err := cfg.HTTPClient(0)
+			if err != nil {
+				return err
+			}
+			upd.SetHTTPClient(client)
+			upd.SetIncludePrereleases(cfg.UpdateChannel == config.UpdateChannelPrerelease)
+
+			if rollback {
+				version, err := upd.Rollback()
+				if err != nil {
+					return fmt.Errorf("failed to roll back: %w", err)
+				}
+				fmt.Printf("✓ Rolled back to %s (%s will be skipped by automatic updates)\n", version, cfg.PluginVersion())
+				return nil
+			}
+
+			// A package manager owns this binary and would undo our update
+			if manager := updater.ManagedBy(binaryPath); manager != "" {
+				fmt.Printf("terminal-wakatime was installed with %s. Update it with:\n  %s\n", manager, updater.UpgradeCommand(manager))
+				return nil
+			}
 
 			// Check if we should update (unless forced)
 			if !force && !upd.ShouldCheckForUpdate() {
@@ -547,14 +1690,8 @@ manual updates and testing.`,
 
 			fmt.Printf("Found new version: %s (current: %s)\n", release.TagName, cfg.PluginVersion())
 
-			// Get download URL
-			downloadURL, err := upd.GetAssetURL(release)
-			if err != nil {
-				return fmt.Errorf("failed to get download URL: %w", err)
-			}
-
-			fmt.Println("Downloading update...")
-			if err := upd.DownloadUpdate(downloadURL); err != nil {
+			fmt.Println("Downloading and verifying update...")
+			if err := upd.DownloadRelease(release); err != nil {
 				return fmt.Errorf("failed to download update: %w", err)
 			}
 
@@ -563,14 +1700,64 @@ manual updates and testing.`,
 				return fmt.Errorf("failed to install update: %w", err)
 			}
 
+			upd.SaveChangelog(release)
+
 			fmt.Printf("✓ Successfully updated to %s!\n", release.TagName)
 			fmt.Println("The update will take effect on your next terminal session.")
+			fmt.Println("See what changed with: terminal-wakatime changelog")
 
 			return nil
 		},
 	}
 
 	cmd.Flags().Bool("force", false, "Force update check even if checked recently")
+	cmd.Flags().Bool("rollback", false, "Switch back to the binary replaced by the last update")
+
+	return cmd
+}
+
+func changelogCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "changelog",
+		Short: "Show the release notes of the last update",
+		Long: `Show the full release notes of every version the last update went through,
+newest first, so you can tell when tracking behavior changed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			upd := updater.NewUpdater(cfg.PluginVersion(), cfg.WakaTimeDir(), "")
+
+			changelog, err := upd.GetChangelog()
+			if err != nil {
+				return err
+			}
+
+			if changelog == nil || len(changelog.Releases) == 0 {
+				fmt.Println("No release notes recorded yet; they're saved when terminal-wakatime updates itself.")
+				fmt.Println("All releases: https://github.com/hackclub/terminal-wakatime/releases")
+				return nil
+			}
+
+			fmt.Printf("terminal-wakatime %s → %s\n", changelog.FromVersion, changelog.ToVersion)
+
+			for _, release := range changelog.Releases {
+				title := release.Version
+				if release.Name != "" && release.Name != release.Version {
+					title += " — " + release.Name
+				}
+				if !release.PublishedAt.IsZero() {
+					title += fmt.Sprintf(" (%s)", release.PublishedAt.Local().Format("2006-01-02"))
+				}
+
+				fmt.Printf("\n%s\n%s\n\n", title, strings.Repeat("=", utf8.RuneCountInString(title)))
+				if release.Body == "" {
+					fmt.Println("No release notes.")
+				} else {
+					fmt.Println(release.Body)
+				}
+			}
+
+			return nil
+		},
+	}
 
 	return cmd
 }
@@ -6,6 +6,9 @@ import (
 	"os/exec"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/monitor"
 )
 
 func TestMainFunction(t *testing.T) {
@@ -62,29 +65,6 @@ func TestMaskAPIKey(t *testing.T) {
 	}
 }
 
-func TestFormatKey(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"api_key", "Api Key"},
-		{"debug_enabled", "Debug Enabled"},
-		{"heartbeat_frequency", "Heartbeat Frequency"},
-		{"recent_commands", "Recent Commands"},
-		{"single", "Single"},
-		{"", ""},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			result := formatKey(tt.input)
-			if result != tt.expected {
-				t.Errorf("formatKey(%q) = %q, want %q", tt.input, result, tt.expected)
-			}
-		})
-	}
-}
-
 func TestTruncateString(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -275,3 +255,82 @@ func TestConfigWorkflow(t *testing.T) {
 		t.Errorf("Expected config to show project, got: %s", output)
 	}
 }
+
+func TestStatusFields(t *testing.T) {
+	status := &monitor.Status{
+		TodayTime:            "3 hrs 12 mins",
+		Project:              "api",
+		QueueSize:            2,
+		WakaTimeCLIInstalled: false,
+		WakaTimeCLIVersion:   "v1.73.0",
+		PluginVersion:        "v1.2.3",
+	}
+
+	fields := statusFields(status)
+	values := make(map[string]string)
+	for i, field := range fields {
+		values[field[0]] = field[1]
+		if i == 0 && field[0] != "Today" {
+			t.Errorf("Expected Today to come first, got %s", field[0])
+		}
+	}
+
+	expected := map[string]string{
+		"Today":             "3 hrs 12 mins",
+		"Last Heartbeat":    "never",
+		"Project":           "api",
+		"Branch":            "unknown",
+		"Queued Heartbeats": "2",
+		"WakaTime CLI":      "not installed",
+		"Plugin Version":    "v1.2.3",
+	}
+
+	for key, want := range expected {
+		if values[key] != want {
+			t.Errorf("%s = %q, want %q", key, values[key], want)
+		}
+	}
+}
+
+func TestFormatPromptDuration(t *testing.T) {
+	tests := []struct {
+		input    time.Duration
+		expected string
+	}{
+		{30 * time.Second, "0m"},
+		{45 * time.Minute, "45m"},
+		{time.Hour, "1h00m"},
+		{3*time.Hour + 12*time.Minute + 40*time.Second, "3h12m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := formatPromptDuration(tt.input); got != tt.expected {
+				t.Errorf("formatPromptDuration(%v) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEditorTargetDir(t *testing.T) {
+	pwd := t.TempDir()
+	os.MkdirAll(pwd+"/src", 0755)
+	os.WriteFile(pwd+"/src/main.go", []byte("package main\n"), 0644)
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{nil, pwd},
+		{[]string{"."}, pwd},
+		{[]string{"-O", "+12", "src/main.go"}, pwd + "/src"},
+		{[]string{"src"}, pwd + "/src"},
+		{[]string{"missing.go", "src"}, pwd + "/src"},
+	}
+
+	for _, tt := range tests {
+		if got := editorTargetDir(tt.args, pwd); got != tt.expected {
+			t.Errorf("editorTargetDir(%v) = %q, expected %q", tt.args, got, tt.expected)
+		}
+	}
+}
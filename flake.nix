@@ -24,7 +24,7 @@
           version = "1.1.6";
           subPackages = [ "cmd/terminal-wakatime" ];
           src = self;
-          vendorHash = "sha256-fchZVBY43ccu6nbWn572Qzfgeq4uIwpLf99lOuJCO44=";
+          vendorHash = "sha256-jL3ei3WnapdUIzbamvpyfdvQ4rVhmMil+bHvgmT1rks=";
         };
       });
     };
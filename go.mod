@@ -3,6 +3,7 @@ module github.com/hackclub/terminal-wakatime
 go 1.24
 
 require (
+	github.com/fsnotify/fsnotify v1.10.1
 	github.com/spf13/cobra v1.8.0
 	gopkg.in/ini.v1 v1.67.0
 )
@@ -13,4 +14,5 @@ require (
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/stretchr/testify v1.8.4 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
@@ -0,0 +1,277 @@
+package accounting
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+	"github.com/hackclub/terminal-wakatime/pkg/tracker"
+)
+
+// DefaultTimeout is the longest gap between two heartbeats that still counts
+// as continuous activity, matching WakaTime's default keystroke timeout
+const DefaultTimeout = tracker.SummaryTimeout
+
+// Dimension is a heartbeat attribute a report can be grouped by
+type Dimension string
+
+const (
+	ByDay      Dimension = "day"
+	ByProject  Dimension = "project"
+	ByCategory Dimension = "category"
+	ByLanguage Dimension = "language"
+	ByBranch   Dimension = "branch"
+	ByCommand  Dimension = "command"
+	ByEntity   Dimension = "entity"
+)
+
+// Dimensions lists every supported dimension
+var Dimensions = []Dimension{ByDay, ByProject, ByCategory, ByLanguage, ByBranch, ByCommand, ByEntity}
+
+// Unknown is shown for heartbeats without a value for a dimension
+const Unknown = "(none)"
+
+// Span is a heartbeat with the time credited to it
+type Span struct {
+	tracker.HeartbeatRecord
+	Duration time.Duration
+}
+
+// Row is the total time for one combination of dimension values
+type Row struct {
+	Keys     []string
+	Duration time.Duration
+}
+
+// Report is the aggregated time for a period
+type Report struct {
+	Since      time.Time
+	Until      time.Time
+	Dimensions []Dimension
+	Rows       []Row
+	Total      time.Duration
+}
+
+// ParseDimensions parses a comma separated list such as "project,category"
+func ParseDimensions(value string) ([]Dimension, error) {
+	var dims []Dimension
+	seen := make(map[Dimension]bool)
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+
+		dim := Dimension(part)
+		valid := false
+		for _, known := range Dimensions {
+			if dim == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown dimension %q (expected one of %s)", part, joinDimensions(Dimensions))
+		}
+
+		if !seen[dim] {
+			dims = append(dims, dim)
+			seen[dim] = true
+		}
+	}
+
+	if len(dims) == 0 {
+		return nil, fmt.Errorf("at least one dimension is required")
+	}
+
+	return dims, nil
+}
+
+// ParseTime parses a report boundary: a date (2006-01-02) in local time, an
+// RFC 3339 timestamp, "today", "yesterday", or an age such as "7d" or "36h".
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	today := startOfDay(now)
+
+	switch strings.ToLower(value) {
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "now":
+		return now, nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", value, now.Location()); err == nil {
+		return t, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && days >= 0 {
+			return today.AddDate(0, 0, -days), nil
+		}
+	}
+
+	if age, err := time.ParseDuration(value); err == nil && age >= 0 {
+		return now.Add(-age), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q (use YYYY-MM-DD, RFC 3339, today, yesterday, 7d or 36h)", value)
+}
+
+// Load builds a report from the local journal for since <= t < until. The
+// heartbeats just after until are read too, so the last one in the period
+// is credited up to until like the others.
+func Load(cfg *config.Config, since, until time.Time, dims []Dimension) (*Report, error) {
+	readUntil := until
+	if !until.IsZero() {
+		readUntil = until.Add(DefaultTimeout)
+	}
+
+	records, err := tracker.ReadJournal(cfg, since, readUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	report := aggregateSpans(Clip(Durations(records, DefaultTimeout), until), dims)
+	report.Since = since
+	report.Until = until
+	return report, nil
+}
+
+// Durations credits each heartbeat with the gap to the next one, as long as
+// the gap doesn't exceed timeout. The last heartbeat of a session gets no
+// time, the same way WakaTime computes durations.
+func Durations(records []tracker.HeartbeatRecord, timeout time.Duration) []Span {
+	sorted := make([]tracker.HeartbeatRecord, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	spans := make([]Span, len(sorted))
+	for i, record := range sorted {
+		spans[i].HeartbeatRecord = record
+		if i+1 < len(sorted) {
+			if gap := sorted[i+1].Time.Sub(record.Time); gap <= timeout {
+				spans[i].Duration = gap
+			}
+		}
+	}
+
+	return spans
+}
+
+// Clip drops the spans starting at or after until and shortens the ones
+// running past it. A zero until leaves spans as they are.
+func Clip(spans []Span, until time.Time) []Span {
+	if until.IsZero() {
+		return spans
+	}
+
+	var clipped []Span
+	for _, span := range spans {
+		if !span.Time.Before(until) {
+			continue
+		}
+		if end := span.Time.Add(span.Duration); end.After(until) {
+			span.Duration = until.Sub(span.Time)
+		}
+		clipped = append(clipped, span)
+	}
+	return clipped
+}
+
+// Aggregate builds a report over records grouped by dims. Rows are sorted by
+// duration, longest first.
+func Aggregate(records []tracker.HeartbeatRecord, dims []Dimension, timeout time.Duration) *Report {
+	return aggregateSpans(Durations(records, timeout), dims)
+}
+
+func aggregateSpans(spans []Span, dims []Dimension) *Report {
+	report := &Report{Dimensions: dims}
+
+	totals := make(map[string]*Row)
+	var order []string
+
+	for _, span := range spans {
+		if span.Duration == 0 {
+			continue
+		}
+
+		keys := make([]string, len(dims))
+		for i, dim := range dims {
+			keys[i] = value(span.HeartbeatRecord, dim)
+		}
+
+		id := strings.Join(keys, "\x00")
+		row, ok := totals[id]
+		if !ok {
+			row = &Row{Keys: keys}
+			totals[id] = row
+			order = append(order, id)
+		}
+
+		row.Duration += span.Duration
+		report.Total += span.Duration
+	}
+
+	for _, id := range order {
+		report.Rows = append(report.Rows, *totals[id])
+	}
+
+	sort.SliceStable(report.Rows, func(i, j int) bool {
+		if report.Rows[i].Duration != report.Rows[j].Duration {
+			return report.Rows[i].Duration > report.Rows[j].Duration
+		}
+		return strings.Join(report.Rows[i].Keys, "\x00") < strings.Join(report.Rows[j].Keys, "\x00")
+	})
+
+	return report
+}
+
+func value(record tracker.HeartbeatRecord, dim Dimension) string {
+	var v string
+	switch dim {
+	case ByDay:
+		v = record.Time.Local().Format("2006-01-02")
+	case ByProject:
+		v = record.Project
+	case ByCategory:
+		v = record.Category
+	case ByLanguage:
+		v = record.Language
+	case ByBranch:
+		v = record.Branch
+	case ByCommand:
+		v = record.Command
+	case ByEntity:
+		v = record.Entity
+	}
+
+	if v == "" {
+		return Unknown
+	}
+	return v
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func joinDimensions(dims []Dimension) string {
+	names := make([]string, len(dims))
+	for i, dim := range dims {
+		names[i] = string(dim)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,246 @@
+package accounting
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+	"github.com/hackclub/terminal-wakatime/pkg/tracker"
+)
+
+var base = time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local)
+
+func heartbeat(offset time.Duration, project, category, command string) tracker.HeartbeatRecord {
+	return tracker.HeartbeatRecord{
+		Time:     base.Add(offset),
+		Entity:   command,
+		Project:  project,
+		Category: category,
+		Command:  command,
+	}
+}
+
+func sampleRecords() []tracker.HeartbeatRecord {
+	return []tracker.HeartbeatRecord{
+		heartbeat(0, "api", "coding", "vim"),                  // 10m until make
+		heartbeat(10*time.Minute, "api", "building", "make"),  // 2m
+		heartbeat(12*time.Minute, "web", "coding", "nvim"),    // 8m
+		heartbeat(20*time.Minute, "web", "coding", "nvim"),    // gap over the timeout, 0m
+		heartbeat(2*time.Hour, "api", "coding", "vim"),        // 3m until ls
+		heartbeat(2*time.Hour+5*time.Minute, "", "", "htop"),  // 1m
+		heartbeat(2*time.Hour+6*time.Minute, "", "", "htop"),  // last, 0m
+		heartbeat(2*time.Hour+3*time.Minute, "api", "", "ls"), // out of order, 2m
+	}
+}
+
+func TestDurations(t *testing.T) {
+	spans := Durations(sampleRecords(), DefaultTimeout)
+
+	var total time.Duration
+	for i, span := range spans {
+		if i > 0 && span.Time.Before(spans[i-1].Time) {
+			t.Errorf("Spans are not sorted by time at %d", i)
+		}
+		total += span.Duration
+	}
+
+	if total != 26*time.Minute {
+		t.Errorf("Expected 26m total, got %v", total)
+	}
+
+	if last := spans[len(spans)-1]; last.Duration != 0 {
+		t.Errorf("Expected the last heartbeat to get no time, got %v", last.Duration)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	report := Aggregate(sampleRecords(), []Dimension{ByProject}, DefaultTimeout)
+
+	if report.Total != 26*time.Minute {
+		t.Errorf("Expected 26m total, got %v", report.Total)
+	}
+
+	expected := []Row{
+		{Keys: []string{"api"}, Duration: 17 * time.Minute},
+		{Keys: []string{"web"}, Duration: 8 * time.Minute},
+		{Keys: []string{Unknown}, Duration: time.Minute},
+	}
+
+	if len(report.Rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %+v", len(expected), report.Rows)
+	}
+	for i, row := range expected {
+		got := report.Rows[i]
+		if strings.Join(got.Keys, ",") != strings.Join(row.Keys, ",") || got.Duration != row.Duration {
+			t.Errorf("Row %d = %+v, want %+v", i, got, row)
+		}
+	}
+
+	report = Aggregate(sampleRecords(), []Dimension{ByDay, ByCategory}, DefaultTimeout)
+	if report.Rows[0].Keys[0] != "2024-05-01" || report.Rows[0].Keys[1] != "coding" || report.Rows[0].Duration != 21*time.Minute {
+		t.Errorf("Unexpected first row %+v", report.Rows[0])
+	}
+}
+
+func TestLoadCreditsTheLastSpanUpToUntil(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+	if err := os.MkdirAll(cfg.WakaTimeDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var journal bytes.Buffer
+	for _, record := range sampleRecords() {
+		data, _ := json.Marshal(record)
+		journal.Write(append(data, '\n'))
+	}
+	if err := os.WriteFile(filepath.Join(cfg.WakaTimeDir(), tracker.JournalFile), journal.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// make runs from 10m to the next heartbeat at 12m, so 1m of it falls
+	// before until
+	report, err := Load(cfg, base, base.Add(11*time.Minute), []Dimension{ByCommand})
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if report.Total != 11*time.Minute {
+		t.Errorf("Expected 11m total, got %v", report.Total)
+	}
+	if len(report.Rows) != 2 || report.Rows[1].Keys[0] != "make" || report.Rows[1].Duration != time.Minute {
+		t.Errorf("Expected make to be credited 1m, got %+v", report.Rows)
+	}
+
+	// Heartbeats from until on are only read to credit the one before
+	report, err = Load(cfg, base, base.Add(12*time.Minute), []Dimension{ByProject})
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(report.Rows) != 1 || report.Rows[0].Keys[0] != "api" || report.Total != 12*time.Minute {
+		t.Errorf("Expected 12m of api only, got %+v", report.Rows)
+	}
+}
+
+func TestParseDimensions(t *testing.T) {
+	dims, err := ParseDimensions("Project, category,project")
+	if err != nil {
+		t.Fatalf("ParseDimensions() failed: %v", err)
+	}
+	if len(dims) != 2 || dims[0] != ByProject || dims[1] != ByCategory {
+		t.Errorf("Unexpected dimensions %v", dims)
+	}
+
+	for _, invalid := range []string{"", "editor", "project,,nope"} {
+		if _, err := ParseDimensions(invalid); err == nil {
+			t.Errorf("Expected ParseDimensions(%q) to fail", invalid)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 5, 10, 15, 30, 0, 0, time.Local)
+	today := time.Date(2024, 5, 10, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"today", today},
+		{"yesterday", today.AddDate(0, 0, -1)},
+		{"now", now},
+		{"7d", today.AddDate(0, 0, -7)},
+		{"36h", now.Add(-36 * time.Hour)},
+		{"2024-05-01", time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)},
+		{"2024-05-01T10:00:00Z", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseTime(tt.input, now)
+			if err != nil {
+				t.Fatalf("ParseTime(%q) failed: %v", tt.input, err)
+			}
+			if !got.Equal(tt.expected) {
+				t.Errorf("ParseTime(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"last week", "-3d", "2024-13-01"} {
+		if _, err := ParseTime(invalid, now); err == nil {
+			t.Errorf("Expected ParseTime(%q) to fail", invalid)
+		}
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	report := Aggregate(sampleRecords(), []Dimension{ByProject, ByCommand}, DefaultTimeout)
+
+	tests := []struct {
+		format   string
+		contains []string
+	}{
+		{FormatTable, []string{"Project", "Command", "api", "vim", "13m", "Total", "26m"}},
+		{FormatCSV, []string{"project,command,seconds\n", "api,vim,780\n"}},
+		{FormatMarkdown, []string{"| Project | Command | Time | % |", "| --- | --- | ---: | ---: |", "| api | vim | 13m |", "**26m**"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := report.Write(&buf, tt.format); err != nil {
+				t.Fatalf("Write(%s) failed: %v", tt.format, err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("Expected %s output to contain %q, got:\n%s", tt.format, want, buf.String())
+				}
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := report.Write(&buf, FormatJSON); err != nil {
+		t.Fatalf("Write(json) failed: %v", err)
+	}
+
+	var decoded jsonReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	if decoded.TotalSeconds != 26*60 || len(decoded.Rows) != len(report.Rows) {
+		t.Errorf("Unexpected JSON report %+v", decoded)
+	}
+	if decoded.Rows[0].Keys["project"] != "api" || decoded.Rows[0].Keys["command"] != "vim" {
+		t.Errorf("Unexpected first JSON row %+v", decoded.Rows[0])
+	}
+
+	if err := report.Write(&buf, "xml"); err == nil {
+		t.Error("Expected unknown format to fail")
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                            "0m",
+		90 * time.Second:             "2m",
+		59 * time.Minute:             "59m",
+		3*time.Hour + 12*time.Minute: "3h 12m",
+		25*time.Hour + 5*time.Minute: "25h 5m",
+	}
+
+	for input, expected := range tests {
+		if got := FormatDuration(input); got != expected {
+			t.Errorf("FormatDuration(%v) = %q, want %q", input, got, expected)
+		}
+	}
+}
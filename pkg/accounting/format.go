@@ -0,0 +1,188 @@
+package accounting
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Output formats supported by Report.Write
+const (
+	FormatTable    = "table"
+	FormatJSON     = "json"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+)
+
+// Formats lists every supported output format
+var Formats = []string{FormatTable, FormatJSON, FormatCSV, FormatMarkdown}
+
+type jsonReport struct {
+	Since        *time.Time `json:"since,omitempty"`
+	Until        *time.Time `json:"until,omitempty"`
+	By           []string   `json:"by"`
+	TotalSeconds int64      `json:"total_seconds"`
+	Total        string     `json:"total"`
+	Rows         []jsonRow  `json:"rows"`
+}
+
+type jsonRow struct {
+	Keys    map[string]string `json:"keys"`
+	Seconds int64             `json:"seconds"`
+	Text    string            `json:"text"`
+	Percent float64           `json:"percent"`
+}
+
+// Write renders the report to w in the given format
+func (r *Report) Write(w io.Writer, format string) error {
+	switch strings.ToLower(format) {
+	case FormatTable, "":
+		return r.writeTable(w)
+	case FormatJSON:
+		return r.writeJSON(w)
+	case FormatCSV:
+		return r.writeCSV(w)
+	case FormatMarkdown, "md":
+		return r.writeMarkdown(w)
+	default:
+		return fmt.Errorf("unknown format %q (expected one of %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// FormatDuration formats d like WakaTime's dashboard, e.g. "3h 12m"
+func FormatDuration(d time.Duration) string {
+	minutes := int(d.Round(time.Minute).Minutes())
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+}
+
+func (r *Report) percent(d time.Duration) float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return float64(d) / float64(r.Total) * 100
+}
+
+func (r *Report) headers() []string {
+	headers := make([]string, 0, len(r.Dimensions)+2)
+	for _, dim := range r.Dimensions {
+		headers = append(headers, strings.ToUpper(string(dim[:1]))+string(dim[1:]))
+	}
+	return append(headers, "Time", "%")
+}
+
+func (r *Report) writeTable(w io.Writer) error {
+	if len(r.Rows) == 0 {
+		_, err := fmt.Fprintln(w, "No activity recorded for this period.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, strings.Join(r.headers(), "\t"))
+	for _, row := range r.Rows {
+		fields := append(append([]string{}, row.Keys...),
+			FormatDuration(row.Duration),
+			fmt.Sprintf("%.1f", r.percent(row.Duration)))
+		fmt.Fprintln(tw, strings.Join(fields, "\t"))
+	}
+
+	total := make([]string, len(r.Dimensions))
+	total[0] = "Total"
+	fmt.Fprintln(tw, strings.Join(append(total, FormatDuration(r.Total), "100.0"), "\t"))
+
+	return tw.Flush()
+}
+
+func (r *Report) writeJSON(w io.Writer) error {
+	out := jsonReport{
+		TotalSeconds: int64(r.Total.Seconds()),
+		Total:        FormatDuration(r.Total),
+		Rows:         []jsonRow{},
+	}
+
+	if !r.Since.IsZero() {
+		out.Since = &r.Since
+	}
+	if !r.Until.IsZero() {
+		out.Until = &r.Until
+	}
+
+	for _, dim := range r.Dimensions {
+		out.By = append(out.By, string(dim))
+	}
+
+	for _, row := range r.Rows {
+		keys := make(map[string]string, len(row.Keys))
+		for i, dim := range r.Dimensions {
+			keys[string(dim)] = row.Keys[i]
+		}
+		out.Rows = append(out.Rows, jsonRow{
+			Keys:    keys,
+			Seconds: int64(row.Duration.Seconds()),
+			Text:    FormatDuration(row.Duration),
+			Percent: r.percent(row.Duration),
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+func (r *Report) writeCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	headers := make([]string, 0, len(r.Dimensions)+1)
+	for _, dim := range r.Dimensions {
+		headers = append(headers, string(dim))
+	}
+	if err := writer.Write(append(headers, "seconds")); err != nil {
+		return err
+	}
+
+	for _, row := range r.Rows {
+		record := append(append([]string{}, row.Keys...), strconv.FormatInt(int64(row.Duration.Seconds()), 10))
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func (r *Report) writeMarkdown(w io.Writer) error {
+	headers := r.headers()
+	separators := make([]string, len(headers))
+	for i := range separators {
+		separators[i] = "---"
+		if i >= len(r.Dimensions) {
+			separators[i] = "---:"
+		}
+	}
+
+	fmt.Fprintf(w, "| %s |\n", strings.Join(headers, " | "))
+	fmt.Fprintf(w, "| %s |\n", strings.Join(separators, " | "))
+
+	for _, row := range r.Rows {
+		fields := make([]string, 0, len(headers))
+		for _, key := range row.Keys {
+			fields = append(fields, strings.ReplaceAll(key, "|", `\|`))
+		}
+		fields = append(fields, FormatDuration(row.Duration), fmt.Sprintf("%.1f", r.percent(row.Duration)))
+		fmt.Fprintf(w, "| %s |\n", strings.Join(fields, " | "))
+	}
+
+	total := make([]string, len(r.Dimensions))
+	total[0] = "**Total**"
+	_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(append(total, "**"+FormatDuration(r.Total)+"**", "100.0"), " | "))
+	return err
+}
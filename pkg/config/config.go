@@ -2,9 +2,12 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/ini.v1"
@@ -19,11 +22,22 @@ const (
 	PluginName                = "terminal-wakatime"
 	// WakaTime official plugin interval - hardcoded as per spec
 	WakaTimeInterval = 2 * time.Minute
+
+	// Self-update channels
+	UpdateChannelStable     = "stable"
+	UpdateChannelPrerelease = "prerelease"
+	UpdateChannelOff        = "off"
 )
 
+// UpdateChannels lists the valid update_channel values
+var UpdateChannels = []string{UpdateChannelStable, UpdateChannelPrerelease, UpdateChannelOff}
+
 // PluginVersion will be set at build time via ldflags
 var PluginVersion = "dev"
 
+// WakaTime API keys are UUIDs, optionally prefixed with "waka_"
+var apiKeyPattern = regexp.MustCompile(`^(waka_)?[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
 type Config struct {
 	APIKey                     string
 	APIUrl                     string
@@ -38,8 +52,42 @@ type Config struct {
 	Exclude                    []string
 	Include                    []string
 	IncludeOnlyWithProjectFile bool
-	configFile                 string
-	wakaTimeDir                string
+
+	// Release endpoints, for mirrors and air-gapped installs. Empty means
+	// the GitHub defaults.
+	CLIReleasesURL    string
+	UpdateReleasesURL string
+
+	// WakaTimeCLIVersion pins wakatime-cli to a release tag such as
+	// "v1.98.0". Empty means track the latest release.
+	WakaTimeCLIVersion string
+
+	// UpdateChannel selects which terminal-wakatime releases self-update
+	// installs; UpdateNotifyOnly announces them without installing
+	UpdateChannel    string
+	UpdateNotifyOnly bool
+
+	// WatchEditors watches the project for saved files while an editor
+	// command runs, so `vim .` reports the files actually edited
+	WatchEditors bool
+
+	// TmuxSessionProject names the project after the tmux session commands
+	// run in, for people who keep one named session per project
+	TmuxSessionProject bool
+
+	// SSHForward makes the shell hooks run ssh through terminal-wakatime,
+	// which forwards the remote machine's heartbeats back to this one
+	SSHForward bool
+
+	// Network settings shared with wakatime-cli
+	Proxy        string
+	SSLCertsFile string
+
+	configFile  string
+	wakaTimeDir string
+
+	// saved holds the settings as of Load, so Save only writes changes
+	saved map[string]string
 }
 
 func NewConfig() (*Config, error) {
@@ -60,6 +108,7 @@ func NewConfig() (*Config, error) {
 		DisableEditorSuggestions:  false,
 		EditorSuggestionFrequency: 24 * time.Hour,
 		EditorSuggestions:         []string{"vim", "emacs", "code", "sublime", "atom"},
+		UpdateChannel:             UpdateChannelStable,
 		configFile:                configFile,
 		wakaTimeDir:               wakaTimeDir,
 	}
@@ -112,6 +161,47 @@ func (c *Config) Load() error {
 		if includeOnly, err := section.Key("include_only_with_project_file").Bool(); err == nil {
 			c.IncludeOnlyWithProjectFile = includeOnly
 		}
+
+		if releasesURL := section.Key("wakatime_cli_releases_url"); releasesURL.String() != "" {
+			c.CLIReleasesURL = releasesURL.String()
+		}
+
+		if releasesURL := section.Key("update_releases_url"); releasesURL.String() != "" {
+			c.UpdateReleasesURL = releasesURL.String()
+		}
+
+		if version := section.Key("wakatime_cli_version"); version.String() != "" {
+			c.WakaTimeCLIVersion = version.String()
+		}
+
+		// An unknown channel keeps the default rather than breaking every command
+		if channel := section.Key("update_channel"); ValidateUpdateChannel(channel.String()) == nil {
+			c.UpdateChannel = strings.ToLower(channel.String())
+		}
+
+		if notifyOnly, err := section.Key("update_notify_only").Bool(); err == nil {
+			c.UpdateNotifyOnly = notifyOnly
+		}
+
+		if watch, err := section.Key("watch_editors").Bool(); err == nil {
+			c.WatchEditors = watch
+		}
+
+		if sessionProject, err := section.Key("tmux_session_project").Bool(); err == nil {
+			c.TmuxSessionProject = sessionProject
+		}
+
+		if forward, err := section.Key("ssh_forward").Bool(); err == nil {
+			c.SSHForward = forward
+		}
+
+		if proxy := section.Key("proxy"); proxy.String() != "" {
+			c.Proxy = proxy.String()
+		}
+
+		if certs := section.Key("ssl_certs_file"); certs.String() != "" {
+			c.SSLCertsFile = certs.String()
+		}
 	}
 
 	// Load environment variables for terminal-wakatime specific settings
@@ -131,37 +221,125 @@ func (c *Config) Load() error {
 		c.DisableEditorSuggestions = true
 	}
 
+	if releasesURL := os.Getenv("TERMINAL_WAKATIME_CLI_RELEASES_URL"); releasesURL != "" {
+		c.CLIReleasesURL = releasesURL
+	}
+
+	if releasesURL := os.Getenv("TERMINAL_WAKATIME_UPDATE_RELEASES_URL"); releasesURL != "" {
+		c.UpdateReleasesURL = releasesURL
+	}
+
+	if version := os.Getenv("TERMINAL_WAKATIME_CLI_VERSION"); version != "" {
+		c.WakaTimeCLIVersion = version
+	}
+
+	if channel := os.Getenv("TERMINAL_WAKATIME_UPDATE_CHANNEL"); ValidateUpdateChannel(channel) == nil {
+		c.UpdateChannel = strings.ToLower(channel)
+	}
+
+	if watch := os.Getenv("TERMINAL_WAKATIME_WATCH_EDITORS"); watch != "" {
+		c.WatchEditors = watch == "true"
+	}
+
+	if sessionProject := os.Getenv("TERMINAL_WAKATIME_TMUX_SESSION_PROJECT"); sessionProject != "" {
+		c.TmuxSessionProject = sessionProject == "true"
+	}
+
+	if forward := os.Getenv("TERMINAL_WAKATIME_SSH_FORWARD"); forward != "" {
+		c.SSHForward = forward == "true"
+	}
+
+	if certs := os.Getenv("TERMINAL_WAKATIME_SSL_CERTS_FILE"); certs != "" {
+		c.SSLCertsFile = certs
+	}
+
+	c.saved = settingsMap(c.settings())
 	return nil
 }
 
+// Save writes the settings changed since Load to the config file. The file
+// is shared with wakatime-cli and the editor plugins, so it is edited in
+// place: keys and sections this struct doesn't model are kept, as are
+// values that came from the environment and weren't changed.
 func (c *Config) Save() error {
 	cfg := ini.Empty()
+	if _, err := os.Stat(c.configFile); err == nil {
+		loaded, err := ini.Load(c.configFile)
+		if err != nil {
+			return fmt.Errorf("failed to load config file: %w", err)
+		}
+		cfg = loaded
+	}
 	section := cfg.Section("settings")
 
-	section.Key("api_key").SetValue(c.APIKey)
-	section.Key("api_url").SetValue(c.APIUrl)
-	section.Key("debug").SetValue(strconv.FormatBool(c.Debug))
-	section.Key("hidefilenames").SetValue(strconv.FormatBool(c.HideFilenames))
+	current := c.settings()
+	for _, setting := range current {
+		if saved, ok := c.saved[setting.key]; ok && saved == setting.value {
+			continue
+		}
 
-	if c.Project != "" {
-		section.Key("project").SetValue(c.Project)
+		if setting.value == "" {
+			section.DeleteKey(setting.key)
+		} else {
+			section.Key(setting.key).SetValue(setting.value)
+		}
 	}
 
-	if len(c.Exclude) > 0 {
-		section.Key("exclude").SetValue(joinStrings(c.Exclude, "\n"))
+	if err := os.MkdirAll(filepath.Dir(c.configFile), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	if len(c.Include) > 0 {
-		section.Key("include").SetValue(joinStrings(c.Include, "\n"))
+	if err := cfg.SaveTo(c.configFile); err != nil {
+		return err
 	}
 
-	section.Key("include_only_with_project_file").SetValue(strconv.FormatBool(c.IncludeOnlyWithProjectFile))
+	c.saved = settingsMap(current)
+	return nil
+}
 
-	if err := os.MkdirAll(filepath.Dir(c.configFile), 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
+// setting is a config file key and the value Save writes for it. An empty
+// value means the key is left out.
+type setting struct {
+	key, value string
+}
+
+// settings returns the config file keys this struct models, in file order
+func (c *Config) settings() []setting {
+	optional := func(set bool, value string) string {
+		if set {
+			return value
+		}
+		return ""
 	}
 
-	return cfg.SaveTo(c.configFile)
+	return []setting{
+		{"api_key", c.APIKey},
+		{"api_url", c.APIUrl},
+		{"debug", strconv.FormatBool(c.Debug)},
+		{"hidefilenames", strconv.FormatBool(c.HideFilenames)},
+		{"project", c.Project},
+		{"exclude", joinStrings(c.Exclude, "\n")},
+		{"include", joinStrings(c.Include, "\n")},
+		{"include_only_with_project_file", strconv.FormatBool(c.IncludeOnlyWithProjectFile)},
+		{"wakatime_cli_releases_url", c.CLIReleasesURL},
+		{"update_releases_url", c.UpdateReleasesURL},
+		{"wakatime_cli_version", c.WakaTimeCLIVersion},
+		{"update_channel", optional(c.UpdateChannel != UpdateChannelStable, c.UpdateChannel)},
+		{"update_notify_only", optional(c.UpdateNotifyOnly, "true")},
+		{"watch_editors", optional(c.WatchEditors, "true")},
+		{"tmux_session_project", optional(c.TmuxSessionProject, "true")},
+		{"ssh_forward", optional(c.SSHForward, "true")},
+		{"proxy", c.Proxy},
+		{"ssl_certs_file", c.SSLCertsFile},
+	}
+}
+
+func settingsMap(settings []setting) map[string]string {
+	m := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		m[setting.key] = setting.value
+	}
+	return m
 }
 
 func (c *Config) WakaTimeDir() string {
@@ -184,6 +362,75 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// ValidateUpdateChannel checks that channel is one of UpdateChannels
+func ValidateUpdateChannel(channel string) error {
+	for _, valid := range UpdateChannels {
+		if strings.EqualFold(channel, valid) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("update channel should be one of %s", strings.Join(UpdateChannels, ", "))
+}
+
+// ValidateAPIKey checks that key looks like a WakaTime API key
+func ValidateAPIKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("API key is required")
+	}
+
+	if !apiKeyPattern.MatchString(key) {
+		return fmt.Errorf("API key should be a UUID, optionally prefixed with waka_")
+	}
+
+	return nil
+}
+
+// ValidateAPIURL checks that apiURL is an absolute http(s) URL
+func ValidateAPIURL(apiURL string) error {
+	if apiURL == "" {
+		return fmt.Errorf("API URL is required")
+	}
+
+	parsed, err := url.Parse(apiURL)
+	if err != nil {
+		return fmt.Errorf("invalid API URL: %w", err)
+	}
+
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("API URL must be an absolute http(s) URL")
+	}
+
+	return nil
+}
+
+// IsExcluded reports whether path matches an exclude pattern and no
+// include pattern. As in wakatime-cli, patterns are case-insensitive
+// regular expressions and include wins; invalid patterns are skipped.
+func (c *Config) IsExcluded(path string) bool {
+	if matchesAny(c.Include, path) {
+		return false
+	}
+	return matchesAny(c.Exclude, path)
+}
+
+func matchesAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			continue
+		}
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
 // PluginVersion returns the current plugin version
 func (c *Config) PluginVersion() string {
 	return PluginVersion
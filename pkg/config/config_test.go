@@ -3,8 +3,11 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"gopkg.in/ini.v1"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -84,6 +87,85 @@ func TestConfigSaveAndLoad(t *testing.T) {
 	}
 }
 
+func TestConfigSaveKeepsUnknownKeys(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	t.Setenv("TERMINAL_WAKATIME_CLI_VERSION", "v1.90.0")
+
+	content := `[settings]
+api_key = waka_12345678-1234-1234-1234-123456789abc
+; set by an editor plugin
+status_bar_enabled = false
+offline = false
+debug = true
+
+[projectmap]
+~/work/api = api
+
+[git]
+submodules_disabled = true
+`
+	path := filepath.Join(tempDir, ".wakatime.cfg")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+	cfg.Debug = false
+	cfg.WatchEditors = true
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	saved, err := ini.Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load saved config: %v", err)
+	}
+
+	settings := saved.Section("settings")
+	for key, expected := range map[string]string{
+		"api_key":            "waka_12345678-1234-1234-1234-123456789abc",
+		"status_bar_enabled": "false",
+		"offline":            "false",
+		"debug":              "false",
+		"watch_editors":      "true",
+	} {
+		if got := settings.Key(key).String(); got != expected {
+			t.Errorf("Expected %s = %q, got %q", key, expected, got)
+		}
+	}
+	if saved.Section("projectmap").Key("~/work/api").String() != "api" || saved.Section("git").Key("submodules_disabled").String() != "true" {
+		t.Errorf("Expected other sections to survive Save(), got:\n%s", readFile(t, path))
+	}
+
+	// Values from the environment aren't written unless changed
+	if settings.HasKey("wakatime_cli_version") {
+		t.Errorf("Expected the environment's wakatime-cli version not to be saved, got:\n%s", readFile(t, path))
+	}
+
+	// Turning a setting off removes its key
+	cfg.WatchEditors = false
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if strings.Contains(readFile(t, path), "watch_editors") || !strings.Contains(readFile(t, path), "status_bar_enabled") {
+		t.Errorf("Expected only watch_editors to be removed, got:\n%s", readFile(t, path))
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
 func TestConfigValidation(t *testing.T) {
 	cfg := &Config{}
 
@@ -189,3 +271,128 @@ func TestConfigExcludeInclude(t *testing.T) {
 		t.Errorf("Expected include patterns [*.go, *.js], got %v", cfg2.Include)
 	}
 }
+
+func TestConfigNetworkSettings(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", originalHome)
+	os.Setenv("HOME", tempDir)
+
+	content := `[settings]
+api_key = waka_12345678-1234-1234-1234-123456789abc
+wakatime_cli_releases_url = https://mirror.example.com/wakatime-cli/latest
+wakatime_cli_version = v1.98.0
+proxy = http://proxy.example.com:3128
+ssl_certs_file = /etc/ssl/corp.pem
+`
+	if err := os.WriteFile(filepath.Join(tempDir, ".wakatime.cfg"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	os.Setenv("TERMINAL_WAKATIME_UPDATE_RELEASES_URL", "https://mirror.example.com/terminal-wakatime/latest")
+	defer os.Unsetenv("TERMINAL_WAKATIME_UPDATE_RELEASES_URL")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+
+	if cfg.CLIReleasesURL != "https://mirror.example.com/wakatime-cli/latest" {
+		t.Errorf("Unexpected CLI releases URL %q", cfg.CLIReleasesURL)
+	}
+	if cfg.UpdateReleasesURL != "https://mirror.example.com/terminal-wakatime/latest" {
+		t.Errorf("Unexpected update releases URL %q", cfg.UpdateReleasesURL)
+	}
+	if cfg.WakaTimeCLIVersion != "v1.98.0" {
+		t.Errorf("Unexpected wakatime-cli version pin %q", cfg.WakaTimeCLIVersion)
+	}
+	if cfg.Proxy != "http://proxy.example.com:3128" || cfg.SSLCertsFile != "/etc/ssl/corp.pem" {
+		t.Errorf("Unexpected proxy %q or CA bundle %q", cfg.Proxy, cfg.SSLCertsFile)
+	}
+
+	// Saving keeps the settings
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	saved, err := os.ReadFile(filepath.Join(tempDir, ".wakatime.cfg"))
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	for _, key := range []string{"wakatime_cli_releases_url", "wakatime_cli_version", "proxy", "ssl_certs_file"} {
+		if !strings.Contains(string(saved), key) {
+			t.Errorf("Expected %s to survive Save(), got:\n%s", key, saved)
+		}
+	}
+}
+
+func TestConfigUpdateChannel(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		env        string
+		channel    string
+		notifyOnly bool
+	}{
+		{"default", "", "", UpdateChannelStable, false},
+		{"prerelease", "update_channel = prerelease\n", "", UpdateChannelPrerelease, false},
+		{"case insensitive", "update_channel = OFF\n", "", UpdateChannelOff, false},
+		{"unknown keeps default", "update_channel = nightly\n", "", UpdateChannelStable, false},
+		{"notify only", "update_notify_only = true\n", "", UpdateChannelStable, true},
+		{"environment wins", "update_channel = prerelease\n", "off", UpdateChannelOff, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			originalHome := os.Getenv("HOME")
+			defer os.Setenv("HOME", originalHome)
+			os.Setenv("HOME", tempDir)
+
+			if tt.env != "" {
+				os.Setenv("TERMINAL_WAKATIME_UPDATE_CHANNEL", tt.env)
+				defer os.Unsetenv("TERMINAL_WAKATIME_UPDATE_CHANNEL")
+			}
+
+			content := "[settings]\n" + tt.file
+			if err := os.WriteFile(filepath.Join(tempDir, ".wakatime.cfg"), []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+
+			cfg, err := NewConfig()
+			if err != nil {
+				t.Fatalf("NewConfig() failed: %v", err)
+			}
+
+			if cfg.UpdateChannel != tt.channel || cfg.UpdateNotifyOnly != tt.notifyOnly {
+				t.Errorf("Expected channel %q (notify only %t), got %q (%t)", tt.channel, tt.notifyOnly, cfg.UpdateChannel, cfg.UpdateNotifyOnly)
+			}
+		})
+	}
+
+	if err := ValidateUpdateChannel("nightly"); err == nil {
+		t.Error("Expected an unknown channel to be rejected")
+	}
+}
+
+func TestConfigIsExcluded(t *testing.T) {
+	cfg := &Config{
+		Exclude: []string{"^/home/dev/private/", `\.log$`, "[invalid"},
+		Include: []string{"private/shared/"},
+	}
+
+	tests := []struct {
+		path     string
+		excluded bool
+	}{
+		{"/home/dev/src/main.go", false},
+		{"/home/dev/private/notes.md", true},
+		{"/home/dev/src/DEBUG.LOG", true},
+		{"/home/dev/private/shared/todo.md", false},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.IsExcluded(tt.path); got != tt.excluded {
+			t.Errorf("IsExcluded(%q) = %t, expected %t", tt.path, got, tt.excluded)
+		}
+	}
+}
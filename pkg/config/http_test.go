@@ -0,0 +1,88 @@
+package config
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTTPClientProxy(t *testing.T) {
+	cfg := &Config{Proxy: "http://proxy.example.com:3128"}
+
+	client, err := cfg.HTTPClient(0)
+	if err != nil {
+		t.Fatalf("HTTPClient() failed: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://api.github.com/", nil)
+	proxyURL, err := client.Transport.(*http.Transport).Proxy(req)
+	if err != nil || proxyURL == nil || proxyURL.Host != "proxy.example.com:3128" {
+		t.Errorf("Expected configured proxy, got %v (err %v)", proxyURL, err)
+	}
+
+	cfg.Proxy = "not a proxy"
+	if _, err := cfg.HTTPClient(0); err == nil {
+		t.Error("Expected an invalid proxy to be rejected")
+	}
+}
+
+func TestHTTPClientSSLCertsFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+
+	// The test server's certificate is not trusted by default
+	client, err := (&Config{}).HTTPClient(0)
+	if err != nil {
+		t.Fatalf("HTTPClient() failed: %v", err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("Expected the untrusted certificate to be rejected")
+	}
+
+	bundle := filepath.Join(dir, "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, certPEM, 0644); err != nil {
+		t.Fatalf("Failed to write CA bundle: %v", err)
+	}
+
+	client, err = (&Config{SSLCertsFile: bundle}).HTTPClient(0)
+	if err != nil {
+		t.Fatalf("HTTPClient() failed: %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the bundled certificate to be trusted: %v", err)
+	}
+	resp.Body.Close()
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{"missing file", filepath.Join(dir, "missing.pem"), "", "failed to read CA bundle"},
+		{"no certificates", filepath.Join(dir, "empty.pem"), "not a certificate\n", "no certificates found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.content != "" {
+				os.WriteFile(tt.file, []byte(tt.content), 0644)
+			}
+
+			_, err := (&Config{SSLCertsFile: tt.file}).HTTPClient(0)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
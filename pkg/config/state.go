@@ -0,0 +1,58 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ReadState decodes the JSON state file name in the WakaTime directory into
+// v. Callers treat a missing or corrupt file as empty; the next WriteState
+// replaces it.
+func (c *Config) ReadState(name string, v interface{}) error {
+	data, err := os.ReadFile(filepath.Join(c.wakaTimeDir, name))
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+// WriteState stores v as JSON in the state file name in the WakaTime
+// directory. The file is written beside it then renamed into place, so
+// concurrent processes never see a partial file.
+func (c *Config) WriteState(name string, v interface{}) error {
+	if c.wakaTimeDir == "" {
+		return fmt.Errorf("no WakaTime directory to write %s to", name)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.wakaTimeDir, 0755); err != nil {
+		return fmt.Errorf("failed to create wakatime directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(c.wakaTimeDir, name+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmp.Name(), filepath.Join(c.wakaTimeDir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
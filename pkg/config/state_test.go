@@ -0,0 +1,40 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadWriteState(t *testing.T) {
+	cfg := &Config{wakaTimeDir: filepath.Join(t.TempDir(), ".wakatime")}
+
+	if err := cfg.WriteState("state.json", map[string]int{"a": 1}); err != nil {
+		t.Fatalf("WriteState() failed: %v", err)
+	}
+
+	var state map[string]int
+	if err := cfg.ReadState("state.json", &state); err != nil || state["a"] != 1 {
+		t.Errorf("Expected the written state back, got %v (err %v)", state, err)
+	}
+
+	// Nothing is left beside the file
+	entries, _ := os.ReadDir(cfg.wakaTimeDir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the state file, got %v", entries)
+	}
+
+	if err := os.WriteFile(filepath.Join(cfg.wakaTimeDir, "state.json"), []byte("{corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ReadState("state.json", &state); err == nil {
+		t.Error("Expected a corrupt file to be reported")
+	}
+	if err := cfg.ReadState("missing.json", &state); !os.IsNotExist(err) {
+		t.Errorf("Expected a missing file to be reported as such, got %v", err)
+	}
+
+	if err := (&Config{}).WriteState("state.json", state); err == nil {
+		t.Error("Expected writing without a WakaTime directory to fail")
+	}
+}
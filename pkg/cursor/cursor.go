@@ -0,0 +1,145 @@
+// Package cursor reads the last cursor position of a file from the state
+// editors keep between sessions: vim's viminfo, Neovim's ShaDa, nano's
+// filepos_history and micro's saved buffers. Running Neovim sessions are
+// asked directly, so positions are current while the editor is open. Each
+// editor is a Parser, and more can be added with Register.
+//
+// Helix keeps no cursor state on disk and has no remote interface, so
+// Helix sessions still report the first line. The other editors only save
+// their state on exit.
+package cursor
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Position is a cursor position, both 1-based
+type Position struct {
+	Line   int
+	Column int
+
+	// Time is when the editor recorded the position, or the state file's
+	// modification time if the editor doesn't say
+	Time time.Time
+}
+
+// Parser reads positions from one editor's state
+type Parser interface {
+	// Editors are the commands whose state this parser reads
+	Editors() []string
+
+	// Position returns the last position recorded for file, an absolute
+	// path, and false if there is none
+	Position(file string) (Position, bool)
+}
+
+var (
+	parsersMu sync.RWMutex
+	parsers   []Parser
+)
+
+func init() {
+	Register(vimParser{})
+	Register(shadaParser{})
+	Register(nanoParser{})
+	Register(microParser{})
+	Register(nvimServerParser{})
+}
+
+// Register adds a parser. Later parsers don't replace earlier ones for the
+// same editor; all of them are asked.
+func Register(parser Parser) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+
+	parsers = append(parsers, parser)
+}
+
+// Lookup returns the most recently recorded position of file. With an
+// editor command, such as "nvim", only that editor's parsers are asked;
+// with "", all of them are.
+func Lookup(editor, file string) (Position, bool) {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+
+	var best Position
+	found := false
+
+	for _, parser := range parsers {
+		if editor != "" && !handles(parser, filepath.Base(editor)) {
+			continue
+		}
+
+		position, ok := parser.Position(file)
+		if !ok || position.Line < 1 {
+			continue
+		}
+		if position.Column < 1 {
+			position.Column = 1
+		}
+
+		if !found || position.Time.After(best.Time) {
+			best = position
+			found = true
+		}
+	}
+
+	return best, found
+}
+
+func handles(parser Parser, editor string) bool {
+	for _, name := range parser.Editors() {
+		if name == editor {
+			return true
+		}
+	}
+	return false
+}
+
+// homeDir returns the user's home directory, or "" if it is unknown
+func homeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return home
+}
+
+// xdgDir returns $<env>, or fallback under the home directory
+func xdgDir(env string, fallback ...string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return dir
+	}
+
+	home := homeDir()
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(append([]string{home}, fallback...)...)
+}
+
+// pathMatcher returns a test for whether a path recorded by an editor
+// names file. Editors record the name they were given, so file's resolved
+// form (/private/tmp for /tmp) matches too.
+func pathMatcher(file string) func(recorded string) bool {
+	names := map[string]bool{filepath.Clean(file): true}
+	if resolved, err := filepath.EvalSymlinks(file); err == nil {
+		names[resolved] = true
+	}
+
+	return func(recorded string) bool {
+		return recorded != "" && names[filepath.Clean(recorded)]
+	}
+}
+
+// modTime returns path's modification time, or the zero time
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,313 @@
+package cursor
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setHome points HOME and the XDG directories at a temporary directory
+func setHome(t *testing.T) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("MICRO_CONFIG_HOME", "")
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	t.Setenv("TMPDIR", home)
+
+	return home
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestParseViminfo(t *testing.T) {
+	home := "/home/dev"
+	viminfo := `# File marks:
+'0  40  2  ~/src/app/other.go
+'1  7  0  ~/src/app/marked.go
+
+# History of marks within files (newest to oldest):
+
+> ~/src/app/main.go
+	*	1700000000	0
+	"	12	4
+	^	12	5
+
+> /etc/hosts
+	*	1600000000	0
+	"	3	0
+`
+
+	tests := []struct {
+		name     string
+		file     string
+		expected Position
+		found    bool
+	}{
+		{"history block", "/home/dev/src/app/main.go", Position{Line: 12, Column: 5, Time: time.Unix(1700000000, 0)}, true},
+		{"absolute path", "/etc/hosts", Position{Line: 3, Column: 1, Time: time.Unix(1600000000, 0)}, true},
+		{"file mark fallback", "/home/dev/src/app/marked.go", Position{Line: 7, Column: 1}, true},
+		{"unknown file", "/home/dev/src/app/missing.go", Position{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position, ok := parseViminfo(strings.NewReader(viminfo), tt.file, home, time.Time{})
+			if ok != tt.found || position != tt.expected {
+				t.Errorf("Expected %+v (%t), got %+v (%t)", tt.expected, tt.found, position, ok)
+			}
+		})
+	}
+}
+
+// msgpack encodes the values the ShaDa tests need
+func msgpack(values ...any) []byte {
+	var buf bytes.Buffer
+
+	var encode func(value any)
+	encode = func(value any) {
+		switch v := value.(type) {
+		case int:
+			switch {
+			case v >= 0 && v <= 0x7f:
+				buf.WriteByte(byte(v))
+			case v >= 0 && v <= 0xffff:
+				buf.Write([]byte{0xcd, byte(v >> 8), byte(v)})
+			default:
+				buf.Write([]byte{0xce, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+			}
+		case string:
+			buf.Write([]byte{0xd9, byte(len(v))})
+			buf.WriteString(v)
+		case map[string]any:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			buf.WriteByte(0x80 | byte(len(v)))
+			for _, key := range keys {
+				encode(key)
+				encode(v[key])
+			}
+		case []any:
+			buf.WriteByte(0x90 | byte(len(v)))
+			for _, item := range v {
+				encode(item)
+			}
+		}
+	}
+
+	for _, value := range values {
+		encode(value)
+	}
+	return buf.Bytes()
+}
+
+// shadaEntry encodes one ShaDa entry
+func shadaEntry(entryType, timestamp int, data any) []byte {
+	body := msgpack(data)
+	return append(msgpack(entryType, timestamp, len(body)), body...)
+}
+
+func TestParseShada(t *testing.T) {
+	file := "/home/dev/src/app/main.go"
+
+	var data []byte
+	data = append(data, shadaEntry(1, 1700000000, map[string]any{"generator": "nvim", "version": "0.10.0"})...)
+	data = append(data, shadaEntry(8, 1700000300, map[string]any{"f": file, "l": 80, "c": 2})...)
+	data = append(data, shadaEntry(10, 1700000100, map[string]any{"f": file, "l": 12, "c": 4})...)
+	data = append(data, shadaEntry(10, 1700000200, map[string]any{"f": file, "l": 99, "n": int('.')})...)
+	data = append(data, shadaEntry(10, 1700000400, map[string]any{"f": "/other.go", "l": 5})...)
+	data = append(data, shadaEntry(4, 1700000500, []any{0, "set ft=go"})...)
+
+	position, ok := parseShada(data, file)
+	expected := Position{Line: 12, Column: 5, Time: time.Unix(1700000100, 0)}
+	if !ok || position != expected {
+		t.Errorf("Expected the \" mark %+v, got %+v (%t)", expected, position, ok)
+	}
+
+	// Without a " mark the newest other position is used, with defaults
+	// for omitted fields
+	jumps := append(shadaEntry(8, 1700000300, map[string]any{"f": file, "l": 80}), shadaEntry(11, 1700000600, map[string]any{"f": file})...)
+	position, ok = parseShada(jumps, file)
+	expected = Position{Line: 1, Column: 1, Time: time.Unix(1700000600, 0)}
+	if !ok || position != expected {
+		t.Errorf("Expected %+v, got %+v (%t)", expected, position, ok)
+	}
+
+	// Truncated files don't panic
+	if _, ok := parseShada(data[:len(data)/2], "/nowhere"); ok {
+		t.Error("Expected no position in a truncated file for an unknown path")
+	}
+}
+
+func TestParseFilepos(t *testing.T) {
+	history := `/home/dev/notes.txt 3 1
+/home/dev/my notes.md 10 7 4 9
+/home/dev/notes.txt 25 3
+`
+
+	tests := []struct {
+		file     string
+		expected Position
+		found    bool
+	}{
+		{"/home/dev/notes.txt", Position{Line: 25, Column: 3}, true},
+		{"/home/dev/my notes.md", Position{Line: 10, Column: 7}, true},
+		{"/home/dev/my", Position{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			position, ok := parseFilepos(strings.NewReader(history), tt.file, time.Time{})
+			if ok != tt.found || position != tt.expected {
+				t.Errorf("Expected %+v (%t), got %+v (%t)", tt.expected, tt.found, position, ok)
+			}
+		})
+	}
+}
+
+func TestMicroParser(t *testing.T) {
+	home := setHome(t)
+	file := filepath.Join(home, "src", "main.go")
+
+	// The full SerializedBuffer; the parser only declares Cursor
+	type loc struct{ X, Y int }
+	type eventHandler struct{ UndoStack []string }
+	serialized := struct {
+		EventHandler *eventHandler
+		Cursor       loc
+		ModTime      time.Time
+	}{&eventHandler{UndoStack: []string{"insert"}}, loc{X: 4, Y: 11}, time.Now()}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(serialized); err != nil {
+		t.Fatalf("Failed to encode buffer: %v", err)
+	}
+	writeFile(t, filepath.Join(home, ".config", "micro", "buffers", strings.ReplaceAll(file, "/", "%")), buf.Bytes())
+
+	position, ok := microParser{}.Position(file)
+	if !ok || position.Line != 12 || position.Column != 5 {
+		t.Errorf("Expected line 12, column 5, got %+v (%t)", position, ok)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	home := setHome(t)
+	file := filepath.Join(home, "src", "main.go")
+
+	writeFile(t, filepath.Join(home, ".viminfo"), []byte("> ~/src/main.go\n\t*\t1700000000\t0\n\t\"\t12\t4\n"))
+	writeFile(t, filepath.Join(home, ".local", "share", "nano", "filepos_history"), []byte(file+" 30 2\n"))
+
+	// nano's history has no timestamps, so it is dated by the file
+	old := time.Unix(1600000000, 0)
+	os.Chtimes(filepath.Join(home, ".local", "share", "nano", "filepos_history"), old, old)
+
+	tests := []struct {
+		editor string
+		line   int
+		found  bool
+	}{
+		{"vim", 12, true},
+		{"/usr/bin/nano", 30, true},
+		{"", 12, true},
+		{"nvim", 0, false},
+		{"emacs", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.editor, func(t *testing.T) {
+			position, ok := Lookup(tt.editor, file)
+			if ok != tt.found || position.Line != tt.line {
+				t.Errorf("Lookup(%q) = %+v (%t), expected line %d (%t)", tt.editor, position, ok, tt.line, tt.found)
+			}
+		})
+	}
+}
+
+func TestNvimServerParser(t *testing.T) {
+	home := setHome(t)
+	runtime := filepath.Join(home, "run")
+	t.Setenv("XDG_RUNTIME_DIR", runtime)
+
+	// A session that has quit left its socket behind
+	writeFile(t, filepath.Join(runtime, "nvim.100.0"), nil)
+	writeFile(t, filepath.Join(runtime, "nvim.200.0"), nil)
+
+	saved := nvimRemoteExpr
+	defer func() { nvimRemoteExpr = saved }()
+	nvimRemoteExpr = func(socket, expr string) ([]byte, error) {
+		if filepath.Base(socket) == "nvim.100.0" {
+			return nil, os.ErrNotExist
+		}
+		return []byte(`[["/src/app/main.go", 40, 7], ["/src/app/main.go", 40, 0], ["/src/app/util.go", 12, 0], ["", 1, 0]]`), nil
+	}
+
+	tests := []struct {
+		file     string
+		expected Position
+		found    bool
+	}{
+		{"/src/app/main.go", Position{Line: 40, Column: 7}, true},
+		{"/src/app/util.go", Position{Line: 12, Column: 0}, true},
+		{"/src/app/other.go", Position{}, false},
+	}
+
+	for _, tt := range tests {
+		position, ok := nvimServerParser{}.Position(tt.file)
+		if ok != tt.found || position.Line != tt.expected.Line || position.Column != tt.expected.Column {
+			t.Errorf("Position(%s) = %+v (%t), expected %+v (%t)", tt.file, position, ok, tt.expected, tt.found)
+		}
+	}
+
+	// A running session wins over the position ShaDa kept from the last one
+	if position, ok := Lookup("nvim", "/src/app/main.go"); !ok || position.Line != 40 {
+		t.Errorf("Expected the live position, got %+v (%t)", position, ok)
+	}
+}
+
+type fakeParser struct{ position Position }
+
+func (fakeParser) Editors() []string { return []string{"fake-editor"} }
+
+func (p fakeParser) Position(string) (Position, bool) { return p.position, true }
+
+func TestRegister(t *testing.T) {
+	setHome(t)
+
+	parsersMu.Lock()
+	saved := parsers
+	parsersMu.Unlock()
+	defer func() {
+		parsersMu.Lock()
+		parsers = saved
+		parsersMu.Unlock()
+	}()
+
+	Register(fakeParser{Position{Line: 42, Column: 0}})
+
+	position, ok := Lookup("fake-editor", "/any/file")
+	if !ok || position.Line != 42 || position.Column != 1 {
+		t.Errorf("Expected the registered parser's line 42 (column clamped to 1), got %+v (%t)", position, ok)
+	}
+}
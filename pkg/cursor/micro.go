@@ -0,0 +1,51 @@
+package cursor
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// microParser reads the buffers micro saves with its `savecursor` option:
+// one gob-encoded file per edited file, named after its path with every
+// separator replaced by %
+type microParser struct{}
+
+// microBuffer is the part of micro's SerializedBuffer we need; gob skips
+// the fields left out. Loc is 0-based.
+type microBuffer struct {
+	Cursor struct {
+		X, Y int
+	}
+}
+
+func (microParser) Editors() []string {
+	return []string{"micro"}
+}
+
+func (microParser) Position(file string) (Position, bool) {
+	configDir := os.Getenv("MICRO_CONFIG_HOME")
+	if configDir == "" {
+		configDir = filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), "micro")
+	}
+
+	absolute, err := filepath.Abs(file)
+	if err != nil {
+		return Position{}, false
+	}
+
+	path := filepath.Join(configDir, "buffers", strings.ReplaceAll(filepath.ToSlash(absolute), "/", "%"))
+	f, err := os.Open(path)
+	if err != nil {
+		return Position{}, false
+	}
+	defer f.Close()
+
+	var buffer microBuffer
+	if err := gob.NewDecoder(f).Decode(&buffer); err != nil {
+		return Position{}, false
+	}
+
+	return Position{Line: buffer.Cursor.Y + 1, Column: buffer.Cursor.X + 1, Time: modTime(path)}, true
+}
@@ -0,0 +1,70 @@
+package cursor
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// nanoParser reads nano's filepos_history (kept with `set positionlog`):
+// one "path line column" per file, possibly followed by anchors. Updated
+// files move to the end, so the last entry wins.
+type nanoParser struct{}
+
+func (nanoParser) Editors() []string {
+	return []string{"nano", "pico", "rnano"}
+}
+
+func (nanoParser) Position(file string) (Position, bool) {
+	// nano uses ~/.nano when it exists, the XDG data directory otherwise
+	paths := []string{filepath.Join(xdgDir("XDG_DATA_HOME", ".local", "share"), "nano", "filepos_history")}
+	if home := homeDir(); home != "" {
+		paths = append([]string{filepath.Join(home, ".nano", "filepos_history")}, paths...)
+	}
+
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		position, ok := parseFilepos(f, file, modTime(path))
+		f.Close()
+
+		if ok {
+			return position, true
+		}
+	}
+
+	return Position{}, false
+}
+
+func parseFilepos(r io.Reader, file string, fileTime time.Time) (Position, bool) {
+	matches := pathMatcher(file)
+
+	var position Position
+	found := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Paths may contain spaces, and anchors may follow the column
+		fields := strings.Fields(line)
+		for i := 1; i+1 < len(fields); i++ {
+			if !matches(strings.Join(fields[:i], " ")) {
+				continue
+			}
+
+			if lineNo, column, ok := lineAndColumn(fields[i], fields[i+1]); ok {
+				position = Position{Line: lineNo, Column: column, Time: fileTime}
+				found = true
+			}
+			break
+		}
+	}
+
+	return position, found
+}
@@ -0,0 +1,90 @@
+package cursor
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// nvimServerTimeout bounds the query of one running Neovim
+const nvimServerTimeout = 500 * time.Millisecond
+
+// nvimServerExpr lists the current buffer with its cursor, then the cursor
+// line of every listed buffer, as [path, line, column] triples
+const nvimServerExpr = `json_encode(extend([[expand('%:p'), line('.'), col('.')]], ` +
+	`map(getbufinfo({'buflisted': 1}), {_, b -> [b.name, b.lnum, 0]})))`
+
+// nvimRemoteExpr evaluates expr in the Neovim listening on socket. It is a
+// variable so tests don't need Neovim.
+var nvimRemoteExpr = func(socket, expr string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), nvimServerTimeout)
+	defer cancel()
+
+	return exec.CommandContext(ctx, "nvim", "--server", socket, "--remote-expr", expr).Output()
+}
+
+// nvimServerParser asks running Neovim sessions where their cursor is, so
+// files saved while an editor is still open get a current position rather
+// than the one ShaDa kept from the last session. Neovim 0.9 and later
+// listen on a socket in its run directory by default.
+type nvimServerParser struct{}
+
+func (nvimServerParser) Editors() []string {
+	return []string{"nvim", "nvim-qt", "neovide"}
+}
+
+func (nvimServerParser) Position(file string) (Position, bool) {
+	absolute, err := filepath.Abs(file)
+	if err != nil {
+		return Position{}, false
+	}
+
+	for _, socket := range nvimSockets() {
+		output, err := nvimRemoteExpr(socket, nvimServerExpr)
+		if err != nil {
+			continue
+		}
+
+		var buffers [][3]json.RawMessage
+		if err := json.Unmarshal(output, &buffers); err != nil {
+			continue
+		}
+
+		for _, buffer := range buffers {
+			var name string
+			var line, column int
+			if json.Unmarshal(buffer[0], &name) != nil || json.Unmarshal(buffer[1], &line) != nil || json.Unmarshal(buffer[2], &column) != nil {
+				continue
+			}
+			if name != "" && filepath.Clean(name) == absolute {
+				return Position{Line: line, Column: column, Time: time.Now()}, true
+			}
+		}
+	}
+
+	return Position{}, false
+}
+
+// nvimSockets returns the sockets of running Neovim sessions: nvim.<pid>.0
+// in $XDG_RUNTIME_DIR, or in a per-user directory under the temporary
+// directory without one
+func nvimSockets() []string {
+	var patterns []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		patterns = append(patterns, filepath.Join(dir, "nvim.*.0"))
+	}
+	if current, err := user.Current(); err == nil {
+		patterns = append(patterns, filepath.Join(os.TempDir(), "nvim."+current.Username, "*", "nvim.*.0"))
+	}
+
+	var sockets []string
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		sockets = append(sockets, matches...)
+	}
+	return sockets
+}
@@ -0,0 +1,315 @@
+package cursor
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ShaDa entry types that carry a file position
+const (
+	shadaGlobalMark = 7
+	shadaJump       = 8
+	shadaLocalMark  = 10
+	shadaChange     = 11
+)
+
+// shadaParser reads Neovim's ShaDa file, a sequence of msgpack entries:
+// type, timestamp, length, then a map such as {"f": file, "l": 12, "c": 4}.
+// Defaults are omitted, so a local mark without "n" is the `"` mark.
+type shadaParser struct{}
+
+func (shadaParser) Editors() []string {
+	return []string{"nvim", "nvim-qt", "neovide"}
+}
+
+func (shadaParser) Position(file string) (Position, bool) {
+	var best Position
+	found := false
+
+	// Neovim 0.8 moved ShaDa from the data to the state directory
+	for _, path := range []string{
+		filepath.Join(xdgDir("XDG_STATE_HOME", ".local", "state"), "nvim", "shada", "main.shada"),
+		filepath.Join(xdgDir("XDG_DATA_HOME", ".local", "share"), "nvim", "shada", "main.shada"),
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		if position, ok := parseShada(data, file); ok && (!found || position.Time.After(best.Time)) {
+			best = position
+			found = true
+		}
+	}
+
+	return best, found
+}
+
+// parseShada returns the newest `"` mark for file, or failing that the
+// newest jump, change or global mark in it
+func parseShada(data []byte, file string) (Position, bool) {
+	matches := pathMatcher(file)
+
+	var best Position
+	bestRank := 0
+
+	r := &msgpackReader{data: data}
+	for r.pos < len(data) {
+		entryType, err1 := r.uint()
+		timestamp, err2 := r.uint()
+		length, err3 := r.uint()
+		if err := errors.Join(err1, err2, err3); err != nil || length > uint64(len(data)-r.pos) {
+			break
+		}
+
+		end := r.pos + int(length)
+		body := data[r.pos:end]
+		r.pos = end
+
+		if entryType != shadaGlobalMark && entryType != shadaJump && entryType != shadaLocalMark && entryType != shadaChange {
+			continue
+		}
+
+		value, err := (&msgpackReader{data: body}).value()
+		if err != nil {
+			continue
+		}
+		fields, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		name, _ := fields["f"].(string)
+		if !matches(name) {
+			continue
+		}
+
+		// The `"` mark beats everything else; otherwise the newest wins
+		rank := 1
+		if entryType == shadaLocalMark && intField(fields, "n", '"') == '"' {
+			rank = 2
+		}
+
+		position := Position{
+			Line:   intField(fields, "l", 1),
+			Column: intField(fields, "c", 0) + 1,
+			Time:   time.Unix(int64(timestamp), 0),
+		}
+
+		if rank > bestRank || (rank == bestRank && !position.Time.Before(best.Time)) {
+			best = position
+			bestRank = rank
+		}
+	}
+
+	return best, bestRank > 0
+}
+
+func intField(fields map[string]any, key string, fallback int) int {
+	switch v := fields[key].(type) {
+	case uint64:
+		if v <= math.MaxInt32 {
+			return int(v)
+		}
+	case int64:
+		return int(v)
+	}
+	return fallback
+}
+
+var errMsgpack = errors.New("invalid msgpack")
+
+// msgpackReader decodes the subset of msgpack ShaDa uses. Maps keep only
+// string keys, binary data decodes as a string and extensions as nil.
+type msgpackReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *msgpackReader) next(n int) ([]byte, error) {
+	if n < 0 || n > len(r.data)-r.pos {
+		return nil, errMsgpack
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// uint reads a non-negative integer
+func (r *msgpackReader) uint() (uint64, error) {
+	value, err := r.value()
+	if err != nil {
+		return 0, err
+	}
+
+	switch v := value.(type) {
+	case uint64:
+		return v, nil
+	case int64:
+		if v >= 0 {
+			return uint64(v), nil
+		}
+	}
+	return 0, errMsgpack
+}
+
+// size reads an n-byte big-endian length
+func (r *msgpackReader) size(n int) (int, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return 0, err
+	}
+
+	var size uint64
+	for _, c := range b {
+		size = size<<8 | uint64(c)
+	}
+	if size > uint64(len(r.data)) {
+		return 0, errMsgpack
+	}
+	return int(size), nil
+}
+
+func (r *msgpackReader) value() (any, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+
+	switch {
+	case c <= 0x7f:
+		return uint64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c >= 0x80 && c <= 0x8f:
+		return r.mapOf(int(c & 0x0f))
+	case c >= 0x90 && c <= 0x9f:
+		return r.arrayOf(int(c & 0x0f))
+	case c >= 0xa0 && c <= 0xbf:
+		return r.str(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xd9: // bin 8, str 8
+		return r.sizedStr(1)
+	case 0xc5, 0xda: // bin 16, str 16
+		return r.sizedStr(2)
+	case 0xc6, 0xdb: // bin 32, str 32
+		return r.sizedStr(4)
+	case 0xc7, 0xc8, 0xc9: // ext 8/16/32
+		size, err := r.size(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		_, err = r.next(size + 1)
+		return nil, err
+	case 0xca:
+		b, err := r.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 0xcb:
+		b, err := r.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf: // uint 8/16/32/64
+		b, err := r.next(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		var v uint64
+		for _, x := range b {
+			v = v<<8 | uint64(x)
+		}
+		return v, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3: // int 8/16/32/64
+		n := 1 << (c - 0xd0)
+		b, err := r.next(n)
+		if err != nil {
+			return nil, err
+		}
+		var v uint64
+		for _, x := range b {
+			v = v<<8 | uint64(x)
+		}
+		shift := uint(64 - 8*n)
+		return int64(v<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8: // fixext 1/2/4/8/16
+		_, err := r.next(1 + 1<<(c-0xd4))
+		return nil, err
+	case 0xdc, 0xdd: // array 16/32
+		size, err := r.size(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return r.arrayOf(size)
+	case 0xde, 0xdf: // map 16/32
+		size, err := r.size(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return r.mapOf(size)
+	}
+
+	return nil, errMsgpack
+}
+
+func (r *msgpackReader) str(n int) (any, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (r *msgpackReader) sizedStr(sizeBytes int) (any, error) {
+	n, err := r.size(sizeBytes)
+	if err != nil {
+		return nil, err
+	}
+	return r.str(n)
+}
+
+func (r *msgpackReader) arrayOf(n int) (any, error) {
+	values := make([]any, 0, min(n, 64))
+	for i := 0; i < n; i++ {
+		value, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func (r *msgpackReader) mapOf(n int) (any, error) {
+	fields := make(map[string]any, min(n, 64))
+	for i := 0; i < n; i++ {
+		key, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		if name, ok := key.(string); ok {
+			fields[name] = value
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,121 @@
+package cursor
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vimParser reads ~/.viminfo. Its "History of marks within files" lists
+// files newest first, each followed by its marks; the `"` mark is where
+// the cursor was when the buffer was last left.
+//
+//	> ~/src/app/main.go
+//		*	1700000000	0
+//		"	12	4
+type vimParser struct{}
+
+func (vimParser) Editors() []string {
+	return []string{"vi", "vim", "gvim", "view", "vimdiff"}
+}
+
+func (vimParser) Position(file string) (Position, bool) {
+	home := homeDir()
+	if home == "" {
+		return Position{}, false
+	}
+
+	path := filepath.Join(home, ".viminfo")
+	f, err := os.Open(path)
+	if err != nil {
+		return Position{}, false
+	}
+	defer f.Close()
+
+	return parseViminfo(f, file, home, modTime(path))
+}
+
+func parseViminfo(r io.Reader, file, home string, fileTime time.Time) (Position, bool) {
+	matches := pathMatcher(file)
+
+	// block is set while reading the marks of file
+	var block, fileMark *Position
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "> ") {
+			if block != nil {
+				break // our block had no `"` mark
+			}
+			if matches(expandHome(strings.TrimPrefix(line, "> "), home)) {
+				block = &Position{Time: fileTime}
+			}
+			continue
+		}
+
+		if block != nil {
+			if !strings.HasPrefix(line, "\t") {
+				break
+			}
+
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+
+			switch fields[0] {
+			case "*":
+				if seconds, err := strconv.ParseInt(fields[1], 10, 64); err == nil && seconds > 0 {
+					block.Time = time.Unix(seconds, 0)
+				}
+			case `"`:
+				if lineNo, column, ok := lineAndColumn(fields[1], fields[2]); ok {
+					block.Line, block.Column = lineNo, column+1
+					return *block, true
+				}
+			}
+			continue
+		}
+
+		// '0  12  4  ~/src/app/main.go: file marks, most recent first
+		if fileMark == nil && len(line) > 2 && line[0] == '\'' && line[1] >= '0' && line[1] <= '9' {
+			fields := strings.Fields(line)
+			if len(fields) < 4 || !matches(expandHome(strings.Join(fields[3:], " "), home)) {
+				continue
+			}
+			if lineNo, column, ok := lineAndColumn(fields[1], fields[2]); ok {
+				fileMark = &Position{Line: lineNo, Column: column + 1, Time: fileTime}
+			}
+		}
+	}
+
+	if fileMark != nil {
+		return *fileMark, true
+	}
+	return Position{}, false
+}
+
+func lineAndColumn(line, column string) (int, int, bool) {
+	lineNo, err1 := strconv.Atoi(line)
+	col, err2 := strconv.Atoi(column)
+	return lineNo, col, err1 == nil && err2 == nil
+}
+
+// expandHome replaces a leading ~ with home
+func expandHome(path, home string) string {
+	if path == "~" {
+		return home
+	}
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(home, path[2:])
+	}
+	return path
+}
@@ -0,0 +1,406 @@
+package doctor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+	"github.com/hackclub/terminal-wakatime/pkg/shell"
+	"github.com/hackclub/terminal-wakatime/pkg/wakatime"
+)
+
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+const (
+	// Clock skew thresholds relative to the API server
+	ClockSkewWarning = 2 * time.Minute
+	ClockSkewFailure = 15 * time.Minute
+
+	// Entity used for the round-trip heartbeat
+	DoctorEntity = "terminal-wakatime doctor"
+)
+
+// Result is the outcome of a single check, with a suggested fix when it
+// didn't pass
+type Result struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+type Doctor struct {
+	// Offline skips the checks that talk to the API
+	Offline bool
+
+	config     *config.Config
+	loadErr    error
+	cli        *wakatime.CLI
+	binPath    string
+	httpClient *http.Client
+	netErr     error
+
+	// Date header of the round-trip response, used for the clock skew check
+	serverTime time.Time
+	localTime  time.Time
+}
+
+// NewDoctor creates a doctor for cfg. loadErr is the error (if any) returned
+// while loading the config file and binPath is the running executable.
+func NewDoctor(cfg *config.Config, loadErr error, binPath string) *Doctor {
+	httpClient, netErr := cfg.HTTPClient(10 * time.Second)
+
+	return &Doctor{
+		config:     cfg,
+		loadErr:    loadErr,
+		cli:        wakatime.NewCLI(cfg),
+		binPath:    binPath,
+		httpClient: httpClient,
+		netErr:     netErr,
+	}
+}
+
+// Run performs every check in order
+func (d *Doctor) Run() []Result {
+	checks := []func() Result{
+		d.checkHooksLoaded,
+		d.checkHookBinary,
+		d.checkConfigFile,
+		d.checkAPIKey,
+		d.checkWakaTimeCLI,
+		d.checkWakaTimeDir,
+		d.checkQueue,
+		d.checkHeartbeat,
+		d.checkClockSkew,
+	}
+
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		results = append(results, check())
+	}
+	return results
+}
+
+func (d *Doctor) checkHooksLoaded() Result {
+	result := Result{Name: "Shell hooks"}
+
+	hookBin := os.Getenv(shell.HookBinEnv)
+	if hookBin == "" {
+		result.Status = StatusFail
+		result.Detail = "not loaded in this shell"
+		result.Fix = "Run `terminal-wakatime install` and open a new terminal"
+		return result
+	}
+
+	result.Status = StatusOK
+	result.Detail = fmt.Sprintf("loaded (%s)", hookBin)
+	return result
+}
+
+func (d *Doctor) checkHookBinary() Result {
+	result := Result{Name: "Hook binary"}
+
+	// Paths baked into the loaded hooks and into every shell's rc files
+	sources := make(map[string]string)
+	var paths []string
+	add := func(path, source string) {
+		if _, seen := sources[path]; !seen {
+			paths = append(paths, path)
+			sources[path] = source
+		}
+	}
+
+	if hookBin := os.Getenv(shell.HookBinEnv); hookBin != "" {
+		add(hookBin, "loaded hooks")
+	}
+
+	for _, shellName := range []string{"bash", "zsh", "fish"} {
+		lines, err := shell.NewIntegrationForShell(d.binPath, shellName).InstalledInitLines()
+		if err != nil {
+			continue
+		}
+		for _, line := range lines {
+			add(line.BinPath, line.File)
+		}
+	}
+
+	if len(paths) == 0 {
+		result.Status = StatusSkip
+		result.Detail = "no shell integration found"
+		result.Fix = "Run `terminal-wakatime install`"
+		return result
+	}
+
+	var missing []string
+	for _, path := range paths {
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			missing = append(missing, fmt.Sprintf("%s (from %s)", path, sources[path]))
+		}
+	}
+
+	if len(missing) > 0 {
+		result.Status = StatusFail
+		result.Detail = "missing: " + strings.Join(missing, ", ")
+		result.Fix = "Run `terminal-wakatime install` to point your shell at " + d.binPath
+		return result
+	}
+
+	result.Status = StatusOK
+	result.Detail = strings.Join(paths, ", ")
+	return result
+}
+
+func (d *Doctor) checkConfigFile() Result {
+	result := Result{Name: "Config file"}
+
+	if d.loadErr != nil {
+		result.Status = StatusFail
+		result.Detail = d.loadErr.Error()
+		result.Fix = "Fix the syntax in " + d.config.ConfigFile()
+		return result
+	}
+
+	if _, err := os.Stat(d.config.ConfigFile()); os.IsNotExist(err) {
+		result.Status = StatusWarn
+		result.Detail = d.config.ConfigFile() + " does not exist"
+		result.Fix = "Run `terminal-wakatime config --key YOUR_WAKATIME_KEY`"
+		return result
+	}
+
+	if err := config.ValidateAPIURL(d.config.APIUrl); err != nil {
+		result.Status = StatusFail
+		result.Detail = err.Error()
+		result.Fix = "Set api_url in " + d.config.ConfigFile()
+		return result
+	}
+
+	result.Status = StatusOK
+	result.Detail = d.config.ConfigFile()
+	return result
+}
+
+func (d *Doctor) checkAPIKey() Result {
+	result := Result{Name: "API key"}
+
+	if err := config.ValidateAPIKey(d.config.APIKey); err != nil {
+		result.Status = StatusFail
+		result.Detail = err.Error()
+		result.Fix = "Run `terminal-wakatime config --key YOUR_WAKATIME_KEY`"
+		return result
+	}
+
+	result.Status = StatusOK
+	result.Detail = "looks valid"
+	return result
+}
+
+func (d *Doctor) checkWakaTimeCLI() Result {
+	result := Result{Name: "wakatime-cli"}
+
+	if !d.cli.IsInstalled() {
+		result.Status = StatusFail
+		result.Detail = "not installed or not runnable at " + d.cli.BinaryPath()
+		if retryAt, reason := d.cli.InstallBackoff(); !retryAt.IsZero() {
+			result.Detail += fmt.Sprintf("; last install failed (%s), next automatic attempt after %s", reason, retryAt.Format(time.Kitchen))
+		}
+		result.Fix = "Run `terminal-wakatime deps --reinstall`"
+		return result
+	}
+
+	version, err := d.cli.Version()
+	if err != nil {
+		result.Status = StatusWarn
+		result.Detail = fmt.Sprintf("runs, but version is unknown: %v", err)
+		return result
+	}
+
+	result.Status = StatusOK
+	result.Detail = version
+	return result
+}
+
+func (d *Doctor) checkWakaTimeDir() Result {
+	result := Result{Name: "WakaTime directory"}
+	dir := d.config.WakaTimeDir()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		result.Status = StatusFail
+		result.Detail = err.Error()
+		result.Fix = "Make sure " + dir + " exists and belongs to you"
+		return result
+	}
+
+	probe, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		result.Status = StatusFail
+		result.Detail = dir + " is not writable"
+		result.Fix = "Run `chmod u+w " + dir + "` or fix its ownership"
+		return result
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	result.Status = StatusOK
+	result.Detail = dir + " is writable"
+	return result
+}
+
+func (d *Doctor) checkQueue() Result {
+	result := Result{Name: "Offline queue"}
+
+	if !d.cli.IsInstalled() {
+		result.Status = StatusSkip
+		result.Detail = "wakatime-cli is not installed"
+		return result
+	}
+
+	count, err := d.cli.OfflineCount()
+	if err != nil {
+		result.Status = StatusWarn
+		result.Detail = err.Error()
+		return result
+	}
+
+	if count > 0 {
+		result.Status = StatusWarn
+		result.Detail = fmt.Sprintf("%d heartbeats waiting to be sent", count)
+		result.Fix = "Queued heartbeats are sent with the next heartbeat; if the number keeps growing, check the round-trip result below"
+		return result
+	}
+
+	result.Status = StatusOK
+	result.Detail = "empty"
+	return result
+}
+
+func (d *Doctor) checkHeartbeat() Result {
+	result := Result{Name: "Round-trip heartbeat"}
+
+	if d.Offline {
+		result.Status = StatusSkip
+		result.Detail = "offline mode"
+		return result
+	}
+
+	if d.netErr != nil {
+		result.Status = StatusFail
+		result.Detail = d.netErr.Error()
+		result.Fix = "Fix proxy or ssl_certs_file in " + d.config.ConfigFile()
+		return result
+	}
+
+	if config.ValidateAPIURL(d.config.APIUrl) != nil || d.config.APIKey == "" {
+		result.Status = StatusSkip
+		result.Detail = "API key or URL is not configured"
+		return result
+	}
+
+	heartbeat := map[string]interface{}{
+		"entity":   DoctorEntity,
+		"type":     "app",
+		"category": "coding",
+		"time":     float64(time.Now().UnixNano()) / 1e9,
+		"is_write": false,
+	}
+	body, err := json.Marshal(heartbeat)
+	if err != nil {
+		result.Status = StatusFail
+		result.Detail = err.Error()
+		return result
+	}
+
+	endpoint := strings.TrimRight(d.config.APIUrl, "/") + "/users/current/heartbeats"
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		result.Status = StatusFail
+		result.Detail = err.Error()
+		return result
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(d.config.APIKey)))
+	req.Header.Set("User-Agent", shell.FormatPluginString(config.PluginName, config.PluginVersion))
+
+	start := time.Now()
+	resp, err := d.httpClient.Do(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		result.Status = StatusFail
+		result.Detail = err.Error()
+		result.Fix = "Check your network, proxy settings and api_url (" + d.config.APIUrl + ")"
+		return result
+	}
+	defer resp.Body.Close()
+
+	if serverTime, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		d.serverTime = serverTime
+		d.localTime = start.Add(elapsed / 2)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		result.Status = StatusFail
+		result.Detail = fmt.Sprintf("API key rejected by %s (%s)", d.config.APIUrl, resp.Status)
+		result.Fix = "Copy your key from your WakaTime settings and run `terminal-wakatime config --key ...`"
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		result.Status = StatusOK
+		result.Detail = fmt.Sprintf("%s in %s", resp.Status, elapsed.Round(time.Millisecond))
+	default:
+		result.Status = StatusFail
+		result.Detail = fmt.Sprintf("%s returned %s", endpoint, resp.Status)
+		result.Fix = "Check that api_url points at a WakaTime-compatible API"
+	}
+
+	return result
+}
+
+func (d *Doctor) checkClockSkew() Result {
+	result := Result{Name: "Clock skew"}
+
+	if d.serverTime.IsZero() {
+		result.Status = StatusSkip
+		result.Detail = "no server time available"
+		return result
+	}
+
+	skew := d.localTime.Sub(d.serverTime).Round(time.Second)
+	magnitude := skew
+	if magnitude < 0 {
+		magnitude = -magnitude
+	}
+
+	direction := "ahead of"
+	if skew < 0 {
+		direction = "behind"
+	}
+	detail := fmt.Sprintf("local clock is %s %s the server", magnitude, direction)
+
+	switch {
+	case magnitude >= ClockSkewFailure:
+		result.Status = StatusFail
+		result.Detail = detail
+		result.Fix = "Enable NTP time sync; heartbeats this far off are attributed to the wrong time"
+	case magnitude >= ClockSkewWarning:
+		result.Status = StatusWarn
+		result.Detail = detail
+		result.Fix = "Enable NTP time sync"
+	default:
+		result.Status = StatusOK
+		result.Detail = detail
+	}
+
+	return result
+}
@@ -0,0 +1,189 @@
+package doctor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+	"github.com/hackclub/terminal-wakatime/pkg/shell"
+)
+
+const testAPIKey = "waka_12345678-1234-1234-1234-123456789abc"
+
+func newTestDoctor(t *testing.T, apiURL string) *Doctor {
+	t.Helper()
+
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	t.Cleanup(func() { os.Setenv("HOME", originalHome) })
+	os.Setenv("HOME", tempDir)
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+	cfg.APIKey = testAPIKey
+	cfg.APIUrl = apiURL
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	return NewDoctor(cfg, nil, filepath.Join(tempDir, "terminal-wakatime"))
+}
+
+func installFakeCLI(t *testing.T, doc *Doctor, offlineCount int) {
+	t.Helper()
+
+	script := fmt.Sprintf(`#!/bin/sh
+case "$1" in
+    --version) echo "v1.73.0" ;;
+    --offline-count) echo "%d" ;;
+esac
+`, offlineCount)
+
+	if err := os.MkdirAll(filepath.Dir(doc.cli.BinaryPath()), 0755); err != nil {
+		t.Fatalf("Failed to create wakatime dir: %v", err)
+	}
+	if err := os.WriteFile(doc.cli.BinaryPath(), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake wakatime-cli: %v", err)
+	}
+}
+
+func TestCheckHooksLoaded(t *testing.T) {
+	doc := newTestDoctor(t, config.DefaultAPIURL)
+
+	original := os.Getenv(shell.HookBinEnv)
+	defer os.Setenv(shell.HookBinEnv, original)
+
+	os.Unsetenv(shell.HookBinEnv)
+	if result := doc.checkHooksLoaded(); result.Status != StatusFail {
+		t.Errorf("Expected fail without %s, got %+v", shell.HookBinEnv, result)
+	}
+
+	os.Setenv(shell.HookBinEnv, "/opt/terminal-wakatime")
+	if result := doc.checkHooksLoaded(); result.Status != StatusOK {
+		t.Errorf("Expected ok with %s set, got %+v", shell.HookBinEnv, result)
+	}
+
+	// The hook points at a binary that no longer exists
+	if result := doc.checkHookBinary(); result.Status != StatusFail {
+		t.Errorf("Expected missing hook binary to fail, got %+v", result)
+	}
+}
+
+func TestCheckConfigAndAPIKey(t *testing.T) {
+	doc := newTestDoctor(t, config.DefaultAPIURL)
+
+	if result := doc.checkConfigFile(); result.Status != StatusOK {
+		t.Errorf("Expected valid config to pass, got %+v", result)
+	}
+	if result := doc.checkAPIKey(); result.Status != StatusOK {
+		t.Errorf("Expected valid key to pass, got %+v", result)
+	}
+
+	doc.config.APIKey = "not-a-key"
+	if result := doc.checkAPIKey(); result.Status != StatusFail {
+		t.Errorf("Expected malformed key to fail, got %+v", result)
+	}
+
+	doc.loadErr = fmt.Errorf("unclosed section: [settings")
+	if result := doc.checkConfigFile(); result.Status != StatusFail || result.Fix == "" {
+		t.Errorf("Expected parse error to fail with a fix, got %+v", result)
+	}
+}
+
+func TestCheckWakaTimeCLIAndQueue(t *testing.T) {
+	doc := newTestDoctor(t, config.DefaultAPIURL)
+
+	if result := doc.checkWakaTimeCLI(); result.Status != StatusFail {
+		t.Errorf("Expected missing wakatime-cli to fail, got %+v", result)
+	}
+	if result := doc.checkQueue(); result.Status != StatusSkip {
+		t.Errorf("Expected queue check to be skipped, got %+v", result)
+	}
+
+	installFakeCLI(t, doc, 7)
+
+	if result := doc.checkWakaTimeCLI(); result.Status != StatusOK || result.Detail != "v1.73.0" {
+		t.Errorf("Expected wakatime-cli v1.73.0, got %+v", result)
+	}
+	if result := doc.checkQueue(); result.Status != StatusWarn {
+		t.Errorf("Expected queued heartbeats to warn, got %+v", result)
+	}
+}
+
+func TestCheckWakaTimeDir(t *testing.T) {
+	doc := newTestDoctor(t, config.DefaultAPIURL)
+
+	if result := doc.checkWakaTimeDir(); result.Status != StatusOK {
+		t.Errorf("Expected writable dir to pass, got %+v", result)
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("Permission checks don't apply to root")
+	}
+
+	if err := os.Chmod(doc.config.WakaTimeDir(), 0555); err != nil {
+		t.Fatalf("Failed to make dir read-only: %v", err)
+	}
+	defer os.Chmod(doc.config.WakaTimeDir(), 0755)
+
+	if result := doc.checkWakaTimeDir(); result.Status != StatusFail {
+		t.Errorf("Expected read-only dir to fail, got %+v", result)
+	}
+}
+
+func TestCheckHeartbeatAndClockSkew(t *testing.T) {
+	var gotAuth, gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotPath = r.URL.Path
+		w.Header().Set("Date", time.Now().Add(-5*time.Minute).UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	doc := newTestDoctor(t, server.URL+"/api/v1")
+
+	if result := doc.checkClockSkew(); result.Status != StatusSkip {
+		t.Errorf("Expected clock skew to be skipped before the round trip, got %+v", result)
+	}
+
+	if result := doc.checkHeartbeat(); result.Status != StatusOK {
+		t.Fatalf("Expected round trip to pass, got %+v", result)
+	}
+
+	if gotPath != "/api/v1/users/current/heartbeats" {
+		t.Errorf("Unexpected heartbeat path %s", gotPath)
+	}
+	if gotAuth != "Basic "+base64.StdEncoding.EncodeToString([]byte(testAPIKey)) {
+		t.Errorf("Unexpected Authorization header %q", gotAuth)
+	}
+
+	if result := doc.checkClockSkew(); result.Status != StatusWarn {
+		t.Errorf("Expected 5 minute skew to warn, got %+v", result)
+	}
+}
+
+func TestCheckHeartbeatRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	doc := newTestDoctor(t, server.URL)
+	if result := doc.checkHeartbeat(); result.Status != StatusFail || result.Fix == "" {
+		t.Errorf("Expected rejected key to fail with a fix, got %+v", result)
+	}
+
+	doc.Offline = true
+	if result := doc.checkHeartbeat(); result.Status != StatusSkip {
+		t.Errorf("Expected offline mode to skip the round trip, got %+v", result)
+	}
+}
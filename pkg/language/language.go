@@ -0,0 +1,380 @@
+// Package language maps files to the language names WakaTime's servers
+// expect, from file names, extensions, shebangs and editor modelines.
+package language
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Language is one row of the language table
+type Language struct {
+	// Name is the canonical WakaTime language name
+	Name string
+
+	// Extensions, lowercase with the leading dot. Multi-part extensions
+	// such as ".d.ts" win over their last part.
+	Extensions []string
+
+	// Filenames are exact base names, such as "Justfile"
+	Filenames []string
+
+	// Interpreters are shebang commands, without version suffixes
+	Interpreters []string
+
+	// Modes are vim filetypes and Emacs modes naming the language, besides
+	// the lowercased Name
+	Modes []string
+}
+
+// Table lists every known language. Lookups are built from it once, so
+// adding a language is adding a row.
+var Table = []Language{
+	{Name: "Ada", Extensions: []string{".ada", ".adb", ".ads"}},
+	{Name: "Assembly", Extensions: []string{".asm", ".s", ".nasm"}, Modes: []string{"asm", "nasm"}},
+	{Name: "Astro", Extensions: []string{".astro"}},
+	{Name: "Awk", Extensions: []string{".awk"}, Interpreters: []string{"awk", "gawk", "mawk", "nawk"}},
+	{
+		Name:         "Bash",
+		Extensions:   []string{".sh", ".bash", ".zsh", ".ksh", ".bats", ".command"},
+		Filenames:    []string{".envrc", ".bashrc", ".bash_profile", ".bash_login", ".bash_logout", ".bash_aliases", ".profile", ".zshrc", ".zshenv", ".zprofile", ".zlogin", ".zlogout", "PKGBUILD", "APKBUILD"},
+		Interpreters: []string{"sh", "bash", "dash", "ash", "ksh", "mksh", "zsh", "bats"},
+		Modes:        []string{"sh", "zsh", "ksh", "shell-script"},
+	},
+	{Name: "Batchfile", Extensions: []string{".bat", ".cmd"}, Modes: []string{"dosbatch", "bat"}},
+	{Name: "C", Extensions: []string{".c", ".h"}},
+	{Name: "C#", Extensions: []string{".cs", ".csx"}, Modes: []string{"cs", "csharp"}},
+	{Name: "C++", Extensions: []string{".cpp", ".cc", ".cxx", ".c++", ".hpp", ".hh", ".hxx", ".h++", ".ipp", ".tpp"}, Modes: []string{"cpp", "c++"}},
+	{Name: "Clojure", Extensions: []string{".clj", ".cljs", ".cljc", ".edn", ".bb"}, Interpreters: []string{"bb", "clojure", "clj"}},
+	{Name: "CMake", Extensions: []string{".cmake"}, Filenames: []string{"CMakeLists.txt"}},
+	{Name: "Common Lisp", Extensions: []string{".lisp", ".cl", ".asd"}, Interpreters: []string{"sbcl", "clisp"}, Modes: []string{"lisp"}},
+	{Name: "Crystal", Extensions: []string{".cr"}, Interpreters: []string{"crystal"}},
+	{Name: "CSS", Extensions: []string{".css"}},
+	{Name: "CSV", Extensions: []string{".csv", ".tsv"}},
+	{Name: "CUDA", Extensions: []string{".cu", ".cuh"}},
+	{Name: "CUE", Extensions: []string{".cue"}},
+	{Name: "D", Extensions: []string{".d", ".di"}},
+	{Name: "Dart", Extensions: []string{".dart"}, Interpreters: []string{"dart"}},
+	{Name: "Dhall", Extensions: []string{".dhall"}},
+	{Name: "Diff", Extensions: []string{".diff", ".patch"}},
+	{Name: "Docker", Extensions: []string{".dockerfile"}, Filenames: []string{"Dockerfile", "dockerfile", "Containerfile"}, Modes: []string{"dockerfile"}},
+	{Name: "Elixir", Extensions: []string{".ex", ".exs", ".heex", ".leex"}, Interpreters: []string{"elixir"}},
+	{Name: "Elm", Extensions: []string{".elm"}},
+	{Name: "Emacs Lisp", Extensions: []string{".el"}, Filenames: []string{".emacs", "_emacs"}, Modes: []string{"emacs-lisp", "elisp", "lisp-interaction"}},
+	{Name: "Erlang", Extensions: []string{".erl", ".hrl", ".escript"}, Filenames: []string{"rebar.config"}, Interpreters: []string{"escript"}},
+	{Name: "F#", Extensions: []string{".fs", ".fsi", ".fsx"}, Modes: []string{"fsharp"}},
+	{Name: "Fish", Extensions: []string{".fish"}, Interpreters: []string{"fish"}},
+	{Name: "Fortran", Extensions: []string{".f", ".for", ".f90", ".f95", ".f03", ".f08"}},
+	{Name: "Gleam", Extensions: []string{".gleam"}},
+	{Name: "GLSL", Extensions: []string{".glsl", ".vert", ".frag", ".geom", ".comp"}},
+	{Name: "Go", Extensions: []string{".go"}, Filenames: []string{"go.mod", "go.sum", "go.work", "go.work.sum"}, Modes: []string{"golang"}},
+	{Name: "GraphQL", Extensions: []string{".graphql", ".gql", ".graphqls"}},
+	{Name: "Groovy", Extensions: []string{".groovy", ".gradle", ".gvy"}, Filenames: []string{"Jenkinsfile"}, Interpreters: []string{"groovy"}},
+	{Name: "Haml", Extensions: []string{".haml"}},
+	{Name: "Handlebars", Extensions: []string{".hbs", ".handlebars", ".mustache"}},
+	{Name: "Haskell", Extensions: []string{".hs", ".lhs", ".hs-boot"}, Interpreters: []string{"runhaskell", "runghc", "stack", "cabal"}},
+	{Name: "HCL", Extensions: []string{".hcl", ".nomad"}},
+	{Name: "HLSL", Extensions: []string{".hlsl", ".fx"}},
+	{Name: "HTML", Extensions: []string{".html", ".htm", ".xhtml"}},
+	{Name: "INI", Extensions: []string{".ini"}, Filenames: []string{".editorconfig", ".gitconfig", ".npmrc", "setup.cfg", "tox.ini"}, Modes: []string{"dosini", "conf-unix"}},
+	{Name: "Java", Extensions: []string{".java"}},
+	{Name: "JavaScript", Extensions: []string{".js", ".mjs", ".cjs"}, Filenames: []string{"Jakefile"}, Interpreters: []string{"node", "nodejs"}, Modes: []string{"js", "js2"}},
+	{Name: "JSON", Extensions: []string{".json", ".jsonc", ".json5", ".geojson", ".webmanifest"}, Filenames: []string{".babelrc", ".eslintrc", ".prettierrc", "flake.lock", "composer.lock"}},
+	{Name: "Jsonnet", Extensions: []string{".jsonnet", ".libsonnet"}},
+	{Name: "JSX", Extensions: []string{".jsx"}, Modes: []string{"javascriptreact", "rjsx"}},
+	{Name: "Julia", Extensions: []string{".jl"}, Interpreters: []string{"julia"}},
+	{Name: "Just", Extensions: []string{".just"}, Filenames: []string{"Justfile", "justfile", ".justfile"}, Interpreters: []string{"just"}},
+	{Name: "Kotlin", Extensions: []string{".kt", ".kts"}, Interpreters: []string{"kotlin"}},
+	{Name: "Less", Extensions: []string{".less"}},
+	{Name: "Liquid", Extensions: []string{".liquid"}},
+	{Name: "Lua", Extensions: []string{".lua", ".rockspec"}, Interpreters: []string{"lua", "luajit", "texlua"}},
+	{Name: "Makefile", Extensions: []string{".mk", ".mak", ".make"}, Filenames: []string{"Makefile", "makefile", "GNUmakefile", "BSDmakefile"}, Interpreters: []string{"make"}, Modes: []string{"make"}},
+	{Name: "Markdown", Extensions: []string{".md", ".markdown", ".mkd", ".mdown"}},
+	{Name: "MDX", Extensions: []string{".mdx"}},
+	{Name: "Meson", Filenames: []string{"meson.build", "meson_options.txt", "meson.options"}},
+	{Name: "Nim", Extensions: []string{".nim", ".nims", ".nimble"}},
+	{Name: "Ninja", Extensions: []string{".ninja"}},
+	{Name: "Nix", Extensions: []string{".nix"}},
+	{Name: "Objective-C", Extensions: []string{".m"}, Modes: []string{"objc"}},
+	{Name: "Objective-C++", Extensions: []string{".mm"}, Modes: []string{"objcpp"}},
+	{Name: "OCaml", Extensions: []string{".ml", ".mli", ".mll", ".mly"}, Interpreters: []string{"ocaml"}, Modes: []string{"tuareg"}},
+	{Name: "Odin", Extensions: []string{".odin"}},
+	{Name: "Perl", Extensions: []string{".pl", ".pm", ".t", ".pod"}, Interpreters: []string{"perl"}, Modes: []string{"cperl"}},
+	{Name: "PHP", Extensions: []string{".php", ".phtml"}, Interpreters: []string{"php"}},
+	{Name: "PowerShell", Extensions: []string{".ps1", ".psm1", ".psd1"}, Interpreters: []string{"pwsh", "powershell"}, Modes: []string{"ps1"}},
+	{Name: "Prisma", Extensions: []string{".prisma"}},
+	{Name: "Protocol Buffer", Extensions: []string{".proto"}, Modes: []string{"proto", "protobuf"}},
+	{Name: "Pug", Extensions: []string{".pug", ".jade"}},
+	{Name: "PureScript", Extensions: []string{".purs"}},
+	{
+		Name:         "Python",
+		Extensions:   []string{".py", ".pyw", ".pyi", ".pyx", ".pxd", ".gyp"},
+		Filenames:    []string{"SConstruct", "SConscript", "wscript"},
+		Interpreters: []string{"python", "pypy", "uv"},
+	},
+	{Name: "R", Extensions: []string{".r", ".rmd"}, Filenames: []string{".Rprofile"}, Interpreters: []string{"Rscript"}},
+	{Name: "Racket", Extensions: []string{".rkt"}, Interpreters: []string{"racket"}},
+	{Name: "reStructuredText", Extensions: []string{".rst", ".rest"}, Modes: []string{"rst"}},
+	{
+		Name:         "Ruby",
+		Extensions:   []string{".rb", ".rake", ".gemspec", ".ru", ".rbw", ".podspec"},
+		Filenames:    []string{"Gemfile", "Rakefile", "Podfile", "Vagrantfile", "Brewfile", "Guardfile", "Fastfile", "Appfile", "Dangerfile", ".irbrc", ".pryrc"},
+		Interpreters: []string{"ruby", "jruby", "macruby"},
+	},
+	{Name: "Rust", Extensions: []string{".rs"}, Interpreters: []string{"rust-script"}},
+	{Name: "Sass", Extensions: []string{".sass"}},
+	{Name: "Scala", Extensions: []string{".scala", ".sc", ".sbt"}, Interpreters: []string{"scala", "amm", "scala-cli"}},
+	{Name: "Scheme", Extensions: []string{".scm", ".ss", ".sld"}, Interpreters: []string{"guile", "chicken", "csi"}},
+	{Name: "SCSS", Extensions: []string{".scss"}},
+	{Name: "Slim", Extensions: []string{".slim"}},
+	{Name: "Solidity", Extensions: []string{".sol"}},
+	{Name: "SQL", Extensions: []string{".sql", ".ddl", ".psql"}, Modes: []string{"plsql", "mysql", "pgsql"}},
+	{
+		Name:       "Starlark",
+		Extensions: []string{".bzl", ".bazel", ".star", ".sky"},
+		Filenames:  []string{"BUILD", "WORKSPACE", "MODULE.bazel", "Tiltfile"},
+		Modes:      []string{"bzl", "bazel"},
+	},
+	{Name: "Svelte", Extensions: []string{".svelte"}},
+	{Name: "Swift", Extensions: []string{".swift"}, Interpreters: []string{"swift"}},
+	{Name: "Tcl", Extensions: []string{".tcl", ".tk"}, Interpreters: []string{"tclsh", "wish", "expect"}},
+	{Name: "Terraform", Extensions: []string{".tf", ".tfvars", ".tftest.hcl"}},
+	{Name: "TeX", Extensions: []string{".tex", ".sty", ".cls", ".ltx", ".bib"}, Modes: []string{"latex", "plaintex"}},
+	{Name: "Text", Extensions: []string{".txt", ".text"}, Filenames: []string{"LICENSE", "COPYING", "AUTHORS"}, Modes: []string{"text"}},
+	{Name: "TOML", Extensions: []string{".toml"}, Filenames: []string{"Cargo.lock", "Pipfile", "poetry.lock", "uv.lock", "Gopkg.lock"}},
+	{Name: "TSX", Extensions: []string{".tsx"}, Modes: []string{"typescriptreact"}},
+	{Name: "Twig", Extensions: []string{".twig"}},
+	{Name: "TypeScript", Extensions: []string{".ts", ".mts", ".cts", ".d.ts"}, Interpreters: []string{"deno", "ts-node", "tsx", "bun"}, Modes: []string{"ts"}},
+	{Name: "Typst", Extensions: []string{".typ"}},
+	{Name: "VHDL", Extensions: []string{".vhd", ".vhdl"}},
+	{Name: "VimL", Extensions: []string{".vim"}, Filenames: []string{".vimrc", "_vimrc", ".gvimrc", ".exrc"}, Modes: []string{"vim"}},
+	{Name: "Vue.js", Extensions: []string{".vue"}, Modes: []string{"vue"}},
+	{Name: "WebAssembly", Extensions: []string{".wat", ".wast"}, Modes: []string{"wat", "wast"}},
+	{Name: "XML", Extensions: []string{".xml", ".xsd", ".xsl", ".xslt", ".plist", ".csproj", ".fsproj", ".vbproj", ".props", ".targets", ".xaml"}, Filenames: []string{"pom.xml"}},
+	{Name: "YAML", Extensions: []string{".yaml", ".yml"}, Filenames: []string{".clang-format", ".clang-tidy", ".gemrc"}},
+	{Name: "Zig", Extensions: []string{".zig", ".zon"}},
+}
+
+var (
+	byExtension   = map[string]string{}
+	byFilename    = map[string]string{}
+	byInterpreter = map[string]string{}
+	byMode        = map[string]string{}
+)
+
+func init() {
+	for _, language := range Table {
+		for _, ext := range language.Extensions {
+			byExtension[ext] = language.Name
+		}
+		for _, name := range language.Filenames {
+			byFilename[name] = language.Name
+		}
+		for _, interpreter := range language.Interpreters {
+			byInterpreter[interpreter] = language.Name
+		}
+
+		byMode[strings.ToLower(language.Name)] = language.Name
+		for _, mode := range language.Modes {
+			byMode[mode] = language.Name
+		}
+	}
+}
+
+// How much of a file is read when sniffing its content
+const (
+	sniffHead  = 4096
+	sniffTail  = 1024
+	sniffLines = 5
+)
+
+// Detect returns the WakaTime language of the file at path, or "" if it
+// isn't known. File names and extensions are tried first; otherwise the
+// start and end of the file are read for a shebang or modeline.
+func Detect(path string) string {
+	if language := DetectFilename(path); language != "" {
+		return language
+	}
+
+	head, tail := readEnds(path)
+	if head == nil {
+		return ""
+	}
+
+	return detectContent(head, tail)
+}
+
+// DetectFilename returns the language implied by path's base name or
+// extension alone, without reading the file
+func DetectFilename(path string) string {
+	base := filepath.Base(path)
+
+	if language, ok := byFilename[base]; ok {
+		return language
+	}
+
+	// Dockerfile.dev, Makefile.linux, ...
+	if prefix, _, ok := strings.Cut(base, "."); ok && prefix != "" {
+		if language, ok := byFilename[prefix]; ok && (language == "Docker" || language == "Makefile") {
+			return language
+		}
+	}
+
+	// Try every suffix from the longest, so .d.ts and .tftest.hcl win
+	lower := strings.ToLower(base)
+	for i := 1; i < len(lower); i++ {
+		if lower[i] != '.' {
+			continue
+		}
+		if language, ok := byExtension[lower[i:]]; ok {
+			return language
+		}
+	}
+
+	return ""
+}
+
+// DetectContent returns the language named by a shebang or an editor
+// modeline at the start or end of content, or "" if there is none
+func DetectContent(content []byte) string {
+	return detectContent(content, content)
+}
+
+func detectContent(head, tail []byte) string {
+	headLines := firstLines(head, sniffLines)
+	if len(headLines) > 0 {
+		if language := Shebang(headLines[0]); language != "" {
+			return language
+		}
+	}
+
+	for _, line := range append(headLines, lastLines(tail, sniffLines)...) {
+		if language := modeline(line); language != "" {
+			return language
+		}
+	}
+
+	return ""
+}
+
+// Interpreters with a version suffix: python3.12, perl5, ruby2.7
+var versionSuffix = regexp.MustCompile(`[0-9][0-9.]*$`)
+
+// Shebang returns the language of a `#!` line's interpreter, looking past
+// env and its options
+func Shebang(line string) string {
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			// env -S, env -i, env FOO=bar
+			if strings.HasPrefix(field, "-") || strings.Contains(field, "=") {
+				continue
+			}
+			interpreter = filepath.Base(field)
+			break
+		}
+	}
+
+	if language, ok := byInterpreter[interpreter]; ok {
+		return language
+	}
+
+	return byInterpreter[versionSuffix.ReplaceAllString(interpreter, "")]
+}
+
+var (
+	// vim: set ft=python :   vi:filetype=sh   ex: syntax=ruby
+	vimModeline = regexp.MustCompile(`(?:^|\s)(?:vim?|ex):.*?\b(?:ft|filetype|syntax)=([\w+#-]+)`)
+
+	// -*- mode: python -*-   -*- python -*-
+	emacsModeline = regexp.MustCompile(`-\*-\s*(?:.*?\bmode:\s*([\w+#-]+)|([\w+#-]+)\s*-\*-)`)
+)
+
+// modeline returns the language named by a vim or Emacs modeline
+func modeline(line string) string {
+	var mode string
+	if match := vimModeline.FindStringSubmatch(line); match != nil {
+		mode = match[1]
+	} else if match := emacsModeline.FindStringSubmatch(line); match != nil {
+		mode = match[1] + match[2]
+	}
+
+	mode = strings.TrimSuffix(strings.ToLower(mode), "-mode")
+	if mode == "" {
+		return ""
+	}
+
+	return byMode[mode]
+}
+
+// readEnds returns the first sniffHead and last sniffTail bytes of the
+// file, or a nil head if it can't be read
+func readEnds(path string) ([]byte, []byte) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil
+	}
+	defer file.Close()
+
+	head := make([]byte, sniffHead)
+	n, err := io.ReadFull(file, head)
+	if n == 0 || (err != nil && err != io.ErrUnexpectedEOF) {
+		return nil, nil
+	}
+	head = head[:n]
+
+	// Binary files have no shebang worth trusting
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+
+	// The whole file fit, so its end is in head too
+	if n < sniffHead {
+		return head, head
+	}
+
+	info, err := file.Stat()
+	if err != nil || info.Size() <= sniffHead {
+		return head, head
+	}
+
+	tail := make([]byte, sniffTail)
+	n, _ = file.ReadAt(tail, info.Size()-sniffTail)
+	return head, tail[:n]
+}
+
+func firstLines(content []byte, n int) []string {
+	lines := strings.SplitN(string(content), "\n", n+1)
+	if len(lines) > n {
+		lines = lines[:n]
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	return lines
+}
+
+func lastLines(content []byte, n int) []string {
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	return lines
+}
@@ -0,0 +1,186 @@
+package language
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectFilename(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"main.go", "Go"},
+		{"/src/app/index.JS", "JavaScript"},
+		{"component.jsx", "JSX"},
+		{"component.tsx", "TSX"},
+		{"types.d.ts", "TypeScript"},
+		{"header.h", "C"},
+		{"vector.hpp", "C++"},
+		{"go.mod", "Go"},
+		{"Cargo.lock", "TOML"},
+		{"Main.kt", "Kotlin"},
+		{"App.swift", "Swift"},
+		{"build.zig", "Zig"},
+		{"lib/app.ex", "Elixir"},
+		{"Main.hs", "Haskell"},
+		{"init.lua", "Lua"},
+		{"flake.nix", "Nix"},
+		{"main.tf", "Terraform"},
+		{"App.vue", "Vue.js"},
+		{"Button.svelte", "Svelte"},
+		{"script.sh", "Bash"},
+		{"config.fish", "Fish"},
+		{"README.md", "Markdown"},
+		{"styles.scss", "SCSS"},
+		{"styles.sass", "Sass"},
+		{"Dockerfile", "Docker"},
+		{"Dockerfile.dev", "Docker"},
+		{"Makefile", "Makefile"},
+		{"Justfile", "Just"},
+		{"justfile", "Just"},
+		{"BUILD.bazel", "Starlark"},
+		{"BUILD", "Starlark"},
+		{"defs.bzl", "Starlark"},
+		{".envrc", "Bash"},
+		{".zshrc", "Bash"},
+		{"Gemfile", "Ruby"},
+		{"CMakeLists.txt", "CMake"},
+		{"notes.txt", "Text"},
+		{".vimrc", "VimL"},
+		{"schema.proto", "Protocol Buffer"},
+		{"build", ""},
+		{"unknown.xyz", ""},
+		{"noextension", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if result := DetectFilename(tt.path); result != tt.expected {
+				t.Errorf("DetectFilename(%q) = %q, expected %q", tt.path, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestShebang(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"#!/bin/bash", "Bash"},
+		{"#!/bin/sh -e", "Bash"},
+		{"#! /usr/bin/env python3", "Python"},
+		{"#!/usr/bin/python3.12", "Python"},
+		{"#!/usr/bin/env -S deno run --allow-net", "TypeScript"},
+		{"#!/usr/bin/env -S uv run --script", "Python"},
+		{"#!/usr/bin/env LANG=C perl -w", "Perl"},
+		{"#!/usr/bin/env node", "JavaScript"},
+		{"#!/usr/local/bin/ruby2.7", "Ruby"},
+		{"#!/usr/bin/env fish", "Fish"},
+		{"#!/usr/bin/make -f", "Makefile"},
+		{"#!/usr/bin/env unknown-interpreter", ""},
+		{"#!", ""},
+		{"# not a shebang", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if result := Shebang(tt.line); result != tt.expected {
+				t.Errorf("Shebang(%q) = %q, expected %q", tt.line, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDetectContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"shebang", "#!/usr/bin/env python\nprint('hi')\n", "Python"},
+		{"vim modeline at the end", "some text\nmore\n# vim: set ts=4 sw=4 ft=ruby :\n", "Ruby"},
+		{"vim filetype", "// vi:filetype=javascript\n", "JavaScript"},
+		{"emacs mode", "# -*- mode: sh; indent-tabs-mode: nil -*-\necho hi\n", "Bash"},
+		{"emacs short form", ";; -*- emacs-lisp -*-\n", "Emacs Lisp"},
+		{"emacs coding only", "# -*- coding: utf-8 -*-\n", ""},
+		{"shebang wins", "#!/bin/bash\n# vim: ft=python\n", "Bash"},
+		{"plain text", "hello\nworld\n", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := DetectContent([]byte(tt.content)); result != tt.expected {
+				t.Errorf("DetectContent(%q) = %q, expected %q", tt.content, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"extension without reading", filepath.Join(dir, "missing.go"), "Go"},
+		{"extensionless script", write("deploy", "#!/usr/bin/env bash\nset -e\n"), "Bash"},
+		{"unknown extension", write("server.cgi", "#!/usr/bin/perl\n"), "Perl"},
+		{"modeline past the head", write("notes", strings.Repeat("x\n", sniffHead)+"# vim: ft=python\n"), "Python"},
+		{"binary", write("blob", "\x00\x01#!/bin/sh"), ""},
+		{"missing extensionless", filepath.Join(dir, "nope"), ""},
+		{"directory", dir, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := Detect(tt.path); result != tt.expected {
+				t.Errorf("Detect(%q) = %q, expected %q", tt.path, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTableHasNoConflicts(t *testing.T) {
+	seen := map[string]string{}
+
+	for _, language := range Table {
+		for _, ext := range language.Extensions {
+			if ext != strings.ToLower(ext) || !strings.HasPrefix(ext, ".") {
+				t.Errorf("%s: extension %q should be lowercase with a leading dot", language.Name, ext)
+			}
+			if other, ok := seen["ext "+ext]; ok {
+				t.Errorf("Extension %q is listed for both %s and %s", ext, other, language.Name)
+			}
+			seen["ext "+ext] = language.Name
+		}
+
+		for _, name := range language.Filenames {
+			if other, ok := seen["file "+name]; ok {
+				t.Errorf("Filename %q is listed for both %s and %s", name, other, language.Name)
+			}
+			seen["file "+name] = language.Name
+		}
+
+		for _, interpreter := range language.Interpreters {
+			if other, ok := seen["interpreter "+interpreter]; ok {
+				t.Errorf("Interpreter %q is listed for both %s and %s", interpreter, other, language.Name)
+			}
+			seen["interpreter "+interpreter] = language.Name
+		}
+	}
+}
@@ -12,13 +12,38 @@ import (
 	"github.com/hackclub/terminal-wakatime/pkg/config"
 	"github.com/hackclub/terminal-wakatime/pkg/tracker"
 	"github.com/hackclub/terminal-wakatime/pkg/updater"
+	"github.com/hackclub/terminal-wakatime/pkg/wakatime"
 )
 
+// CommandLogFile is the debug log of tracked commands in the WakaTime directory
+const CommandLogFile = "commands.log"
+
+// Release note highlights shown after a self-update
+const changelogSummaryLines = 3
+
 type Monitor struct {
-	config  *config.Config
-	tracker *tracker.Tracker
-	updater *updater.Updater
-	logFile string
+	config    *config.Config
+	tracker   *tracker.Tracker
+	updater   *updater.Updater
+	managedBy string
+	logFile   string
+}
+
+// Status is the stable, machine-readable output of the status command
+type Status struct {
+	TodayTime            string     `json:"today_time"`
+	LastHeartbeat        *time.Time `json:"last_heartbeat"`
+	LastEntity           string     `json:"last_entity"`
+	Project              string     `json:"project"`
+	Branch               string     `json:"branch"`
+	QueueSize            int        `json:"queue_size"`
+	WakaTimeCLIInstalled bool       `json:"wakatime_cli_installed"`
+	WakaTimeCLIVersion   string     `json:"wakatime_cli_version"`
+	PluginVersion        string     `json:"plugin_version"`
+	APIKeyConfigured     bool       `json:"api_key_configured"`
+	DebugEnabled         bool       `json:"debug_enabled"`
+	HeartbeatFrequency   string     `json:"heartbeat_frequency"`
+	RecentCommands       int        `json:"recent_commands"`
 }
 
 type CommandEvent struct {
@@ -29,27 +54,37 @@ type CommandEvent struct {
 }
 
 func NewMonitor(cfg *config.Config) *Monitor {
-	logFile := filepath.Join(cfg.WakaTimeDir(), "commands.log")
+	logFile := filepath.Join(cfg.WakaTimeDir(), CommandLogFile)
 
 	// Get current binary path for updater
 	binaryPath, _ := os.Executable()
 	upd := updater.NewUpdater(cfg.PluginVersion(), cfg.WakaTimeDir(), binaryPath)
+	upd.SetReleasesURL(cfg.UpdateReleasesURL)
+	if client, err := cfg.HTTPClient(0); err == nil {
+		upd.SetHTTPClient(client)
+	}
+	upd.SetIncludePrereleases(cfg.UpdateChannel == config.UpdateChannelPrerelease)
+
+	// A package manager owns its binary; only tell the user about updates
+	managedBy := updater.ManagedBy(binaryPath)
+	upd.SetNotifyOnly(cfg.UpdateNotifyOnly || managedBy != "")
 
 	return &Monitor{
-		config:  cfg,
-		tracker: tracker.NewTracker(cfg),
-		updater: upd,
-		logFile: logFile,
+		config:    cfg,
+		tracker:   tracker.NewTracker(cfg),
+		updater:   upd,
+		managedBy: managedBy,
+		logFile:   logFile,
 	}
 }
 
 func (m *Monitor) ProcessCommand(command string, duration time.Duration, workingDir string) error {
 	// Check for pending update notifications (show once then clear)
 	m.checkAndShowUpdateNotification()
+	m.checkAndShowAvailableUpdate()
 
 	// Check for updates in background (non-blocking)
-	// Skip updates if disabled via environment variable (useful for tests)
-	if os.Getenv("TERMINAL_WAKATIME_DISABLE_UPDATES") == "" {
+	if m.updatesEnabled() {
 		go m.updater.CheckAndUpdate()
 	}
 
@@ -65,6 +100,12 @@ func (m *Monitor) ProcessCommand(command string, duration time.Duration, working
 	return m.tracker.TrackCommand(command, workingDir)
 }
 
+// SetGitSnapshot passes on the repository state the shell hooks captured
+// before the next command ran
+func (m *Monitor) SetGitSnapshot(snapshot *tracker.GitSnapshot) {
+	m.tracker.SetGitSnapshot(snapshot)
+}
+
 // checkAndShowUpdateNotification checks for pending update notifications and shows them
 func (m *Monitor) checkAndShowUpdateNotification() {
 	updateInfo, err := m.updater.GetPendingUpdateInfo()
@@ -73,14 +114,62 @@ func (m *Monitor) checkAndShowUpdateNotification() {
 	}
 
 	// Show the notification
-	fmt.Fprintf(os.Stderr, "\n🚀 FYI! terminal-wakatime here. I self-updated from %s to %s.\n\n",
+	fmt.Fprintf(os.Stderr, "\n🚀 FYI! terminal-wakatime here. I self-updated from %s to %s.\n",
 		updateInfo.FromVersion, updateInfo.ToVersion)
+	m.showChangelogSummary(updateInfo.ToVersion)
+	fmt.Fprintln(os.Stderr)
 
 	// Clear the notification (it's shown once)
 	m.updater.ClearPendingUpdateInfo()
 }
 
-func (m *Monitor) ProcessFileEdit(filePath string, isWrite bool) error {
+// showChangelogSummary prints the highlights of the release notes stored
+// for the update to version
+func (m *Monitor) showChangelogSummary(version string) {
+	changelog, err := m.updater.GetChangelog()
+	if err != nil || changelog == nil || changelog.ToVersion != version {
+		return
+	}
+
+	highlights, more := changelog.Summary(changelogSummaryLines)
+	if len(highlights) == 0 {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "   What's new:")
+	for _, line := range highlights {
+		fmt.Fprintf(os.Stderr, "   • %s\n", line)
+	}
+	if more > 0 {
+		fmt.Fprintf(os.Stderr, "   …and %d more\n", more)
+	}
+	fmt.Fprintln(os.Stderr, "   Full release notes: terminal-wakatime changelog")
+}
+
+// updatesEnabled reports whether background update checks run. They can
+// also be disabled via environment variable (useful for tests).
+func (m *Monitor) updatesEnabled() bool {
+	return m.config.UpdateChannel != config.UpdateChannelOff &&
+		os.Getenv("TERMINAL_WAKATIME_DISABLE_UPDATES") == ""
+}
+
+// checkAndShowAvailableUpdate announces a newer version found in
+// notify-only mode, once per version
+func (m *Monitor) checkAndShowAvailableUpdate() {
+	available, err := m.updater.GetAvailableUpdate()
+	if err != nil || available == nil {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "\n💡 terminal-wakatime %s is available (you have %s). Update with: %s\n\n",
+		available.Version, m.config.PluginVersion(), updater.UpgradeCommand(m.managedBy))
+
+	m.updater.MarkAvailableUpdateNotified()
+}
+
+// ProcessFileEdit tracks an edit of filePath by editor, which may be "" if
+// it isn't known
+func (m *Monitor) ProcessFileEdit(editor, filePath string, isWrite bool) error {
 	// Ensure absolute path
 	if !filepath.IsAbs(filePath) {
 		wd, err := os.Getwd()
@@ -90,7 +179,7 @@ func (m *Monitor) ProcessFileEdit(filePath string, isWrite bool) error {
 		filePath = filepath.Join(wd, filePath)
 	}
 
-	return m.tracker.TrackFile(filePath, isWrite)
+	return m.tracker.TrackFile(editor, filePath, isWrite)
 }
 
 func (m *Monitor) logCommand(command string, duration time.Duration, workingDir string) {
@@ -175,22 +264,48 @@ func (m *Monitor) parseLogLine(line string) (CommandEvent, error) {
 	}, nil
 }
 
-func (m *Monitor) GetStatus() (map[string]interface{}, error) {
-	status := make(map[string]interface{})
+// GetStatus collects the current state of the integration. Fields that can't
+// be determined (e.g. wakatime-cli is missing or offline) are left empty.
+func (m *Monitor) GetStatus() (*Status, error) {
+	cli := wakatime.NewCLI(m.config)
+
+	status := &Status{
+		PluginVersion:        m.config.PluginVersion(),
+		WakaTimeCLIInstalled: cli.IsInstalled(),
+		APIKeyConfigured:     m.config.APIKey != "",
+		DebugEnabled:         m.config.Debug,
+		HeartbeatFrequency:   m.config.HeartbeatFrequency.String(),
+	}
 
 	// Get recent activity count
-	recentCommands, err := m.GetRecentCommands(10)
-	if err == nil {
-		status["recent_commands"] = len(recentCommands)
+	if recentCommands, err := m.GetRecentCommands(10); err == nil {
+		status.RecentCommands = len(recentCommands)
+	}
+
+	if wd, err := os.Getwd(); err == nil {
+		status.Project, status.Branch = m.tracker.ProjectForDir(wd)
 	}
 
-	// Check if wakatime CLI is installed
-	status["wakatime_cli_installed"] = m.tracker != nil
+	if last, err := tracker.LoadLastHeartbeat(m.config); err == nil && last != nil {
+		status.LastHeartbeat = &last.Time
+		status.LastEntity = last.Entity
+	}
 
-	// Get configuration status
-	status["api_key_configured"] = m.config.APIKey != ""
-	status["debug_enabled"] = m.config.Debug
-	status["heartbeat_frequency"] = m.config.HeartbeatFrequency.String()
+	if status.WakaTimeCLIInstalled {
+		if version, err := cli.Version(); err == nil {
+			status.WakaTimeCLIVersion = version
+		}
+
+		if count, err := cli.OfflineCount(); err == nil {
+			status.QueueSize = count
+		}
+
+		if status.APIKeyConfigured {
+			if today, err := cli.Today(); err == nil {
+				status.TodayTime = today
+			}
+		}
+	}
 
 	return status, nil
 }
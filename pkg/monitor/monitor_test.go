@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
@@ -60,7 +61,7 @@ func TestProcessFileEdit(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	err := monitor.ProcessFileEdit(testFile, false)
+	err := monitor.ProcessFileEdit("", testFile, false)
 	if err == nil {
 		t.Log("ProcessFileEdit succeeded (expected in test environment)")
 	} else {
@@ -181,31 +182,55 @@ func TestGetStatus(t *testing.T) {
 		t.Errorf("GetStatus failed: %v", err)
 	}
 
-	// Check expected status fields
+	if !status.APIKeyConfigured {
+		t.Error("Expected APIKeyConfigured to be true")
+	}
+
+	if !status.DebugEnabled {
+		t.Error("Expected DebugEnabled to be true")
+	}
+
+	if status.HeartbeatFrequency != "2m0s" {
+		t.Errorf("Expected HeartbeatFrequency to be '2m0s', got '%v'", status.HeartbeatFrequency)
+	}
+
+	if status.PluginVersion != config.PluginVersion {
+		t.Errorf("Expected PluginVersion %q, got %q", config.PluginVersion, status.PluginVersion)
+	}
+
+	if status.LastHeartbeat != nil {
+		t.Errorf("Expected no last heartbeat, got %v", status.LastHeartbeat)
+	}
+
+	// The JSON schema is relied on by scripts, so keys must stay stable
+	data, err := json.Marshal(status)
+	if err != nil {
+		t.Fatalf("Failed to marshal status: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal status: %v", err)
+	}
+
 	expectedFields := []string{
+		"today_time",
+		"last_heartbeat",
+		"project",
+		"branch",
+		"queue_size",
+		"wakatime_cli_version",
+		"plugin_version",
 		"api_key_configured",
 		"debug_enabled",
 		"heartbeat_frequency",
 	}
 
 	for _, field := range expectedFields {
-		if _, exists := status[field]; !exists {
+		if _, exists := decoded[field]; !exists {
 			t.Errorf("Expected status field '%s' to be present", field)
 		}
 	}
-
-	// Check values
-	if status["api_key_configured"] != true {
-		t.Error("Expected api_key_configured to be true")
-	}
-
-	if status["debug_enabled"] != true {
-		t.Error("Expected debug_enabled to be true")
-	}
-
-	if status["heartbeat_frequency"] != "2m0s" {
-		t.Errorf("Expected heartbeat_frequency to be '2m0s', got '%v'", status["heartbeat_frequency"])
-	}
 }
 
 func TestLogCommand(t *testing.T) {
@@ -98,3 +98,82 @@ func TestMonitor_BackgroundUpdateCheck(t *testing.T) {
 	// without errors. We can't check file creation reliably since the background
 	// check might fail due to network or other issues in test environment
 }
+
+func TestMonitor_UpdateChannelOff(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	cfg := &config.Config{UpdateChannel: config.UpdateChannelStable}
+	monitor := NewMonitor(cfg)
+
+	if os.Getenv("TERMINAL_WAKATIME_DISABLE_UPDATES") == "" && !monitor.updatesEnabled() {
+		t.Error("Expected updates on the stable channel")
+	}
+
+	cfg.UpdateChannel = config.UpdateChannelOff
+	if monitor.updatesEnabled() {
+		t.Error("Expected no background updates with update_channel = off")
+	}
+}
+
+func TestMonitor_AvailableUpdateNotification(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	wakatimeDir := filepath.Join(tempDir, ".wakatime")
+	if err := os.MkdirAll(wakatimeDir, 0755); err != nil {
+		t.Fatalf("Failed to create wakatime dir: %v", err)
+	}
+
+	monitor := NewMonitor(&config.Config{})
+	monitor.updater = updater.NewUpdater("v0.0.1", wakatimeDir, "/fake/binary")
+
+	available := `{"version":"v0.0.2","notified":false}`
+	if err := os.WriteFile(filepath.Join(wakatimeDir, updater.AvailableUpdateFile), []byte(available), 0644); err != nil {
+		t.Fatalf("Failed to write available update: %v", err)
+	}
+
+	monitor.checkAndShowAvailableUpdate()
+
+	if pending, _ := monitor.updater.GetAvailableUpdate(); pending != nil {
+		t.Errorf("Expected the announcement to be shown once, still pending: %+v", pending)
+	}
+}
+
+func TestMonitor_UpdateNotificationKeepsChangelog(t *testing.T) {
+	tempDir := t.TempDir()
+	wakatimeDir := filepath.Join(tempDir, ".wakatime")
+	if err := os.MkdirAll(wakatimeDir, 0755); err != nil {
+		t.Fatalf("Failed to create wakatime dir: %v", err)
+	}
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	monitor := NewMonitor(&config.Config{})
+	monitor.updater = updater.NewUpdater("v0.0.1", wakatimeDir, "/fake/binary")
+
+	if err := monitor.updater.SaveUpdateInfo(updater.UpdateInfo{FromVersion: "v0.0.1", ToVersion: "v0.0.2"}); err != nil {
+		t.Fatalf("Failed to save update info: %v", err)
+	}
+	changelog := `{"from_version":"v0.0.1","to_version":"v0.0.2","releases":[{"version":"v0.0.2","body":"- Track jj"}]}`
+	if err := os.WriteFile(filepath.Join(wakatimeDir, updater.ChangelogFile), []byte(changelog), 0644); err != nil {
+		t.Fatalf("Failed to write changelog: %v", err)
+	}
+
+	monitor.checkAndShowUpdateNotification()
+
+	if pending, _ := monitor.updater.GetPendingUpdateInfo(); pending != nil {
+		t.Error("Expected the update summary to be shown once")
+	}
+
+	// The full notes stay available to `terminal-wakatime changelog`
+	if stored, err := monitor.updater.GetChangelog(); err != nil || stored == nil {
+		t.Errorf("Expected the changelog to be kept, got %v (%v)", stored, err)
+	}
+}
@@ -16,12 +16,74 @@ const (
 	Fish Shell = "fish"
 )
 
+// HookBinEnv is exported by the hooks so child processes (like doctor) can
+// tell that the integration is loaded and which binary it calls
+const HookBinEnv = "__TERMINAL_WAKATIME_BIN"
+
 type Integration struct {
 	shell          Shell
 	binPath        string
 	enableTiming   bool
 	enableDetails  bool
 	minCommandTime int
+	watchEditors   []string
+	sshForward     bool
+}
+
+// SetWatchEditors makes the hooks start a project watcher alongside each
+// command named in editors and stop it when the editor exits. Other
+// commands start nothing. No editors turns watching off.
+func (i *Integration) SetWatchEditors(editors []string) {
+	i.watchEditors = editors
+}
+
+// SetSSHForward makes the hooks define an ssh function that runs ssh
+// through terminal-wakatime, so remote heartbeats are forwarded back
+func (i *Integration) SetSSHForward(enabled bool) {
+	i.sshForward = enabled
+}
+
+// gitCapture records HEAD and its upstream before git commands, so the
+// tracker can tell what a commit, push or merge changed
+const gitCapture = `
+        case "$1" in
+            git\ *) export __TERMINAL_WAKATIME_GIT_BEFORE="$(git rev-parse -q --verify 'HEAD^{commit}' 2>/dev/null || echo -) $(git rev-parse -q --verify '@{u}^{commit}' 2>/dev/null || echo -)" ;;
+        esac`
+
+// watchHooks returns the bash/zsh lines that start the watcher for editor
+// commands and stop it once one exits, or empty strings when watching is off
+func (i *Integration) watchHooks() (string, string) {
+	if len(i.watchEditors) == 0 {
+		return "", ""
+	}
+
+	start := fmt.Sprintf(`
+        local editor="${1%%%% *}"
+        case "${editor##*/}" in
+            %s)
+                __TERMINAL_WAKATIME_WATCHING=1
+                ("%s" watch --command "$1" --pwd "$PWD" --shell-pid $$ >/dev/null 2>&1 &) ;;
+        esac`, strings.Join(i.watchEditors, "|"), i.binPath)
+	stop := fmt.Sprintf(`
+        if [ -n "$__TERMINAL_WAKATIME_WATCHING" ]; then
+            unset __TERMINAL_WAKATIME_WATCHING
+            ("%s" watch --stop --shell-pid $$ >/dev/null 2>&1 &)
+        fi`, i.binPath)
+
+	return start, stop
+}
+
+// sshWrapper returns the bash/zsh ssh function, or "" when forwarding is
+// off
+func (i *Integration) sshWrapper() string {
+	if !i.sshForward {
+		return ""
+	}
+
+	return fmt.Sprintf(`
+ssh() {
+    "%s" ssh "$@"
+}`, i.binPath)
 }
 
 func NewIntegration(binPath string) *Integration {
@@ -161,68 +223,153 @@ func (i *Integration) GenerateHooks() string {
 }
 
 func (i *Integration) generateBashHooks() string {
+	watchStart, watchStop := i.watchHooks()
+
 	preExec := fmt.Sprintf(`
 __terminal_wakatime_preexec() {
     if [ -n "$1" ]; then
         export __TERMINAL_WAKATIME_COMMAND="$1"
         export __TERMINAL_WAKATIME_START_TIME="$(date +%%s)"
-        export __TERMINAL_WAKATIME_PWD="$PWD"
+        export __TERMINAL_WAKATIME_PWD="$PWD"%s
     fi
-}`)
+}`, gitCapture+watchStart)
 
 	postExec := fmt.Sprintf(`
 __terminal_wakatime_postexec() {
+    # Disarm the DEBUG trap so the rest of PROMPT_COMMAND isn't treated as user input
+    unset __terminal_wakatime_preexec_armed
+
     if [ -n "$__TERMINAL_WAKATIME_COMMAND" ]; then
         local end_time="$(date +%%s)"
         local duration=$((end_time - __TERMINAL_WAKATIME_START_TIME))
         local command="$__TERMINAL_WAKATIME_COMMAND"
         local pwd="$__TERMINAL_WAKATIME_PWD"
+        local git_before="$__TERMINAL_WAKATIME_GIT_BEFORE"
         
         # Clear variables immediately
         unset __TERMINAL_WAKATIME_COMMAND
         unset __TERMINAL_WAKATIME_START_TIME
         unset __TERMINAL_WAKATIME_PWD
-        
+        unset __TERMINAL_WAKATIME_GIT_BEFORE
+        %s
         # Only track commands that run for a minimum duration
         if [ "$duration" -ge %d ]; then
-            ("%s" track --command "$command" --duration "$duration" --pwd "$pwd" >/dev/null 2>&1 &)
+            ("%s" track --command "$command" --duration "$duration" --pwd "$pwd" --git-before "$git_before" >/dev/null 2>&1 &)
         fi
     fi
-}`, i.minCommandTime, i.binPath)
+}`, watchStop, i.minCommandTime, i.binPath)
 
+	// PROMPT_COMMAND may be an array since bash 5.1; postexec has to run first
+	// so the timing isn't skewed by other prompt hooks
 	promptCommand := `
-if [[ "$PROMPT_COMMAND" != *"__terminal_wakatime_postexec"* ]]; then
-    PROMPT_COMMAND="__terminal_wakatime_postexec; $PROMPT_COMMAND"
+if [[ "$(declare -p PROMPT_COMMAND 2>/dev/null)" == "declare -a"* ]]; then
+    if [[ " ${PROMPT_COMMAND[*]} " != *" __terminal_wakatime_postexec "* ]]; then
+        PROMPT_COMMAND=(__terminal_wakatime_postexec "${PROMPT_COMMAND[@]}")
+    fi
+elif [[ "$PROMPT_COMMAND" != *"__terminal_wakatime_postexec"* ]]; then
+    PROMPT_COMMAND="__terminal_wakatime_postexec${PROMPT_COMMAND:+; $PROMPT_COMMAND}"
 fi`
 
-	// Add preexec hook for bash (requires bash-preexec or manual setup)
+	// Without bash-preexec we install our own DEBUG trap. It only fires once per
+	// prompt: __terminal_wakatime_preexec_arm runs last in PROMPT_COMMAND and the
+	// first trapped command after it is the one the user typed. Commands inside
+	// functions, completions, subshells and prompt hooks are ignored, and any
+	// existing DEBUG trap keeps running.
+	debugTrap := `
+__terminal_wakatime_preexec_arm() {
+    # Remember the last history entry, to tell whether the next command
+    # gets one of its own
+    local entry
+    entry="$(HISTTIMEFORMAT= builtin history 1 2>/dev/null)"
+    entry="${entry#"${entry%%[![:space:]]*}"}"
+    __terminal_wakatime_history_number="${entry%%[[:space:]]*}"
+
+    __terminal_wakatime_preexec_armed=1
+}
+
+__terminal_wakatime_debug_trap() {
+    local last_arg="$1"
+
+    if [[ -n "$__terminal_wakatime_prev_debug_trap" ]]; then
+        eval "$__terminal_wakatime_prev_debug_trap"
+    fi
+
+    if [[ -n "$__terminal_wakatime_preexec_armed" && -z "${COMP_LINE:-}" && "$BASH_SUBSHELL" -eq 0 ]]; then
+        case "$BASH_COMMAND" in
+            __terminal_wakatime_*) ;;
+            *)
+                unset __terminal_wakatime_preexec_armed
+
+                # Prefer the full command line from history; BASH_COMMAND only
+                # holds the first simple command of a list or pipeline
+                local this_command history_number
+                this_command="$(HISTTIMEFORMAT= builtin history 1 2>/dev/null)"
+                this_command="${this_command#"${this_command%%[![:space:]]*}"}"
+                history_number="${this_command%%[[:space:]]*}"
+                this_command="${this_command#*[[:space:]]}"
+                this_command="${this_command#"${this_command%%[![:space:]]*}"}"
+
+                # HISTCONTROL and HISTIGNORE keep some commands out of history,
+                # where the last entry is then an earlier command
+                if [[ -z "$this_command" || "$history_number" == "$__terminal_wakatime_history_number" ]]; then
+                    this_command="$BASH_COMMAND"
+                fi
+
+                __terminal_wakatime_preexec "$this_command"
+                ;;
+        esac
+    fi
+
+    # Restore $_ for the command that is about to run
+    : "$last_arg"
+}`
+
 	preexecSetup := `
 if [[ -n "$BASH_VERSION" ]]; then
-    if command -v __bp_install >/dev/null 2>&1; then
+    if [[ -n "${bash_preexec_imported:-}${__bp_imported:-}" ]] || command -v __bp_install >/dev/null 2>&1; then
         # bash-preexec is available
-        preexec_functions+=(__terminal_wakatime_preexec)
-    else
-        # Fallback: use DEBUG trap (less reliable but works)
-        if [[ "$PS4" != *"__terminal_wakatime_preexec"* ]]; then
-            __original_ps4="$PS4"
-            PS4='$(__terminal_wakatime_preexec "$BASH_COMMAND"; echo "$__original_ps4")'
-            set -T
+        if [[ " ${preexec_functions[*]} " != *" __terminal_wakatime_preexec "* ]]; then
+            preexec_functions+=(__terminal_wakatime_preexec)
+        fi
+    elif [[ $- == *i* ]]; then
+        # Built-in preexec: arm at the end of PROMPT_COMMAND, fire from DEBUG
+        if [[ "$(declare -p PROMPT_COMMAND 2>/dev/null)" == "declare -a"* ]]; then
+            if [[ " ${PROMPT_COMMAND[*]} " != *" __terminal_wakatime_preexec_arm "* ]]; then
+                PROMPT_COMMAND+=(__terminal_wakatime_preexec_arm)
+            fi
+        elif [[ "$PROMPT_COMMAND" != *"__terminal_wakatime_preexec_arm"* ]]; then
+            PROMPT_COMMAND="${PROMPT_COMMAND:+$PROMPT_COMMAND
+}__terminal_wakatime_preexec_arm"
         fi
+
+        __terminal_wakatime_existing_trap="$(trap -p DEBUG)"
+        if [[ "$__terminal_wakatime_existing_trap" != *"__terminal_wakatime_debug_trap"* ]]; then
+            __terminal_wakatime_existing_trap="${__terminal_wakatime_existing_trap#trap -- \'}"
+            __terminal_wakatime_existing_trap="${__terminal_wakatime_existing_trap%\' DEBUG}"
+            __terminal_wakatime_prev_debug_trap="${__terminal_wakatime_existing_trap//"'\''"/"'"}"
+            trap '__terminal_wakatime_debug_trap "$_"' DEBUG
+        fi
+        unset __terminal_wakatime_existing_trap
     fi
 fi`
 
-	return fmt.Sprintf("%s\n%s\n%s\n%s", preExec, postExec, promptCommand, preexecSetup)
+	marker := fmt.Sprintf(`
+export %s="%s"`, HookBinEnv, i.binPath)
+
+	return fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n%s", preExec, postExec, promptCommand, debugTrap, preexecSetup, marker) + i.sshWrapper()
 }
 
 func (i *Integration) generateZshHooks() string {
+	watchStart, watchStop := i.watchHooks()
+
 	preExec := fmt.Sprintf(`
 __terminal_wakatime_preexec() {
     if [ -n "$1" ]; then
         export __TERMINAL_WAKATIME_COMMAND="$1"
         export __TERMINAL_WAKATIME_START_TIME="$(date +%%s)"
-        export __TERMINAL_WAKATIME_PWD="$PWD"
+        export __TERMINAL_WAKATIME_PWD="$PWD"%s
     fi
-}`)
+}`, gitCapture+watchStart)
 
 	postExec := fmt.Sprintf(`
 __terminal_wakatime_precmd() {
@@ -231,18 +378,20 @@ __terminal_wakatime_precmd() {
         local duration=$((end_time - __TERMINAL_WAKATIME_START_TIME))
         local command="$__TERMINAL_WAKATIME_COMMAND"
         local pwd="$__TERMINAL_WAKATIME_PWD"
+        local git_before="$__TERMINAL_WAKATIME_GIT_BEFORE"
         
         # Clear variables immediately
         unset __TERMINAL_WAKATIME_COMMAND
         unset __TERMINAL_WAKATIME_START_TIME
         unset __TERMINAL_WAKATIME_PWD
-        
+        unset __TERMINAL_WAKATIME_GIT_BEFORE
+        %s
         # Only track commands that run for a minimum duration
         if [ "$duration" -ge %d ]; then
-            ("%s" track --command "$command" --duration "$duration" --pwd "$pwd" >/dev/null 2>&1 &)
+            ("%s" track --command "$command" --duration "$duration" --pwd "$pwd" --git-before "$git_before" >/dev/null 2>&1 &)
         fi
     fi
-}`, i.minCommandTime, i.binPath)
+}`, watchStop, i.minCommandTime, i.binPath)
 
 	hookSetup := `
 # Add hooks to zsh
@@ -256,15 +405,44 @@ if [[ -n "$ZSH_VERSION" ]]; then
     fi
 fi`
 
-	return fmt.Sprintf("%s\n%s\n%s", preExec, postExec, hookSetup)
+	marker := fmt.Sprintf(`
+export %s="%s"`, HookBinEnv, i.binPath)
+
+	return fmt.Sprintf("%s\n%s\n%s\n%s", preExec, postExec, hookSetup, marker) + i.sshWrapper()
 }
 
 func (i *Integration) generateFishHooks() string {
+	var watchStart, watchStop string
+	if len(i.watchEditors) > 0 {
+		watchStart = fmt.Sprintf(`
+    switch (string replace -r '.*/' '' -- (string split -f1 ' ' -- $argv[1]))
+        case %s
+            set -g __TERMINAL_WAKATIME_WATCHING 1
+            fish -c '"%s" watch --command "$argv[1]" --pwd "$argv[2]" --shell-pid "$argv[3]" >/dev/null 2>&1 &' -- $argv[1] $PWD $fish_pid
+    end`, strings.Join(i.watchEditors, " "), i.binPath)
+		watchStop = fmt.Sprintf(`
+        if set -q __TERMINAL_WAKATIME_WATCHING
+            set -e __TERMINAL_WAKATIME_WATCHING
+            fish -c '"%s" watch --stop --shell-pid "$argv[1]" >/dev/null 2>&1 &' -- $fish_pid
+        end`, i.binPath)
+	}
+
+	var sshWrapper string
+	if i.sshForward {
+		sshWrapper = fmt.Sprintf(`
+function ssh --wraps ssh
+    "%s" ssh $argv
+end`, i.binPath)
+	}
+
 	return fmt.Sprintf(`
 function __terminal_wakatime_preexec --on-event fish_preexec
     set -g __TERMINAL_WAKATIME_COMMAND $argv[1]
     set -g __TERMINAL_WAKATIME_START_TIME (date +%%s)
     set -g __TERMINAL_WAKATIME_PWD $PWD
+    if string match -q 'git *' -- $argv[1]
+        set -g __TERMINAL_WAKATIME_GIT_BEFORE (string join ' ' -- (git rev-parse -q --verify 'HEAD^{commit}' 2>/dev/null; or echo -) (git rev-parse -q --verify '@{u}^{commit}' 2>/dev/null; or echo -))
+    end%s
 end
 
 function __terminal_wakatime_postexec --on-event fish_postexec
@@ -273,18 +451,22 @@ function __terminal_wakatime_postexec --on-event fish_postexec
         set duration (math $end_time - $__TERMINAL_WAKATIME_START_TIME)
         set command "$__TERMINAL_WAKATIME_COMMAND"
         set pwd "$__TERMINAL_WAKATIME_PWD"
+        set git_before "$__TERMINAL_WAKATIME_GIT_BEFORE"
         
         # Clear variables immediately
         set -e __TERMINAL_WAKATIME_COMMAND
         set -e __TERMINAL_WAKATIME_START_TIME
         set -e __TERMINAL_WAKATIME_PWD
-        
+        set -e __TERMINAL_WAKATIME_GIT_BEFORE
+        %s
         # Only track commands that run for a minimum duration
         if test $duration -ge %d
-            fish -c '"%s" track --command "$argv[1]" --duration "$argv[2]" --pwd "$argv[3]" >/dev/null 2>&1 &' -- "$command" "$duration" "$pwd"
+            fish -c '"%s" track --command "$argv[1]" --duration "$argv[2]" --pwd "$argv[3]" --git-before "$argv[4]" >/dev/null 2>&1 &' -- "$command" "$duration" "$pwd" "$git_before"
         end
     end
-end`, i.minCommandTime, i.binPath)
+end
+
+set -gx %s "%s"%s`, watchStart, watchStop, i.minCommandTime, i.binPath, HookBinEnv, i.binPath, sshWrapper)
 }
 
 func (i *Integration) GetShellName() string {
@@ -1,9 +1,14 @@
 package shell
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDetectShell(t *testing.T) {
@@ -76,6 +81,26 @@ func TestGenerateBashHooks(t *testing.T) {
 	if !strings.Contains(hooks, `"`+integration.binPath+`"`) {
 		t.Error("Expected binary path to be properly quoted in hooks")
 	}
+
+	// The fallback must not touch PS4 or enable functrace
+	for _, forbidden := range []string{"PS4", "set -T"} {
+		if strings.Contains(hooks, forbidden) {
+			t.Errorf("Expected bash hooks not to contain '%s'", forbidden)
+		}
+	}
+
+	// It should install a DEBUG trap that chains any existing one
+	expectedFallback := []string{
+		`trap '__terminal_wakatime_debug_trap "$_"' DEBUG`,
+		"__terminal_wakatime_prev_debug_trap",
+		"__terminal_wakatime_preexec_arm",
+		`declare -p PROMPT_COMMAND`,
+	}
+	for _, part := range expectedFallback {
+		if !strings.Contains(hooks, part) {
+			t.Errorf("Expected bash hooks to contain '%s'", part)
+		}
+	}
 }
 
 func TestGenerateZshHooks(t *testing.T) {
@@ -133,6 +158,116 @@ func TestGenerateFishHooks(t *testing.T) {
 	}
 }
 
+func TestWatchEditorsHooks(t *testing.T) {
+	for _, shell := range []Shell{Bash, Zsh, Fish} {
+		t.Run(string(shell), func(t *testing.T) {
+			integration := &Integration{shell: shell, binPath: "/usr/local/bin/terminal-wakatime"}
+
+			if hooks := integration.GenerateHooks(); strings.Contains(hooks, " watch ") {
+				t.Error("Expected no watcher unless it is enabled")
+			}
+
+			integration.SetWatchEditors([]string{"nvim", "vim"})
+			hooks := integration.GenerateHooks()
+
+			for _, part := range []string{"watch --command", "watch --stop --shell-pid", "vim"} {
+				if !strings.Contains(hooks, part) {
+					t.Errorf("Expected hooks to contain %q", part)
+				}
+			}
+		})
+	}
+}
+
+func TestWatchEditorsHooksSkipOtherCommands(t *testing.T) {
+	for _, shell := range []Shell{Bash, Zsh} {
+		t.Run(string(shell), func(t *testing.T) {
+			shellPath, err := exec.LookPath(string(shell))
+			if err != nil {
+				t.Skipf("%s not installed", shell)
+			}
+
+			dir := t.TempDir()
+			logFile := filepath.Join(dir, "calls.log")
+			binPath := filepath.Join(dir, "terminal-wakatime")
+			script := "#!/bin/sh\n[ \"$1\" = watch ] && echo \"$*\" >> " + logFile + "\n"
+			if err := os.WriteFile(binPath, []byte(script), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			integration := &Integration{shell: shell, binPath: binPath}
+			integration.SetWatchEditors([]string{"nvim", "vim"})
+			hooksFile := filepath.Join(dir, "hooks")
+			if err := os.WriteFile(hooksFile, []byte(integration.GenerateHooks()), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			postexec := "__terminal_wakatime_postexec"
+			if shell == Zsh {
+				postexec = "__terminal_wakatime_precmd"
+			}
+			commands := []string{"ls -la", "/usr/bin/vim main.go", "git status", "vimdiff a b"}
+			var session strings.Builder
+			session.WriteString(". " + hooksFile + "\n")
+			for _, command := range commands {
+				fmt.Fprintf(&session, "__terminal_wakatime_preexec '%s'\n%s\n", command, postexec)
+			}
+
+			if output, err := exec.Command(shellPath, "-c", session.String()).CombinedOutput(); err != nil {
+				t.Fatalf("%s failed: %v\n%s", shell, err, output)
+			}
+
+			// The watcher is started and stopped in the background, so give
+			// stray calls a moment to show up too
+			readCalls := func() []string {
+				data, _ := os.ReadFile(logFile)
+				return strings.Fields(strings.ReplaceAll(strings.TrimSpace(string(data)), " ", "_"))
+			}
+			for deadline := time.Now().Add(5 * time.Second); len(readCalls()) < 2 && time.Now().Before(deadline); {
+				time.Sleep(20 * time.Millisecond)
+			}
+			time.Sleep(200 * time.Millisecond)
+			calls := readCalls()
+
+			sort.Strings(calls)
+			if len(calls) != 2 || !strings.HasPrefix(calls[0], "watch_--command_/usr/bin/vim_main.go") || !strings.HasPrefix(calls[1], "watch_--stop") {
+				t.Errorf("Expected one watcher for vim only, got %q", calls)
+			}
+		})
+	}
+}
+
+func TestSSHForwardHooks(t *testing.T) {
+	for _, shell := range []Shell{Bash, Zsh, Fish} {
+		t.Run(string(shell), func(t *testing.T) {
+			integration := &Integration{shell: shell, binPath: "/usr/local/bin/terminal-wakatime"}
+
+			if hooks := integration.GenerateHooks(); strings.Contains(hooks, `" ssh `) {
+				t.Error("Expected no ssh wrapper unless forwarding is enabled")
+			}
+
+			integration.SetSSHForward(true)
+			if hooks := integration.GenerateHooks(); !strings.Contains(hooks, `"/usr/local/bin/terminal-wakatime" ssh `) {
+				t.Error("Expected hooks to run ssh through terminal-wakatime")
+			}
+		})
+	}
+}
+
+func TestGitSnapshotHooks(t *testing.T) {
+	for _, shell := range []Shell{Bash, Zsh, Fish} {
+		t.Run(string(shell), func(t *testing.T) {
+			hooks := (&Integration{shell: shell, binPath: "/usr/local/bin/terminal-wakatime"}).GenerateHooks()
+
+			for _, part := range []string{"__TERMINAL_WAKATIME_GIT_BEFORE", "rev-parse -q --verify 'HEAD^{commit}'", "--git-before"} {
+				if !strings.Contains(hooks, part) {
+					t.Errorf("Expected hooks to contain %q", part)
+				}
+			}
+		})
+	}
+}
+
 func TestGenerateHooks(t *testing.T) {
 	binPath := "/usr/local/bin/terminal-wakatime"
 
@@ -0,0 +1,298 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Marker comments delimiting the block managed by install/uninstall
+	RCBlockStart = "# >>> terminal-wakatime >>>"
+	RCBlockEnd   = "# <<< terminal-wakatime <<<"
+
+	// Header written by older versions of install.sh
+	legacyRCHeader = "# terminal-wakatime setup"
+)
+
+var (
+	// Init lines added by install.sh or by hand, outside of a managed block
+	legacyInitPattern = regexp.MustCompile(`^(eval\s.*terminal-wakatime"?\s+init\b.*|.*terminal-wakatime"?\s+init\b.*\|\s*source)$`)
+
+	// PATH lines install.sh adds right after its header
+	legacyPathPattern = regexp.MustCompile(`^(export PATH="\$HOME/\.wakatime:\$PATH"|set -x PATH "\$HOME/\.wakatime" \$PATH)$`)
+
+	// Binary referenced by an init line, quoted or bare
+	initBinPattern = regexp.MustCompile(`(?:"([^"]+)"|([^\s"'(]*terminal-wakatime))\s+init\b`)
+)
+
+// RCInitLine is an init line found in a shell configuration file
+type RCInitLine struct {
+	File    string
+	Line    string
+	BinPath string
+}
+
+// RCEdit is a planned change to a shell configuration file
+type RCEdit struct {
+	Path     string
+	Original string
+	Updated  string
+	Added    []string
+	Removed  []string
+	exists   bool
+}
+
+// Changed reports whether applying the edit would modify the file
+func (e *RCEdit) Changed() bool {
+	return e.Original != e.Updated
+}
+
+// Apply writes the updated content, keeping the file's permissions and
+// following symlinks so dotfile managers keep working
+func (e *RCEdit) Apply() error {
+	if !e.Changed() {
+		return nil
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(e.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(e.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", e.Path, err)
+	}
+
+	if err := os.WriteFile(e.Path, []byte(e.Updated), mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", e.Path, err)
+	}
+
+	return nil
+}
+
+// InitCommand returns the line that loads the hooks for this shell
+func (i *Integration) InitCommand() string {
+	switch i.shell {
+	case Fish:
+		return fmt.Sprintf(`"%s" init fish | source`, i.binPath)
+	default:
+		return fmt.Sprintf(`eval "$("%s" init %s)"`, i.binPath, i.shell)
+	}
+}
+
+// PlanInstall works out how to add or repair the integration block. The block
+// is rewritten in place if any recommended file already has one, otherwise it
+// is appended to the first recommended file that exists (or the first one).
+func (i *Integration) PlanInstall() (*RCEdit, error) {
+	block := []string{RCBlockStart, i.InitCommand(), RCBlockEnd}
+
+	var target *RCEdit
+	for _, candidate := range i.GetConfigFileRecommendations() {
+		edit, err := loadRCEdit(expandPath(candidate))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, removed := rewriteIntegration(edit.Original, nil); len(removed) > 0 {
+			target = edit
+			break
+		}
+
+		if target == nil || (!target.exists && edit.exists) {
+			target = edit
+		}
+	}
+
+	if target == nil {
+		return nil, fmt.Errorf("no configuration file known for shell %s", i.shell)
+	}
+
+	target.Updated, target.Removed = rewriteIntegration(target.Original, block)
+	target.Added = managedBlock(target.Updated)
+	if !target.Changed() {
+		target.Added = nil
+		target.Removed = nil
+	}
+
+	return target, nil
+}
+
+// PlanUninstall returns the edits that remove the integration from every
+// recommended file for this shell. Files without an integration are skipped.
+func (i *Integration) PlanUninstall() ([]*RCEdit, error) {
+	var edits []*RCEdit
+
+	for _, candidate := range i.GetConfigFileRecommendations() {
+		edit, err := loadRCEdit(expandPath(candidate))
+		if err != nil {
+			return nil, err
+		}
+
+		edit.Updated, edit.Removed = rewriteIntegration(edit.Original, nil)
+		if edit.Changed() {
+			edits = append(edits, edit)
+		}
+	}
+
+	return edits, nil
+}
+
+// InstalledInitLines returns the init lines found in the recommended files
+// for this shell, with the binary each one calls. Bare names are resolved
+// through PATH; BinPath is left as written if that fails.
+func (i *Integration) InstalledInitLines() ([]RCInitLine, error) {
+	var found []RCInitLine
+
+	for _, candidate := range i.GetConfigFileRecommendations() {
+		path := expandPath(candidate)
+		edit, err := loadRCEdit(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range strings.Split(edit.Original, "\n") {
+			trimmed := strings.TrimSpace(line)
+			if !legacyInitPattern.MatchString(trimmed) {
+				continue
+			}
+
+			matches := initBinPattern.FindStringSubmatch(trimmed)
+			if matches == nil {
+				continue
+			}
+
+			binPath := matches[1]
+			if binPath == "" {
+				binPath = matches[2]
+			}
+			binPath = expandPath(strings.Replace(binPath, "$HOME/", "~/", 1))
+			if !strings.Contains(binPath, "/") {
+				if resolved, err := exec.LookPath(binPath); err == nil {
+					binPath = resolved
+				}
+			}
+
+			found = append(found, RCInitLine{File: path, Line: trimmed, BinPath: binPath})
+		}
+	}
+
+	return found, nil
+}
+
+// managedBlock returns the lines of the first managed block in content,
+// markers included
+func managedBlock(content string) []string {
+	var block []string
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == RCBlockStart || len(block) > 0 {
+			block = append(block, trimmed)
+		}
+		if trimmed == RCBlockEnd && len(block) > 0 {
+			break
+		}
+	}
+	return block
+}
+
+func loadRCEdit(path string) (*RCEdit, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &RCEdit{Path: path}, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return &RCEdit{
+		Path:     path,
+		Original: string(data),
+		Updated:  string(data),
+		exists:   true,
+	}, nil
+}
+
+// rewriteIntegration removes every managed block and legacy init line from
+// content and, if block is non-nil, puts it where the first one was found (or
+// at the end of the file). It returns the new content and the removed lines.
+// The PATH line install.sh adds is carried into the new block, so the
+// binaries it puts on PATH stay there; only uninstall drops it.
+func rewriteIntegration(content string, block []string) (string, []string) {
+	lines := strings.Split(content, "\n")
+	hasEnd := strings.Contains(content, RCBlockEnd)
+
+	var kept, removed, pathLines []string
+	insertAt := -1
+	inBlock := false
+	inLegacy := false
+
+	remove := func(line string) {
+		if insertAt == -1 {
+			// Drop the blank separator line that precedes the block
+			if n := len(kept); n > 0 && strings.TrimSpace(kept[n-1]) == "" {
+				kept = kept[:n-1]
+			}
+			insertAt = len(kept)
+		}
+		removed = append(removed, line)
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case inBlock:
+			removed = append(removed, line)
+			if trimmed == RCBlockEnd {
+				inBlock = false
+			} else if legacyPathPattern.MatchString(trimmed) {
+				pathLines = append(pathLines, trimmed)
+			}
+		case trimmed == RCBlockStart:
+			remove(line)
+			inBlock = hasEnd
+		case trimmed == legacyRCHeader:
+			remove(line)
+			inLegacy = true
+		case legacyInitPattern.MatchString(trimmed):
+			remove(line)
+		case inLegacy && legacyPathPattern.MatchString(trimmed):
+			remove(line)
+			pathLines = append(pathLines, trimmed)
+		default:
+			inLegacy = false
+			kept = append(kept, line)
+		}
+	}
+
+	if block != nil && len(pathLines) > 0 {
+		block = append([]string{block[0], pathLines[0]}, block[1:]...)
+	}
+
+	if block != nil {
+		if insertAt == -1 {
+			// Append after a blank separator line
+			for len(kept) > 0 && kept[len(kept)-1] == "" {
+				kept = kept[:len(kept)-1]
+			}
+			if len(kept) > 0 {
+				kept = append(kept, "")
+			}
+			kept = append(kept, block...)
+			kept = append(kept, "")
+		} else {
+			var section []string
+			if insertAt > 0 {
+				section = append(section, "")
+			}
+			section = append(section, block...)
+			kept = append(kept[:insertAt], append(section, kept[insertAt:]...)...)
+		}
+	}
+
+	return strings.Join(kept, "\n"), removed
+}
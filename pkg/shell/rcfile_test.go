@@ -0,0 +1,182 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitCommand(t *testing.T) {
+	tests := []struct {
+		shell    Shell
+		expected string
+	}{
+		{Bash, `eval "$("/opt/tw" init bash)"`},
+		{Zsh, `eval "$("/opt/tw" init zsh)"`},
+		{Fish, `"/opt/tw" init fish | source`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.shell), func(t *testing.T) {
+			integration := &Integration{shell: tt.shell, binPath: "/opt/tw"}
+			if got := integration.InitCommand(); got != tt.expected {
+				t.Errorf("InitCommand() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRewriteIntegration(t *testing.T) {
+	block := []string{RCBlockStart, `eval "$("/opt/tw" init bash)"`, RCBlockEnd}
+	managed := strings.Join(block, "\n")
+
+	tests := []struct {
+		name     string
+		content  string
+		block    []string
+		expected string
+		nRemoved int
+	}{
+		{
+			name:     "install into empty file",
+			content:  "",
+			block:    block,
+			expected: managed + "\n",
+		},
+		{
+			name:     "append after existing content",
+			content:  "export EDITOR=vim\n",
+			block:    block,
+			expected: "export EDITOR=vim\n\n" + managed + "\n",
+		},
+		{
+			name:     "install is idempotent",
+			content:  "export EDITOR=vim\n\n" + managed + "\n",
+			block:    block,
+			expected: "export EDITOR=vim\n\n" + managed + "\n",
+			nRemoved: 3,
+		},
+		{
+			name:     "repair block in place",
+			content:  "a\n\n" + RCBlockStart + "\neval \"$(/old/tw init)\"\n" + RCBlockEnd + "\nb\n",
+			block:    block,
+			expected: "a\n\n" + managed + "\nb\n",
+			nRemoved: 3,
+		},
+		{
+			name:     "migrate legacy install.sh lines",
+			content:  "a\n\n# terminal-wakatime setup\nexport PATH=\"$HOME/.wakatime:$PATH\"\neval \"$(terminal-wakatime init)\"\n",
+			block:    block,
+			expected: "a\n\n" + RCBlockStart + "\nexport PATH=\"$HOME/.wakatime:$PATH\"\n" + strings.Join(block[1:], "\n") + "\n",
+			nRemoved: 3,
+		},
+		{
+			name:     "carried PATH line survives reinstall",
+			content:  RCBlockStart + "\nset -x PATH \"$HOME/.wakatime\" $PATH\n\"/old/tw\" init fish | source\n" + RCBlockEnd + "\n",
+			block:    block,
+			expected: RCBlockStart + "\nset -x PATH \"$HOME/.wakatime\" $PATH\n" + strings.Join(block[1:], "\n") + "\n",
+			nRemoved: 4,
+		},
+		{
+			name:     "uninstall managed block",
+			content:  "a\n\n" + managed + "\nb\n",
+			expected: "a\nb\n",
+			nRemoved: 3,
+		},
+		{
+			name:     "uninstall legacy fish lines",
+			content:  "# terminal-wakatime setup\nset -x PATH \"$HOME/.wakatime\" $PATH\nterminal-wakatime init fish | source\n",
+			expected: "",
+			nRemoved: 3,
+		},
+		{
+			name:     "unterminated block keeps user content",
+			content:  RCBlockStart + "\nexport EDITOR=vim\n",
+			expected: "export EDITOR=vim\n",
+			nRemoved: 1,
+		},
+		{
+			name:     "unrelated mentions are kept",
+			content:  "# I use terminal-wakatime\nalias tw=terminal-wakatime\n",
+			expected: "# I use terminal-wakatime\nalias tw=terminal-wakatime\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, removed := rewriteIntegration(tt.content, tt.block)
+			if got != tt.expected {
+				t.Errorf("rewriteIntegration() content =\n%q\nwant\n%q", got, tt.expected)
+			}
+			if len(removed) != tt.nRemoved {
+				t.Errorf("rewriteIntegration() removed %d lines (%q), want %d", len(removed), removed, tt.nRemoved)
+			}
+		})
+	}
+}
+
+func TestPlanInstallAndUninstall(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", originalHome)
+	os.Setenv("HOME", tempDir)
+
+	// An existing .bash_profile wins over a missing .bashrc
+	profile := filepath.Join(tempDir, ".bash_profile")
+	if err := os.WriteFile(profile, []byte("export A=1\n"), 0600); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+
+	integration := &Integration{shell: Bash, binPath: "/opt/tw"}
+
+	edit, err := integration.PlanInstall()
+	if err != nil {
+		t.Fatalf("PlanInstall() failed: %v", err)
+	}
+	if edit.Path != profile {
+		t.Errorf("Expected install target %s, got %s", profile, edit.Path)
+	}
+	if !edit.Changed() || len(edit.Added) != 3 {
+		t.Fatalf("Expected a 3 line addition, got %+v", edit)
+	}
+	if err := edit.Apply(); err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+
+	info, err := os.Stat(profile)
+	if err != nil {
+		t.Fatalf("Failed to stat profile: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions to be preserved, got %v", info.Mode().Perm())
+	}
+
+	// Running install again must not change anything
+	edit, err = integration.PlanInstall()
+	if err != nil {
+		t.Fatalf("Second PlanInstall() failed: %v", err)
+	}
+	if edit.Changed() {
+		t.Errorf("Expected second install to be a no-op, got:\n%s", edit.Updated)
+	}
+
+	edits, err := integration.PlanUninstall()
+	if err != nil {
+		t.Fatalf("PlanUninstall() failed: %v", err)
+	}
+	if len(edits) != 1 {
+		t.Fatalf("Expected 1 uninstall edit, got %d", len(edits))
+	}
+	if err := edits[0].Apply(); err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+
+	content, err := os.ReadFile(profile)
+	if err != nil {
+		t.Fatalf("Failed to read profile: %v", err)
+	}
+	if string(content) != "export A=1\n" {
+		t.Errorf("Expected original content after uninstall, got %q", content)
+	}
+}
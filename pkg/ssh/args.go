@@ -0,0 +1,271 @@
+// Package ssh reads ssh command lines and ssh_config files the way OpenSSH
+// does, far enough to know which host a command connects to
+package ssh
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// Options without a value, and with one, from ssh(1)
+const (
+	flagOptions  = "46AaCfGgKkMNnqsTtVvXxYy"
+	valueOptions = "BbcDEeFIiJLlmOoPpQRSWw"
+)
+
+// Command is a parsed ssh command line
+type Command struct {
+	// Host is the destination as written, which may be a config alias
+	Host string
+
+	// User, Port and JumpHosts are what the command line sets: from
+	// user@host, ssh://user@host:port, -l, -p, -J and -o
+	User      string
+	Port      string
+	JumpHosts []string
+
+	// ConfigFile is the -F file, "none" to read no configuration
+	ConfigFile string
+
+	// Options are the -o settings, keyed by lowercase keyword. As in ssh,
+	// the first value given wins.
+	Options map[string]string
+
+	// RemoteCommand is what to run instead of a login shell
+	RemoteCommand []string
+
+	// NoSession is set for -G, -V, -Q and -O, which don't log in
+	NoSession bool
+}
+
+// ParseCommand parses ssh's arguments, without "ssh" itself. Options may
+// follow the destination, as OpenSSH allows, until the remote command.
+func ParseCommand(args []string) (*Command, error) {
+	cmd := &Command{Options: map[string]string{}}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if cmd.Host != "" && !strings.HasPrefix(arg, "-") {
+			cmd.RemoteCommand = args[i:]
+			break
+		}
+
+		switch {
+		case arg == "--":
+			if cmd.Host == "" && i+1 < len(args) {
+				cmd.setDestination(args[i+1])
+				i++
+			}
+			if i+1 < len(args) {
+				cmd.RemoteCommand = args[i+1:]
+			}
+			i = len(args)
+
+		case len(arg) > 1 && arg[0] == '-':
+			consumed, err := cmd.parseOptions(arg[1:], args[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			i += consumed
+
+		default:
+			cmd.setDestination(arg)
+		}
+	}
+
+	if cmd.Host == "" && !cmd.NoSession {
+		return nil, fmt.Errorf("no destination")
+	}
+	return cmd, nil
+}
+
+// parseOptions handles one word of bundled options like -tt or -p2222,
+// returning how many of the following words it used as a value
+func (c *Command) parseOptions(letters string, rest []string) (int, error) {
+	for j := 0; j < len(letters); j++ {
+		letter := letters[j]
+
+		switch {
+		case strings.IndexByte(flagOptions, letter) >= 0:
+			if letter == 'G' || letter == 'V' {
+				c.NoSession = true
+			}
+
+		case strings.IndexByte(valueOptions, letter) >= 0:
+			value, consumed := letters[j+1:], 0
+			if value == "" {
+				if len(rest) == 0 {
+					return 0, fmt.Errorf("option -%c requires a value", letter)
+				}
+				value, consumed = rest[0], 1
+			}
+			c.setOption(letter, value)
+			return consumed, nil
+
+		default:
+			return 0, fmt.Errorf("unknown option -%c", letter)
+		}
+	}
+	return 0, nil
+}
+
+func (c *Command) setOption(letter byte, value string) {
+	switch letter {
+	case 'l':
+		if c.User == "" {
+			c.User = value
+		}
+	case 'p':
+		if c.Port == "" {
+			c.Port = value
+		}
+	case 'J':
+		if c.JumpHosts == nil {
+			c.JumpHosts = splitJumpHosts(value)
+		}
+	case 'F':
+		c.ConfigFile = value
+	case 'o':
+		key, val := splitKeyword(value)
+		key = strings.ToLower(key)
+		if _, set := c.Options[key]; !set && key != "" {
+			c.Options[key] = val
+		}
+	case 'Q', 'O':
+		c.NoSession = true
+	}
+}
+
+// setDestination takes [user@]host or ssh://[user@]host[:port]
+func (c *Command) setDestination(destination string) {
+	if strings.HasPrefix(destination, "ssh://") {
+		if parsed, err := url.Parse(destination); err == nil {
+			if parsed.User != nil && c.User == "" {
+				c.User = parsed.User.Username()
+			}
+			if parsed.Port() != "" && c.Port == "" {
+				c.Port = parsed.Port()
+			}
+			c.Host = parsed.Hostname()
+			return
+		}
+	}
+
+	// The host follows the last "@", as user names may contain one
+	if at := strings.LastIndex(destination, "@"); at >= 0 {
+		if c.User == "" {
+			c.User = destination[:at]
+		}
+		destination = destination[at+1:]
+	}
+	c.Host = destination
+}
+
+// Destination is where a command connects once ssh_config is applied
+type Destination struct {
+	// Alias is the host as written on the command line
+	Alias string
+
+	HostName  string
+	User      string
+	Port      string
+	JumpHosts []string
+}
+
+// Destination applies the configuration in home's ~/.ssh/config and the
+// system ssh_config, or the -F file, beneath the command line's settings
+func (c *Command) Destination(home string) *Destination {
+	var files []string
+	switch {
+	case c.ConfigFile == "none":
+	case c.ConfigFile != "":
+		files = []string{expandHome(c.ConfigFile, home)}
+	default:
+		files = []string{filepath.Join(home, ".ssh", "config"), systemConfigFile}
+	}
+
+	settings := Lookup(c.Host, home, files...)
+	setting := func(keyword string) string {
+		if value, ok := c.Options[keyword]; ok {
+			return value
+		}
+		return settings[keyword]
+	}
+
+	dest := &Destination{
+		Alias:    c.Host,
+		HostName: c.Host,
+		User:     c.User,
+		Port:     c.Port,
+	}
+
+	if hostName := setting("hostname"); hostName != "" {
+		dest.HostName = expandHostTokens(hostName, c.Host)
+	}
+	if dest.User == "" {
+		dest.User = setting("user")
+	}
+	if dest.User == "" {
+		dest.User = LocalUser()
+	}
+	if dest.Port == "" {
+		dest.Port = setting("port")
+	}
+	if dest.Port == "" {
+		dest.Port = "22"
+	}
+
+	dest.JumpHosts = c.JumpHosts
+	if dest.JumpHosts == nil {
+		dest.JumpHosts = splitJumpHosts(setting("proxyjump"))
+	}
+	return dest
+}
+
+// LocalUser returns the name ssh logs in as by default, without a Windows
+// domain
+func LocalUser() string {
+	name := os.Getenv("USER")
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	if slash := strings.LastIndex(name, `\`); slash >= 0 {
+		name = name[slash+1:]
+	}
+	return name
+}
+
+// splitJumpHosts splits a ProxyJump list; "none" turns jumping off
+func splitJumpHosts(value string) []string {
+	if value == "" || strings.EqualFold(value, "none") {
+		return []string{}
+	}
+
+	var hosts []string
+	for _, host := range strings.Split(value, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// expandHostTokens replaces %h with the host as written and %% with %
+func expandHostTokens(value, host string) string {
+	return strings.NewReplacer("%h", host, "%%", "%").Replace(value)
+}
+
+func expandHome(path, home string) string {
+	if path == "~" {
+		return home
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(home, rest)
+	}
+	return path
+}
@@ -0,0 +1,125 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		command       string
+		host          string
+		user          string
+		port          string
+		jumpHosts     []string
+		remoteCommand []string
+	}{
+		{"ssh example.com", "example.com", "", "", nil, nil},
+		{"ssh user@example.com", "example.com", "user", "", nil, nil},
+		{"ssh -p 2222 -i key user@host", "host", "user", "2222", nil, nil},
+		{"ssh -p2222 -tt -vvv host", "host", "", "2222", nil, nil},
+		{"ssh -4A -l deploy host uptime", "host", "deploy", "", nil, []string{"uptime"}},
+		{"ssh host -p 2200 ls -la", "host", "", "2200", nil, []string{"ls", "-la"}},
+		{"ssh -J bastion,admin@jump:2200 db", "db", "", "", []string{"bastion", "admin@jump:2200"}, nil},
+		{"ssh -o ProxyJump=none -o Port=22 host", "host", "", "", nil, nil},
+		{"ssh ssh://git@example.com:7999", "example.com", "git", "7999", nil, nil},
+		{"ssh -L 8080:localhost:80 -N -f host", "host", "", "", nil, nil},
+		{"ssh -- host -p", "host", "", "", nil, []string{"-p"}},
+		{"ssh me@corp.com@gateway", "gateway", "me@corp.com", "", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			cmd, err := ParseCommand(strings.Fields(tt.command)[1:])
+			if err != nil {
+				t.Fatalf("ParseCommand() failed: %v", err)
+			}
+
+			if cmd.Host != tt.host || cmd.User != tt.user || cmd.Port != tt.port ||
+				!reflect.DeepEqual(cmd.JumpHosts, tt.jumpHosts) || !reflect.DeepEqual(cmd.RemoteCommand, tt.remoteCommand) {
+				t.Errorf("Got host %q user %q port %q jump %q command %q",
+					cmd.Host, cmd.User, cmd.Port, cmd.JumpHosts, cmd.RemoteCommand)
+			}
+		})
+	}
+}
+
+func TestParseCommandErrors(t *testing.T) {
+	for _, command := range []string{"ssh", "ssh -p", "ssh -Z host", "ssh -v"} {
+		if _, err := ParseCommand(strings.Fields(command)[1:]); err == nil {
+			t.Errorf("Expected an error for %q", command)
+		}
+	}
+
+	// Commands that don't log in need no destination
+	for _, command := range []string{"ssh -V", "ssh -G host", "ssh -O exit host", "ssh -Q cipher"} {
+		cmd, err := ParseCommand(strings.Fields(command)[1:])
+		if err != nil || !cmd.NoSession {
+			t.Errorf("Expected %q to parse as not logging in, got %+v (err %v)", command, cmd, err)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	home := t.TempDir()
+	writeConfig(t, filepath.Join(home, ".ssh", "config"), `
+# Work machines
+Host prod
+    HostName prod-01.example.com
+    User deploy
+    Port 2200
+    ProxyJump bastion.example.com
+
+Host *.internal !skip.internal
+    HostName %h.corp.example.com
+    User ops
+
+Host *
+    User fallback
+`)
+
+	tests := []struct {
+		command  string
+		hostName string
+		user     string
+		port     string
+		jump     []string
+	}{
+		{"ssh prod", "prod-01.example.com", "deploy", "2200", []string{"bastion.example.com"}},
+		{"ssh -p 22 root@prod", "prod-01.example.com", "root", "22", []string{"bastion.example.com"}},
+		{"ssh -J none prod", "prod-01.example.com", "deploy", "2200", []string{}},
+		{"ssh -o HostName=10.0.0.5 prod", "10.0.0.5", "deploy", "2200", []string{"bastion.example.com"}},
+		{"ssh db.internal", "db.internal.corp.example.com", "ops", "22", []string{}},
+		{"ssh skip.internal", "skip.internal", "fallback", "22", []string{}},
+		{"ssh PROD", "prod-01.example.com", "deploy", "2200", []string{"bastion.example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			cmd, err := ParseCommand(strings.Fields(tt.command)[1:])
+			if err != nil {
+				t.Fatalf("ParseCommand() failed: %v", err)
+			}
+			cmd.ConfigFile = filepath.Join(home, ".ssh", "config") // keep the system file out
+
+			dest := cmd.Destination(home)
+			if dest.HostName != tt.hostName || dest.User != tt.user || dest.Port != tt.port || !reflect.DeepEqual(dest.JumpHosts, tt.jump) {
+				t.Errorf("Got %+v", dest)
+			}
+		})
+	}
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,237 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	systemConfigFile = "/etc/ssh/ssh_config"
+
+	// maxIncludeDepth matches OpenSSH's limit on nested Include
+	maxIncludeDepth = 16
+)
+
+// Lookup returns the settings ssh_config files give host, keyed by
+// lowercase keyword. As in ssh, the first value found for a keyword wins,
+// so earlier files and earlier blocks take precedence.
+func Lookup(host, home string, files ...string) map[string]string {
+	l := &lookup{
+		host:     strings.ToLower(host),
+		home:     home,
+		settings: map[string]string{},
+	}
+
+	for _, file := range files {
+		// Relative includes are relative to ~/.ssh, or to /etc/ssh from
+		// the system file
+		base := filepath.Join(home, ".ssh")
+		if file == systemConfigFile {
+			base = filepath.Dir(systemConfigFile)
+		}
+		l.readFile(file, base, 0)
+	}
+	return l.settings
+}
+
+type lookup struct {
+	host     string
+	home     string
+	settings map[string]string
+}
+
+func (l *lookup) readFile(path, base string, depth int) {
+	if depth > maxIncludeDepth {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	// Lines before the first Host or Match apply to every host
+	active := true
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		keyword, value := splitKeyword(line)
+		keyword = strings.ToLower(keyword)
+		args := splitArgs(value)
+
+		switch keyword {
+		case "host":
+			active = matchPatternList(l.host, args)
+
+		case "match":
+			active = l.matchCriteria(args)
+
+		case "include":
+			if active {
+				for _, pattern := range args {
+					l.include(pattern, base, depth)
+				}
+			}
+
+		default:
+			if _, set := l.settings[keyword]; active && !set && len(args) > 0 {
+				l.settings[keyword] = args[0]
+			}
+		}
+	}
+}
+
+// include reads the files an Include pattern names, in lexical order
+func (l *lookup) include(pattern, base string, depth int) {
+	pattern = expandHome(pattern, l.home)
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(base, pattern)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return
+	}
+	for _, match := range matches {
+		l.readFile(match, base, depth+1)
+	}
+}
+
+// matchCriteria evaluates a Match line. Criteria that need more than the
+// host name (exec, user, canonical and so on) don't match, so their
+// blocks are skipped rather than applied to every host.
+func (l *lookup) matchCriteria(args []string) bool {
+	for i := 0; i < len(args); i++ {
+		criterion := strings.ToLower(args[i])
+		negated := strings.HasPrefix(criterion, "!")
+		criterion = strings.TrimPrefix(criterion, "!")
+
+		var matched bool
+		switch criterion {
+		case "all", "final":
+			matched = true
+
+		case "host", "originalhost", "localuser":
+			if i+1 >= len(args) {
+				return false
+			}
+			i++
+
+			subject := l.host
+			switch criterion {
+			case "host":
+				if hostName, ok := l.settings["hostname"]; ok {
+					subject = strings.ToLower(expandHostTokens(hostName, l.host))
+				}
+			case "localuser":
+				subject = LocalUser()
+			}
+			matched = matchPatternList(subject, strings.Split(args[i], ","))
+
+		default:
+			// Skip the criterion's argument, if it has one
+			if criterion != "canonical" && i+1 < len(args) {
+				i++
+			}
+			matched = false
+		}
+
+		if matched == negated {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPatternList reports whether s matches any of patterns and none of
+// the negated ("!pattern") ones
+func matchPatternList(s string, patterns []string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		if negated, ok := strings.CutPrefix(pattern, "!"); ok {
+			if matchPattern(s, strings.ToLower(negated)) {
+				return false
+			}
+		} else if matchPattern(s, strings.ToLower(pattern)) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// matchPattern matches ssh's wildcards: * for any run of characters and ?
+// for exactly one
+func matchPattern(s, pattern string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			pattern = strings.TrimLeft(pattern, "*")
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchPattern(s[i:], pattern) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if s == "" {
+				return false
+			}
+
+		default:
+			if s == "" || s[0] != pattern[0] {
+				return false
+			}
+		}
+		s, pattern = s[1:], pattern[1:]
+	}
+	return s == ""
+}
+
+// splitKeyword splits "Keyword value" or "Keyword=value"
+func splitKeyword(line string) (string, string) {
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return line, ""
+	}
+
+	keyword, rest := line[:end], strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, "="), " \t")
+	return keyword, rest
+}
+
+// splitArgs splits on whitespace, keeping double-quoted words together
+func splitArgs(value string) []string {
+	var args []string
+	var current strings.Builder
+	quoted, inWord := false, false
+
+	for _, r := range value {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inWord = true
+		case (r == ' ' || r == '\t') && !quoted:
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		args = append(args, current.String())
+	}
+	return args
+}
@@ -0,0 +1,83 @@
+package ssh
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	home := t.TempDir()
+	config := filepath.Join(home, ".ssh", "config")
+
+	writeConfig(t, config, `
+Include config.d/*
+Compression=yes
+
+Host = "box" build
+    User builder
+
+Match originalhost db
+    HostName db.example.com
+
+Match host db.example.com
+    Port 5022
+
+Match exec "test -f /nope" host db
+    User never
+
+Host db
+    User dba
+    HostName ignored.example.com
+`)
+	writeConfig(t, filepath.Join(home, ".ssh", "config.d", "10-git"), `
+Host git
+    HostName git.example.com
+    Port 7999
+`)
+
+	tests := []struct {
+		host     string
+		expected map[string]string
+	}{
+		{"git", map[string]string{"hostname": "git.example.com", "port": "7999", "compression": "yes"}},
+		{"db", map[string]string{"hostname": "db.example.com", "port": "5022", "user": "dba", "compression": "yes"}},
+		{"box", map[string]string{"user": "builder", "compression": "yes"}},
+		{"other", map[string]string{"compression": "yes"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			settings := Lookup(tt.host, home, config)
+			if len(settings) != len(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, settings)
+			}
+			for key, value := range tt.expected {
+				if settings[key] != value {
+					t.Errorf("Expected %s %q, got %q", key, value, settings[key])
+				}
+			}
+		})
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		s, pattern string
+		expected   bool
+	}{
+		{"host", "host", true},
+		{"host", "*", true},
+		{"web1.example.com", "web?.example.com", true},
+		{"web10.example.com", "web?.example.com", false},
+		{"a.b.c", "*.c", true},
+		{"a.b.c", "*.b", false},
+		{"", "*", true},
+		{"", "?", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchPattern(tt.s, tt.pattern); got != tt.expected {
+			t.Errorf("matchPattern(%q, %q) = %t, expected %t", tt.s, tt.pattern, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package summaries
+
+import "time"
+
+// cacheRetention is how long entries are kept before being pruned
+const cacheRetention = 7 * 24 * time.Hour
+
+func (c *Client) loadCache() map[string]*Summary {
+	cache := make(map[string]*Summary)
+	if err := c.config.ReadState(CacheFile, &cache); err != nil {
+		return make(map[string]*Summary)
+	}
+	return cache
+}
+
+func (c *Client) saveCache(cache map[string]*Summary) {
+	for key, summary := range cache {
+		if c.now().Sub(summary.FetchedAt) > cacheRetention {
+			delete(cache, key)
+		}
+	}
+
+	c.config.WriteState(CacheFile, cache)
+}
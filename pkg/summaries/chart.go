@@ -0,0 +1,112 @@
+package summaries
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	barFull  = "█"
+	barEmpty = "░"
+
+	// DefaultBarWidth is the number of cells in a full bar
+	DefaultBarWidth = 30
+)
+
+// Bar is a single labelled value in a chart
+type Bar struct {
+	Label   string
+	Seconds float64
+}
+
+// RenderBars draws a horizontal bar chart scaled to the largest value. Only
+// the first limit bars are drawn (all of them if limit <= 0); the rest are
+// folded into an "Other" bar.
+func RenderBars(w io.Writer, title string, bars []Bar, width, limit int) {
+	fmt.Fprintln(w, title)
+	fmt.Fprintln(w, strings.Repeat("=", utf8.RuneCountInString(title)))
+
+	if len(bars) == 0 {
+		fmt.Fprintln(w, "No activity")
+		return
+	}
+
+	if limit > 0 && len(bars) > limit {
+		other := Bar{Label: "Other"}
+		for _, bar := range bars[limit:] {
+			other.Seconds += bar.Seconds
+		}
+		bars = append(append([]Bar{}, bars[:limit]...), other)
+	}
+
+	var maxSeconds float64
+	labelWidth := 0
+	for _, bar := range bars {
+		if bar.Seconds > maxSeconds {
+			maxSeconds = bar.Seconds
+		}
+		if n := utf8.RuneCountInString(bar.Label); n > labelWidth {
+			labelWidth = n
+		}
+	}
+
+	for _, bar := range bars {
+		filled := 0
+		if maxSeconds > 0 {
+			filled = int(bar.Seconds/maxSeconds*float64(width) + 0.5)
+		}
+		if filled == 0 && bar.Seconds > 0 {
+			filled = 1
+		}
+
+		padding := strings.Repeat(" ", labelWidth-utf8.RuneCountInString(bar.Label))
+		fmt.Fprintf(w, "%s%s  %s%s  %s\n",
+			bar.Label, padding,
+			strings.Repeat(barFull, filled), strings.Repeat(barEmpty, width-filled),
+			FormatDuration(time.Duration(bar.Seconds*float64(time.Second))))
+	}
+}
+
+// ItemBars converts ranked items to chart bars
+func ItemBars(items []Item) []Bar {
+	bars := make([]Bar, len(items))
+	for i, item := range items {
+		bars[i] = Bar{Label: item.Name, Seconds: item.TotalSeconds}
+	}
+	return bars
+}
+
+// DayBars converts daily totals to chart bars labelled with the weekday
+func DayBars(days []Day) []Bar {
+	bars := make([]Bar, 0, len(days))
+	for _, day := range days {
+		label := day.Date
+		if date, err := time.Parse(dateFormat, day.Date); err == nil {
+			label = date.Format("Mon 02 Jan")
+		}
+		bars = append(bars, Bar{Label: label, Seconds: day.TotalSeconds})
+	}
+	return bars
+}
+
+// FormatDuration formats d like the WakaTime dashboard, e.g. "3 hrs 12 mins"
+func FormatDuration(d time.Duration) string {
+	minutes := int(d.Round(time.Minute).Minutes())
+	hours := minutes / 60
+	minutes %= 60
+
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+
+	if hours == 0 {
+		return plural(minutes, "min")
+	}
+	return plural(hours, "hr") + " " + plural(minutes, "min")
+}
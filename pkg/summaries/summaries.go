@@ -0,0 +1,272 @@
+package summaries
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+	"github.com/hackclub/terminal-wakatime/pkg/shell"
+	"github.com/hackclub/terminal-wakatime/pkg/wakatime"
+)
+
+const (
+	// CacheFile stores recent summaries in the WakaTime directory
+	CacheFile = "summaries_cache.json"
+
+	// CacheTTL is how long a cached summary is shown without refetching
+	CacheTTL = 5 * time.Minute
+
+	dateFormat = "2006-01-02"
+)
+
+// Item is the time spent on one project or language
+type Item struct {
+	Name         string  `json:"name"`
+	TotalSeconds float64 `json:"total_seconds"`
+	Percent      float64 `json:"percent"`
+}
+
+// Day is the total for a single day of a range
+type Day struct {
+	Date         string  `json:"date"`
+	TotalSeconds float64 `json:"total_seconds"`
+}
+
+// Summary is the server-side time for a date range
+type Summary struct {
+	Start        string    `json:"start"`
+	End          string    `json:"end"`
+	TotalSeconds float64   `json:"total_seconds"`
+	Days         []Day     `json:"days"`
+	Projects     []Item    `json:"projects"`
+	Languages    []Item    `json:"languages"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	Cached       bool      `json:"cached"`
+}
+
+// Total returns the summary's grand total
+func (s *Summary) Total() time.Duration {
+	return time.Duration(s.TotalSeconds * float64(time.Second))
+}
+
+// apiResponse is the subset of the summaries endpoint we use
+type apiResponse struct {
+	Data []struct {
+		GrandTotal struct {
+			TotalSeconds float64 `json:"total_seconds"`
+		} `json:"grand_total"`
+		Projects  []Item `json:"projects"`
+		Languages []Item `json:"languages"`
+		Range     struct {
+			Date string `json:"date"`
+		} `json:"range"`
+	} `json:"data"`
+}
+
+type Client struct {
+	config     *config.Config
+	cli        *wakatime.CLI
+	httpClient *http.Client
+	netErr     error
+	now        func() time.Time
+}
+
+func NewClient(cfg *config.Config) *Client {
+	httpClient, netErr := cfg.HTTPClient(10 * time.Second)
+
+	return &Client{
+		config:     cfg,
+		cli:        wakatime.NewCLI(cfg),
+		httpClient: httpClient,
+		netErr:     netErr,
+		now:        time.Now,
+	}
+}
+
+// Today returns today's summary. If the summaries endpoint can't be reached,
+// the grand total falls back to `wakatime-cli --today`, which honours the
+// proxy and offline settings in ~/.wakatime.cfg.
+func (c *Client) Today(refresh bool) (*Summary, error) {
+	today := c.now()
+	summary, err := c.Range(today, today, refresh)
+
+	if err != nil {
+		if seconds, cliErr := c.cliTodaySeconds(); cliErr == nil {
+			if summary == nil {
+				date := today.Format(dateFormat)
+				summary = &Summary{Start: date, End: date, FetchedAt: c.now()}
+			}
+			summary.TotalSeconds = seconds
+			err = nil
+		}
+	}
+
+	return summary, err
+}
+
+// Week returns the summary for the last seven days, including today
+func (c *Client) Week(refresh bool) (*Summary, error) {
+	today := c.now()
+	return c.Range(today.AddDate(0, 0, -6), today, refresh)
+}
+
+// Range returns the summary for start..end (inclusive dates), using the
+// cache unless refresh is set. A stale cached summary is returned together
+// with the error if fetching fails.
+func (c *Client) Range(start, end time.Time, refresh bool) (*Summary, error) {
+	key := start.Format(dateFormat) + ".." + end.Format(dateFormat)
+	cache := c.loadCache()
+
+	cached, ok := cache[key]
+	if ok && !refresh && c.now().Sub(cached.FetchedAt) < CacheTTL {
+		cached.Cached = true
+		return cached, nil
+	}
+
+	summary, err := c.fetch(start, end)
+	if err != nil {
+		if ok {
+			cached.Cached = true
+			return cached, err
+		}
+		return nil, err
+	}
+
+	cache[key] = summary
+	c.saveCache(cache)
+	return summary, nil
+}
+
+func (c *Client) fetch(start, end time.Time) (*Summary, error) {
+	if c.config.APIKey == "" {
+		return nil, fmt.Errorf("no API key configured")
+	}
+
+	if c.netErr != nil {
+		return nil, c.netErr
+	}
+
+	query := url.Values{}
+	query.Set("start", start.Format(dateFormat))
+	query.Set("end", end.Format(dateFormat))
+
+	endpoint := strings.TrimRight(c.config.APIUrl, "/") + "/users/current/summaries?" + query.Encode()
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(c.config.APIKey)))
+	req.Header.Set("User-Agent", shell.FormatPluginString(config.PluginName, config.PluginVersion))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch summaries: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("summaries request failed: %s", resp.Status)
+	}
+
+	var body apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to parse summaries: %w", err)
+	}
+
+	summary := &Summary{
+		Start:     start.Format(dateFormat),
+		End:       end.Format(dateFormat),
+		Days:      []Day{},
+		FetchedAt: c.now(),
+	}
+
+	projects := make(map[string]float64)
+	languages := make(map[string]float64)
+	for _, day := range body.Data {
+		summary.TotalSeconds += day.GrandTotal.TotalSeconds
+		summary.Days = append(summary.Days, Day{Date: day.Range.Date, TotalSeconds: day.GrandTotal.TotalSeconds})
+		for _, project := range day.Projects {
+			projects[project.Name] += project.TotalSeconds
+		}
+		for _, language := range day.Languages {
+			languages[language.Name] += language.TotalSeconds
+		}
+	}
+
+	summary.Projects = rank(projects, summary.TotalSeconds)
+	summary.Languages = rank(languages, summary.TotalSeconds)
+	return summary, nil
+}
+
+// cliTodaySeconds reads today's total from `wakatime-cli --today --output json`
+func (c *Client) cliTodaySeconds() (float64, error) {
+	if !c.cli.IsInstalled() {
+		return 0, fmt.Errorf("wakatime-cli is not installed")
+	}
+
+	output, err := c.cli.TodayJSON()
+	if err != nil {
+		return 0, err
+	}
+
+	return parseCLIToday(output)
+}
+
+// parseCLIToday accepts both the status bar shape
+// ({"data": {"grand_total": {...}}}) and a bare grand total
+func parseCLIToday(output []byte) (float64, error) {
+	var body struct {
+		Data struct {
+			GrandTotal struct {
+				TotalSeconds *float64 `json:"total_seconds"`
+			} `json:"grand_total"`
+		} `json:"data"`
+		GrandTotal struct {
+			TotalSeconds *float64 `json:"total_seconds"`
+		} `json:"grand_total"`
+	}
+
+	if err := json.Unmarshal(output, &body); err != nil {
+		return 0, fmt.Errorf("failed to parse wakatime-cli output: %w", err)
+	}
+
+	switch {
+	case body.Data.GrandTotal.TotalSeconds != nil:
+		return *body.Data.GrandTotal.TotalSeconds, nil
+	case body.GrandTotal.TotalSeconds != nil:
+		return *body.GrandTotal.TotalSeconds, nil
+	}
+
+	return 0, fmt.Errorf("wakatime-cli output has no grand total")
+}
+
+// rank sorts totals by time, longest first, and computes percentages
+func rank(totals map[string]float64, grandTotal float64) []Item {
+	items := make([]Item, 0, len(totals))
+	for name, seconds := range totals {
+		if seconds <= 0 {
+			continue
+		}
+		item := Item{Name: name, TotalSeconds: seconds}
+		if grandTotal > 0 {
+			item.Percent = seconds / grandTotal * 100
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].TotalSeconds != items[j].TotalSeconds {
+			return items[i].TotalSeconds > items[j].TotalSeconds
+		}
+		return items[i].Name < items[j].Name
+	})
+
+	return items
+}
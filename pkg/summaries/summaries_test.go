@@ -0,0 +1,230 @@
+package summaries
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+)
+
+const summariesResponse = `{
+  "data": [
+    {
+      "grand_total": {"total_seconds": 3600, "text": "1 hr"},
+      "projects": [{"name": "api", "total_seconds": 2400}, {"name": "web", "total_seconds": 1200}],
+      "languages": [{"name": "Go", "total_seconds": 3000}, {"name": "Bash", "total_seconds": 600}],
+      "range": {"date": "2024-05-09"}
+    },
+    {
+      "grand_total": {"total_seconds": 1800, "text": "30 mins"},
+      "projects": [{"name": "web", "total_seconds": 1800}],
+      "languages": [{"name": "TypeScript", "total_seconds": 1800}],
+      "range": {"date": "2024-05-10"}
+    }
+  ]
+}`
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *int) {
+	t.Helper()
+
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	t.Cleanup(func() { os.Setenv("HOME", originalHome) })
+	os.Setenv("HOME", tempDir)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+	cfg.APIKey = "waka_12345678-1234-1234-1234-123456789abc"
+	cfg.APIUrl = server.URL + "/api/v1"
+
+	client := NewClient(cfg)
+	now := time.Date(2024, 5, 10, 15, 0, 0, 0, time.Local)
+	client.now = func() time.Time { return now }
+
+	return client, &requests
+}
+
+func TestWeek(t *testing.T) {
+	var gotQuery, gotPath, gotAuth string
+	client, requests := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotQuery = r.URL.RawQuery
+		gotAuth = r.Header.Get("Authorization")
+		w.Write([]byte(summariesResponse))
+	})
+
+	summary, err := client.Week(false)
+	if err != nil {
+		t.Fatalf("Week() failed: %v", err)
+	}
+
+	if gotPath != "/api/v1/users/current/summaries" {
+		t.Errorf("Unexpected path %s", gotPath)
+	}
+	if gotQuery != "end=2024-05-10&start=2024-05-04" {
+		t.Errorf("Unexpected query %s", gotQuery)
+	}
+	if !strings.HasPrefix(gotAuth, "Basic ") {
+		t.Errorf("Expected basic auth, got %q", gotAuth)
+	}
+
+	if summary.Total() != 90*time.Minute {
+		t.Errorf("Expected 1h30m total, got %v", summary.Total())
+	}
+	if len(summary.Days) != 2 || summary.Days[1].Date != "2024-05-10" {
+		t.Errorf("Unexpected days %+v", summary.Days)
+	}
+
+	// Projects are summed across days and ranked
+	if len(summary.Projects) != 2 || summary.Projects[0].Name != "web" || summary.Projects[0].TotalSeconds != 3000 {
+		t.Errorf("Unexpected projects %+v", summary.Projects)
+	}
+	if summary.Languages[0].Name != "Go" || summary.Languages[0].Percent < 55.5 || summary.Languages[0].Percent > 55.6 {
+		t.Errorf("Unexpected languages %+v", summary.Languages)
+	}
+
+	// A second call is served from the cache
+	summary, err = client.Week(false)
+	if err != nil {
+		t.Fatalf("Cached Week() failed: %v", err)
+	}
+	if !summary.Cached || *requests != 1 {
+		t.Errorf("Expected cached summary without a request, got cached=%t requests=%d", summary.Cached, *requests)
+	}
+
+	// --refresh bypasses it
+	if _, err := client.Week(true); err != nil {
+		t.Fatalf("Refreshed Week() failed: %v", err)
+	}
+	if *requests != 2 {
+		t.Errorf("Expected refresh to fetch again, got %d requests", *requests)
+	}
+}
+
+func TestRangeFallsBackToStaleCache(t *testing.T) {
+	failing := false
+	client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(summariesResponse))
+	})
+
+	if _, err := client.Week(false); err != nil {
+		t.Fatalf("Week() failed: %v", err)
+	}
+
+	failing = true
+	later := client.now().Add(CacheTTL + time.Minute)
+	client.now = func() time.Time { return later }
+
+	summary, err := client.Week(false)
+	if err == nil {
+		t.Error("Expected the failed refresh to be reported")
+	}
+	if summary == nil || !summary.Cached || summary.Total() != 90*time.Minute {
+		t.Errorf("Expected the stale cached summary, got %+v", summary)
+	}
+}
+
+func TestTodayFallsBackToCLI(t *testing.T) {
+	client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	script := "#!/bin/sh\n" +
+		"case \"$*\" in\n" +
+		"  --version) echo v1.73.0 ;;\n" +
+		"  *--today*) echo '{\"data\":{\"grand_total\":{\"total_seconds\":720,\"text\":\"12 mins\"}}}' ;;\n" +
+		"esac\n"
+	if err := os.MkdirAll(filepath.Dir(client.cli.BinaryPath()), 0755); err != nil {
+		t.Fatalf("Failed to create wakatime dir: %v", err)
+	}
+	if err := os.WriteFile(client.cli.BinaryPath(), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake wakatime-cli: %v", err)
+	}
+
+	summary, err := client.Today(false)
+	if err != nil {
+		t.Fatalf("Today() failed: %v", err)
+	}
+	if summary.Total() != 12*time.Minute || summary.Start != "2024-05-10" {
+		t.Errorf("Expected 12m from wakatime-cli, got %+v", summary)
+	}
+}
+
+func TestParseCLIToday(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+		wantErr  bool
+	}{
+		{`{"data":{"grand_total":{"total_seconds":60}}}`, 60, false},
+		{`{"grand_total":{"total_seconds":0}}`, 0, false},
+		{`{"text":"1 min"}`, 0, true},
+		{`1 min`, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCLIToday([]byte(tt.input))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCLIToday(%s) error = %v, wantErr %t", tt.input, err, tt.wantErr)
+		}
+		if got != tt.expected {
+			t.Errorf("parseCLIToday(%s) = %v, want %v", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestRenderBars(t *testing.T) {
+	bars := []Bar{
+		{Label: "api", Seconds: 7200},
+		{Label: "website", Seconds: 3600},
+		{Label: "docs", Seconds: 60},
+		{Label: "misc", Seconds: 30},
+	}
+
+	var buf bytes.Buffer
+	RenderBars(&buf, "Projects", bars, 10, 3)
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+
+	expected := []string{
+		"Projects",
+		"========",
+		"api      ██████████  2 hrs 0 mins",
+		"website  █████░░░░░  1 hr 0 mins",
+		"docs     █░░░░░░░░░  1 min",
+		"Other    █░░░░░░░░░  1 min",
+	}
+
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got:\n%s", len(expected), buf.String())
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("Line %d = %q, want %q", i, lines[i], expected[i])
+		}
+	}
+
+	buf.Reset()
+	RenderBars(&buf, "Languages", nil, 10, 0)
+	if !strings.Contains(buf.String(), "No activity") {
+		t.Errorf("Expected empty chart message, got %q", buf.String())
+	}
+}
@@ -0,0 +1,199 @@
+package tracker
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/ssh"
+)
+
+const (
+	// How long a remote waits to hand a heartbeat over before sending it
+	// itself
+	forwardDialTimeout = time.Second
+	forwardTimeout     = 5 * time.Second
+
+	forwardAck = "ok"
+
+	// How many acknowledged heartbeats wait for wakatime-cli before
+	// connections stop reading more
+	forwardQueueSize = 64
+)
+
+// forwardSocketDir is where `terminal-wakatime ssh` asks sshd to put the
+// forwarded socket. It is a variable so tests can use their own.
+var forwardSocketDir = "/tmp"
+
+// ForwardSocketPath returns the socket on a remote machine through which
+// user's heartbeats reach the local terminal-wakatime of session id. Each
+// session has its own, as sshd leaves them behind when it disconnects and
+// won't bind over an old one. Anyone can create a file by that name in
+// /tmp, so only sockets that are the user's alone are used.
+func ForwardSocketPath(user, id string) string {
+	return filepath.Join(forwardSocketDir, "terminal-wakatime-"+user+"-"+id+".sock")
+}
+
+// forwardActivity hands activity to the terminal-wakatime this machine was
+// reached from, if an ssh session forwards one. It reports whether that
+// one took the heartbeat; if not, it has to be sent from here.
+func (t *Tracker) forwardActivity(activity *Activity) bool {
+	matches, _ := filepath.Glob(ForwardSocketPath(ssh.LocalUser(), "*"))
+
+	// Another user's socket would receive every file, project and branch,
+	// and could drop them by acknowledging
+	var sockets []string
+	modified := make(map[string]time.Time, len(matches))
+	for _, socket := range matches {
+		if info, err := os.Lstat(socket); err == nil && privateSocket(info) {
+			sockets = append(sockets, socket)
+			modified[socket] = info.ModTime()
+		}
+	}
+	if len(sockets) == 0 {
+		return false
+	}
+
+	// The newest session is the likeliest to be connected
+	sort.Slice(sockets, func(i, j int) bool { return modified[sockets[i]].After(modified[sockets[j]]) })
+
+	for _, socket := range sockets {
+		conn, err := net.DialTimeout("unix", socket, forwardDialTimeout)
+		if err != nil {
+			// Nothing listens on a disconnected session's socket
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				os.Remove(socket)
+			}
+			continue
+		}
+
+		forwarded := forwardOver(conn, activity)
+		conn.Close()
+		if forwarded {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardOver sends activity over conn and waits for the acknowledgement
+func forwardOver(conn net.Conn, activity *Activity) bool {
+	conn.SetDeadline(time.Now().Add(forwardTimeout))
+
+	forwarded := *activity
+	if forwarded.Hostname == "" {
+		forwarded.Hostname, _ = os.Hostname()
+	}
+
+	data, err := json.Marshal(forwarded)
+	if err != nil {
+		return false
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return false
+	}
+
+	// sshd accepts even when the other end is gone, so only the
+	// acknowledgement shows the heartbeat arrived
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	return err == nil && strings.TrimSpace(reply) == forwardAck
+}
+
+// ServeForwarded sends the heartbeats remote machines forward over
+// listener, one JSON activity per line, until the listener is closed. Each
+// connection is served on its own, so one slow remote doesn't hold up the
+// others past forwardTimeout. The heartbeats are sent one at a time,
+// after they are acknowledged; ServeForwarded returns once the
+// acknowledged ones have all been sent.
+func (t *Tracker) ServeForwarded(listener net.Listener) error {
+	queue := make(chan *Activity, forwardQueueSize)
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for activity := range queue {
+			t.sendForwarded(activity)
+		}
+	}()
+
+	var conns sync.WaitGroup
+	defer func() {
+		conns.Wait()
+		close(queue)
+		<-sent
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			serveForwardedConn(conn, queue)
+		}()
+	}
+}
+
+// serveForwardedConn reads the heartbeats from conn and queues the valid
+// ones to be sent
+func serveForwardedConn(conn net.Conn, queue chan<- *Activity) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(forwardTimeout))
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var activity Activity
+		if err := json.Unmarshal(scanner.Bytes(), &activity); err != nil || activity.Entity == "" {
+			fmt.Fprintln(conn, "invalid heartbeat")
+			continue
+		}
+
+		// Acknowledge before sending, so a slow wakatime-cli doesn't make
+		// the remote give up and send it a second time
+		fmt.Fprintln(conn, forwardAck)
+		queue <- &activity
+	}
+}
+
+// sendForwarded sends a remote machine's heartbeat as it is: the remote
+// already rate limited it and knows its project. Remote files don't exist
+// here, so they are sent as unsaved, at the time they were edited.
+func (t *Tracker) sendForwarded(activity *Activity) error {
+	if err := t.wakatime.EnsureInstalled(); err != nil {
+		return fmt.Errorf("failed to ensure wakatime-cli is installed: %w", err)
+	}
+
+	err := t.wakatime.SendHeartbeat(
+		activity.Entity,
+		string(activity.EntityType),
+		activity.Category,
+		activity.Language,
+		activity.Project,
+		activity.Branch,
+		activity.Hostname,
+		activity.Timestamp,
+		activity.IsWrite,
+		activity.EntityType == ActivityFile,
+		activity.Lines,
+		activity.LineNo,
+		activity.CursorPos,
+		activity.LineAdditions,
+		activity.LineDeletions,
+	)
+	if err == nil {
+		t.recordHeartbeat(activity)
+	}
+	return err
+}
@@ -0,0 +1,343 @@
+package tracker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+	"github.com/hackclub/terminal-wakatime/pkg/ssh"
+	"github.com/hackclub/terminal-wakatime/pkg/wakatime"
+)
+
+// listenForwardSocket stands in for the forwarded socket in a temporary
+// directory, answering each heartbeat with reply
+func listenForwardSocket(t *testing.T, reply string) <-chan Activity {
+	t.Helper()
+	return listenForwardSocketMode(t, reply, 0600)
+}
+
+// listenForwardSocketMode is listenForwardSocket with the socket's
+// permissions set to mode. sshd creates sockets with mode 0600 by default.
+func listenForwardSocketMode(t *testing.T, reply string, mode os.FileMode) <-chan Activity {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("Heartbeats aren't forwarded from Windows")
+	}
+
+	saved := forwardSocketDir
+	forwardSocketDir = t.TempDir()
+	t.Cleanup(func() { forwardSocketDir = saved })
+
+	path := ForwardSocketPath(ssh.LocalUser(), "test")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatalf("Failed to chmod %s: %v", path, err)
+	}
+
+	received := make(chan Activity, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				var activity Activity
+				if json.Unmarshal(scanner.Bytes(), &activity) == nil {
+					received <- activity
+				}
+				fmt.Fprintln(conn, reply)
+			}
+			conn.Close()
+		}
+	}()
+	return received
+}
+
+func TestForwardActivity(t *testing.T) {
+	received := listenForwardSocket(t, forwardAck)
+	tracker := NewTracker(&config.Config{})
+
+	activity := &Activity{
+		Entity:     "/srv/app/main.go",
+		EntityType: ActivityFile,
+		Category:   "coding",
+		Project:    "app",
+		Branch:     "main",
+		Timestamp:  time.Now(),
+	}
+	if !tracker.forwardActivity(activity) {
+		t.Fatal("Expected the heartbeat to be forwarded")
+	}
+
+	got := <-received
+	hostname, _ := os.Hostname()
+	if got.Entity != activity.Entity || got.Project != "app" || got.EntityType != ActivityFile || got.Hostname != hostname {
+		t.Errorf("Unexpected forwarded heartbeat %+v", got)
+	}
+}
+
+func TestForwardActivityFallsBack(t *testing.T) {
+	tracker := NewTracker(&config.Config{})
+	activity := &Activity{Entity: "make", EntityType: ActivityApp, Timestamp: time.Now()}
+
+	// No socket
+	saved := forwardSocketDir
+	forwardSocketDir = t.TempDir()
+	if tracker.forwardActivity(activity) {
+		t.Error("Expected nothing to forward to without a socket")
+	}
+	forwardSocketDir = saved
+
+	if runtime.GOOS == "windows" {
+		t.Skip("Heartbeats aren't forwarded from Windows")
+	}
+
+	// A disconnected session's socket is cleaned up
+	forwardSocketDir = t.TempDir()
+	stale := ForwardSocketPath(ssh.LocalUser(), "stale")
+	if listener, err := net.Listen("unix", stale); err == nil {
+		listener.(*net.UnixListener).SetUnlinkOnClose(false)
+		listener.Close()
+		os.Chmod(stale, 0600)
+		if tracker.forwardActivity(activity) {
+			t.Error("Expected nothing to forward to over a stale socket")
+		}
+		if _, err := os.Stat(stale); !os.IsNotExist(err) {
+			t.Error("Expected the stale socket to be removed")
+		}
+	}
+	forwardSocketDir = saved
+
+	// A socket whose other end doesn't acknowledge
+	listenForwardSocket(t, "invalid heartbeat")
+	if tracker.forwardActivity(activity) {
+		t.Error("Expected an unacknowledged heartbeat not to count as forwarded")
+	}
+}
+
+func TestForwardActivitySkipsSharedSockets(t *testing.T) {
+	// Anyone who can connect to the socket could read the heartbeat and
+	// acknowledge it away
+	received := listenForwardSocketMode(t, forwardAck, 0666)
+	tracker := NewTracker(&config.Config{})
+
+	if tracker.forwardActivity(&Activity{Entity: "/srv/app/main.go", EntityType: ActivityFile, Timestamp: time.Now()}) {
+		t.Error("Expected nothing to forward over a socket open to other users")
+	}
+	select {
+	case got := <-received:
+		t.Errorf("Expected the socket not to be connected to, got %+v", got)
+	default:
+	}
+	if _, err := os.Lstat(ForwardSocketPath(ssh.LocalUser(), "test")); err != nil {
+		t.Errorf("Expected a socket that isn't ours to be left alone: %v", err)
+	}
+}
+
+func TestForwardActivitySkipsOtherUsersSockets(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Giving a socket to another user needs root")
+	}
+
+	received := listenForwardSocket(t, forwardAck)
+	if err := os.Lchown(ForwardSocketPath(ssh.LocalUser(), "test"), 65534, 65534); err != nil {
+		t.Fatalf("Failed to chown socket: %v", err)
+	}
+
+	tracker := NewTracker(&config.Config{})
+	if tracker.forwardActivity(&Activity{Entity: "/srv/app/main.go", EntityType: ActivityFile, Timestamp: time.Now()}) {
+		t.Error("Expected nothing to forward over another user's socket")
+	}
+	select {
+	case got := <-received:
+		t.Errorf("Expected the socket not to be connected to, got %+v", got)
+	default:
+	}
+}
+
+func TestSendActivityForwards(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	received := listenForwardSocket(t, forwardAck)
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+
+	// wakatime-cli isn't installed, so only forwarding can succeed
+	tracker := NewTracker(cfg)
+	if err := tracker.sendActivity(&Activity{Entity: "cargo", EntityType: ActivityApp, Category: "building", Project: "api", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("sendActivity() failed: %v", err)
+	}
+
+	if got := <-received; got.Entity != "cargo" {
+		t.Errorf("Expected cargo to be forwarded, got %+v", got)
+	}
+	if record, err := LoadLastHeartbeat(cfg); err != nil || record == nil || record.Entity != "cargo" {
+		t.Errorf("Expected the forwarded heartbeat to be recorded locally, got %+v (err %v)", record, err)
+	}
+}
+
+func TestServeForwardedRejectsInvalid(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	queue := make(chan *Activity, 1)
+	go serveForwardedConn(server, queue)
+
+	fmt.Fprintln(client, "not json")
+	reply, err := bufio.NewReader(client).ReadString('\n')
+	if err != nil || strings.TrimSpace(reply) == forwardAck {
+		t.Errorf("Expected invalid input to be refused, got %q (err %v)", reply, err)
+	}
+	if len(queue) != 0 {
+		t.Error("Expected invalid input not to be queued")
+	}
+}
+
+func TestServeForwardedKeepsTimestamp(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	queue := make(chan *Activity, 1)
+	go serveForwardedConn(server, queue)
+
+	edited := time.Now().Add(-3 * time.Second).Round(0)
+	data, _ := json.Marshal(Activity{Entity: "/srv/app/main.go", EntityType: ActivityFile, Timestamp: edited})
+	fmt.Fprintln(client, string(data))
+
+	reply, err := bufio.NewReader(client).ReadString('\n')
+	if err != nil || strings.TrimSpace(reply) != forwardAck {
+		t.Fatalf("Expected the heartbeat to be acknowledged, got %q (err %v)", reply, err)
+	}
+	if got := <-queue; !got.Timestamp.Equal(edited) {
+		t.Errorf("Expected the heartbeat to keep its time %v, got %v", edited, got.Timestamp)
+	}
+}
+
+func TestServeForwardedServesConnectionsConcurrently(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Heartbeats aren't forwarded from Windows")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+	cfg.CLIReleasesURL = "http://127.0.0.1:1/releases/latest"
+
+	path := filepath.Join(t.TempDir(), "forward.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	served := make(chan struct{})
+	go func() {
+		NewTracker(cfg).ServeForwarded(listener)
+		close(served)
+	}()
+
+	// A remote that connected but hasn't sent anything yet
+	idle, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	activity := &Activity{Entity: "/srv/app/main.go", EntityType: ActivityFile, Timestamp: time.Now()}
+	start := time.Now()
+	if !forwardOver(conn, activity) {
+		t.Fatal("Expected the heartbeat to be acknowledged")
+	}
+	if elapsed := time.Since(start); elapsed >= forwardTimeout {
+		t.Errorf("Expected the acknowledgement not to wait for the idle connection, took %v", elapsed)
+	}
+
+	idle.Close()
+	conn.Close()
+	listener.Close()
+	select {
+	case <-served:
+	case <-time.After(2 * forwardTimeout):
+		t.Error("Expected ServeForwarded() to return once the listener is closed")
+	}
+}
+
+func TestServeForwardedSendsAcknowledgedBeforeReturning(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binary is a shell script")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PATH", "/usr/bin:/bin")
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+	cfg.CLIReleasesURL = "http://127.0.0.1:1/releases/latest"
+
+	// A wakatime-cli slow enough that the session ends before it is done
+	sentFile := filepath.Join(t.TempDir(), "sent")
+	binPath := wakatime.NewCLI(cfg).BinaryPath()
+	os.MkdirAll(filepath.Dir(binPath), 0755)
+	script := "#!/bin/sh\n[ \"$1\" = --version ] && exit 0\nsleep 0.2\necho \"$@\" >> " + sentFile + "\n"
+	if err := os.WriteFile(binPath, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake wakatime-cli: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "forward.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	served := make(chan struct{})
+	go func() {
+		NewTracker(cfg).ServeForwarded(listener)
+		close(served)
+	}()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if !forwardOver(conn, &Activity{Entity: "/srv/app/main.go", EntityType: ActivityFile, Timestamp: time.Now()}) {
+		t.Fatal("Expected the heartbeat to be acknowledged")
+	}
+
+	// The session ends right after the acknowledgement
+	conn.Close()
+	listener.Close()
+	select {
+	case <-served:
+	case <-time.After(2 * forwardTimeout):
+		t.Fatal("Expected ServeForwarded() to return once the listener is closed")
+	}
+
+	sent, _ := os.ReadFile(sentFile)
+	if !strings.Contains(string(sent), "/srv/app/main.go") {
+		t.Errorf("Expected the acknowledged heartbeat to be sent before returning, got %q", sent)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package tracker
+
+import (
+	"os"
+	"syscall"
+)
+
+// privateSocket reports whether info is a socket owned by the current user
+// that nobody else can connect to, as sshd creates forwarded sockets
+func privateSocket(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok &&
+		info.Mode()&os.ModeSocket != 0 &&
+		int(stat.Uid) == os.Getuid() &&
+		info.Mode().Perm()&0077 == 0
+}
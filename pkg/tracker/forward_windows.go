@@ -0,0 +1,12 @@
+//go:build windows
+
+package tracker
+
+import "os"
+
+// privateSocket reports whether info is a socket only the current user can
+// use. Ownership can't be checked here, so nothing is forwarded from
+// Windows.
+func privateSocket(info os.FileInfo) bool {
+	return false
+}
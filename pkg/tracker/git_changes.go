@@ -0,0 +1,212 @@
+package tracker
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// GitSnapshot is the repository state the shell hooks record just before
+// a git command runs, so the tracker can tell afterwards what it changed
+type GitSnapshot struct {
+	// Head is the commit HEAD pointed at, "" on an unborn branch
+	Head string
+
+	// Upstream is the commit of the branch's upstream, "" if it has none
+	Upstream string
+}
+
+// ParseGitSnapshot parses the hooks' "<head> <upstream>" value, where "-"
+// stands for a missing commit. It returns nil if nothing was captured.
+func ParseGitSnapshot(value string) *GitSnapshot {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return nil
+	}
+
+	snapshot := &GitSnapshot{}
+	if fields[0] != "-" {
+		snapshot.Head = fields[0]
+	}
+	if fields[1] != "-" {
+		snapshot.Upstream = fields[1]
+	}
+	return snapshot
+}
+
+// SetGitSnapshot records the state captured before the command that is
+// about to be tracked
+func (t *Tracker) SetGitSnapshot(snapshot *GitSnapshot) {
+	t.gitSnapshot = snapshot
+}
+
+type GitFileChange struct {
+	// FilePath is absolute; OldPath is set when the file was renamed
+	FilePath      string
+	OldPath       string
+	LineAdditions int
+	LineDeletions int
+	Binary        bool
+}
+
+// gitChangeRange returns the commits to diff for what subcommand did: the
+// committed tree for commit, the pushed range for push and the old tip for
+// merge, rebase and pull. from is "" to diff to against its parent, which
+// also works for a repository's first commit. ok is false when the command
+// changed nothing or its range can't be known.
+func gitChangeRange(dir, subcommand string, before *GitSnapshot) (from, to string, ok bool) {
+	head := gitHeadCommit(dir)
+	if head == "" {
+		return "", "", false
+	}
+
+	switch subcommand {
+	case "commit":
+		if before == nil {
+			return "", head, true
+		}
+		if before.Head == head {
+			return "", "", false // nothing was committed
+		}
+		return before.Head, head, true
+
+	case "push":
+		if before != nil {
+			// Without an upstream beforehand the branch is new on the
+			// remote and there's no base to diff against
+			if before.Upstream == "" {
+				return "", "", false
+			}
+			return before.Upstream, head, before.Upstream != head
+		}
+
+		// The remote-tracking branch's reflog remembers where it was
+		old := gitRevParse(dir, "@{u}@{1}")
+		upstream := gitRevParse(dir, "@{u}")
+		if old == "" || upstream == "" || old == upstream {
+			return "", "", false
+		}
+		return old, upstream, true
+
+	case "merge", "rebase", "pull":
+		if before != nil && before.Head == head {
+			return "", "", false // already up to date, ORIG_HEAD is stale
+		}
+		origHead := gitRevParse(dir, "ORIG_HEAD")
+		if origHead == "" || origHead == head {
+			return "", "", false
+		}
+		return origHead, head, true
+	}
+
+	return "", "", false
+}
+
+// getGitChangedFiles returns the files changed between two commits with
+// their line changes. With from "", to is diffed against its parent, or
+// against the empty tree for a root commit.
+func getGitChangedFiles(dir, from, to string) ([]GitFileChange, error) {
+	args := []string{"diff", "--numstat", "-z", "-M", from, to}
+	if from == "" {
+		args = []string{"diff-tree", "-r", "--root", "--no-commit-id", "--numstat", "-z", "-M", to}
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	root := gitTopLevel(dir)
+	if root == "" {
+		root = dir
+	}
+
+	changes := parseNumstat(output)
+	for i := range changes {
+		changes[i].FilePath = filepath.Join(root, filepath.FromSlash(changes[i].FilePath))
+		if changes[i].OldPath != "" {
+			changes[i].OldPath = filepath.Join(root, filepath.FromSlash(changes[i].OldPath))
+		}
+	}
+	return changes, nil
+}
+
+// parseNumstat parses `git diff --numstat -z`: "added\tdeleted\tpath\0",
+// or for a rename "added\tdeleted\t\0old\0new\0". Binary files report "-"
+// for both counts. Paths are relative to the repository root.
+func parseNumstat(output []byte) []GitFileChange {
+	var changes []GitFileChange
+
+	records := bytes.Split(output, []byte{0})
+	for i := 0; i < len(records); i++ {
+		record := string(records[i])
+		if record == "" {
+			continue
+		}
+
+		parts := strings.SplitN(record, "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		change := GitFileChange{FilePath: parts[2]}
+		if parts[0] == "-" && parts[1] == "-" {
+			change.Binary = true
+		} else {
+			change.LineAdditions, _ = strconv.Atoi(parts[0])
+			change.LineDeletions, _ = strconv.Atoi(parts[1])
+		}
+
+		if change.FilePath == "" {
+			if i+2 >= len(records) {
+				break
+			}
+			change.OldPath = string(records[i+1])
+			change.FilePath = string(records[i+2])
+			i += 2
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// gitCommandChanges returns the files a git write command changed, or nil
+func gitCommandChanges(subcommand, dir string, before *GitSnapshot) []GitFileChange {
+	from, to, ok := gitChangeRange(dir, subcommand, before)
+	if !ok {
+		return nil
+	}
+
+	changes, err := getGitChangedFiles(dir, from, to)
+	if err != nil {
+		return nil
+	}
+	return changes
+}
+
+// gitRevParse resolves rev to a commit, or "" if it doesn't exist
+func gitRevParse(dir, rev string) string {
+	cmd := exec.Command("git", "rev-parse", "-q", "--verify", rev+"^{commit}")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func gitTopLevel(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
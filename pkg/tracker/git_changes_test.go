@@ -0,0 +1,234 @@
+package tracker
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseNumstat(t *testing.T) {
+	output := "3\t1\tsrc/main.go\x00" +
+		"-\t-\tassets/logo.png\x00" +
+		"2\t0\t\x00old name.go\x00new name.go\x00" +
+		"0\t4\tREADME.md\x00"
+
+	expected := []GitFileChange{
+		{FilePath: "src/main.go", LineAdditions: 3, LineDeletions: 1},
+		{FilePath: "assets/logo.png", Binary: true},
+		{FilePath: "new name.go", OldPath: "old name.go", LineAdditions: 2},
+		{FilePath: "README.md", LineDeletions: 4},
+	}
+
+	if changes := parseNumstat([]byte(output)); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, changes)
+	}
+}
+
+func TestParseGitSnapshot(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected *GitSnapshot
+	}{
+		{"", nil},
+		{"abc", nil},
+		{"abc def", &GitSnapshot{Head: "abc", Upstream: "def"}},
+		{"abc -", &GitSnapshot{Head: "abc"}},
+		{"- -", &GitSnapshot{}},
+	}
+
+	for _, tt := range tests {
+		if got := ParseGitSnapshot(tt.value); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("ParseGitSnapshot(%q) = %+v, expected %+v", tt.value, got, tt.expected)
+		}
+	}
+}
+
+// gitRepo creates a repository with a bare remote for the git range tests
+func gitRepo(t *testing.T) (string, func(args ...string) string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	base := t.TempDir()
+	dir := filepath.Join(base, "work")
+
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	for _, args := range [][]string{
+		{"init", "-q", "--bare", filepath.Join(base, "remote.git")},
+		{"init", "-q", "-b", "main", dir},
+	} {
+		if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+	git("remote", "add", "origin", filepath.Join(base, "remote.git"))
+
+	return dir, git
+}
+
+func writeRepoFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// rangeChanges summarizes the changes of a subcommand as sorted
+// "path +added -deleted" strings, with paths relative to the repository
+func rangeChanges(t *testing.T, dir, subcommand string, before *GitSnapshot) []string {
+	t.Helper()
+
+	from, to, ok := gitChangeRange(dir, subcommand, before)
+	if !ok {
+		return nil
+	}
+
+	changes, err := getGitChangedFiles(dir, from, to)
+	if err != nil {
+		t.Fatalf("getGitChangedFiles failed: %v", err)
+	}
+
+	root, _ := filepath.EvalSymlinks(dir)
+	var summary []string
+	for _, change := range changes {
+		path, _ := filepath.EvalSymlinks(change.FilePath)
+		rel, _ := filepath.Rel(root, path)
+		entry := filepath.ToSlash(rel)
+		if change.OldPath != "" {
+			entry = filepath.Base(change.OldPath) + "=>" + entry
+		}
+		if change.Binary {
+			entry += " binary"
+		} else {
+			entry += fmt.Sprintf(" +%d -%d", change.LineAdditions, change.LineDeletions)
+		}
+		summary = append(summary, entry)
+	}
+	sort.Strings(summary)
+	return summary
+}
+
+func TestGitChangeRangeCommit(t *testing.T) {
+	dir, git := gitRepo(t)
+
+	// The first commit has no parent to diff against
+	writeRepoFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+
+	expected := []string{"main.go +3 -0"}
+	if got := rangeChanges(t, dir, "commit", &GitSnapshot{}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("First commit: expected %v, got %v", expected, got)
+	}
+	if got := rangeChanges(t, dir, "commit", nil); !reflect.DeepEqual(got, expected) {
+		t.Errorf("First commit without a snapshot: expected %v, got %v", expected, got)
+	}
+
+	// A failed commit leaves HEAD alone and changes nothing
+	head := git("rev-parse", "HEAD")
+	if got := rangeChanges(t, dir, "commit", &GitSnapshot{Head: head}); got != nil {
+		t.Errorf("Expected no changes when HEAD didn't move, got %v", got)
+	}
+
+	// Renames and binary files
+	git("mv", "main.go", "app.go")
+	writeRepoFile(t, dir, "app.go", "package main\n\nfunc main() {}\n\nfunc run() {}\n")
+	writeRepoFile(t, dir, "logo.png", "\x89PNG\x00\x01\x02")
+	git("add", ".")
+	git("commit", "-q", "-m", "rename")
+
+	expected = []string{"logo.png binary", "main.go=>app.go +2 -0"}
+	if got := rangeChanges(t, dir, "commit", &GitSnapshot{Head: head}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestGitChangeRangePush(t *testing.T) {
+	dir, git := gitRepo(t)
+
+	writeRepoFile(t, dir, "a.txt", "one\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "one")
+	git("push", "-q", "-u", "origin", "main")
+	upstream := git("rev-parse", "@{u}")
+
+	// Two commits pushed together both count
+	writeRepoFile(t, dir, "a.txt", "one\ntwo\n")
+	git("commit", "-q", "-am", "two")
+	writeRepoFile(t, dir, "b.txt", "three\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "three")
+
+	before := &GitSnapshot{Head: git("rev-parse", "HEAD"), Upstream: upstream}
+	git("push", "-q")
+
+	expected := []string{"a.txt +1 -0", "b.txt +1 -0"}
+	if got := rangeChanges(t, dir, "push", before); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// Without a snapshot the remote-tracking reflog gives the same range
+	if got := rangeChanges(t, dir, "push", nil); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Without a snapshot: expected %v, got %v", expected, got)
+	}
+
+	// Nothing to push
+	head := git("rev-parse", "HEAD")
+	if got := rangeChanges(t, dir, "push", &GitSnapshot{Head: head, Upstream: head}); got != nil {
+		t.Errorf("Expected no changes for an up-to-date push, got %v", got)
+	}
+}
+
+func TestGitChangeRangeMerge(t *testing.T) {
+	dir, git := gitRepo(t)
+
+	writeRepoFile(t, dir, "a.txt", "one\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "one")
+
+	git("checkout", "-q", "-b", "feature")
+	writeRepoFile(t, dir, "feature.txt", "new\nfeature\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "feature")
+	git("checkout", "-q", "main")
+
+	before := &GitSnapshot{Head: git("rev-parse", "HEAD")}
+	git("merge", "-q", "feature")
+
+	expected := []string{"feature.txt +2 -0"}
+	if got := rangeChanges(t, dir, "merge", before); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// "Already up to date" leaves ORIG_HEAD from the last merge behind
+	head := git("rev-parse", "HEAD")
+	if got := rangeChanges(t, dir, "merge", &GitSnapshot{Head: head}); got != nil {
+		t.Errorf("Expected no changes for an up-to-date merge, got %v", got)
+	}
+}
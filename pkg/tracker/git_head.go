@@ -0,0 +1,234 @@
+package tracker
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitRepository locates the files of the repository a directory is in,
+// so HEAD can be read without running git
+type gitRepository struct {
+	// gitDir holds HEAD: .git, or a worktree's directory under
+	// .git/worktrees
+	gitDir string
+
+	// commonDir holds refs and packed-refs, shared by all worktrees
+	commonDir string
+}
+
+// findGitRepository returns the repository dir belongs to, or nil. A .git
+// file, as worktrees and submodules have, points at the real git dir with
+// "gitdir: <path>".
+func findGitRepository(dir string) *gitRepository {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			gitDir := dotGit
+			if !info.IsDir() {
+				gitDir = readGitdirFile(dotGit)
+				if gitDir == "" {
+					return nil
+				}
+			}
+			return &gitRepository{gitDir: gitDir, commonDir: gitCommonDir(gitDir)}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// readGitdirFile returns the git dir a .git file points at, relative paths
+// being relative to the file
+func readGitdirFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return ""
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	return filepath.Clean(gitDir)
+}
+
+// gitCommonDir returns the main git dir of a worktree, named by its
+// commondir file, or gitDir itself
+func gitCommonDir(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+
+	commonDir := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return filepath.Clean(commonDir)
+}
+
+// head reads HEAD: the branch it is on and the commit it points at. A
+// detached HEAD's branch is its short commit, and an unborn branch has no
+// commit. ok is false for a HEAD this can't read, such as a reftable
+// repository's placeholder, where git has to be asked instead.
+func (r *gitRepository) head() (branch, commit string, ok bool) {
+	data, err := os.ReadFile(filepath.Join(r.gitDir, "HEAD"))
+	if err != nil {
+		return "", "", false
+	}
+	content := strings.TrimSpace(string(data))
+
+	if ref, symbolic := strings.CutPrefix(content, "ref:"); symbolic {
+		ref = strings.TrimSpace(ref)
+		if ref == "refs/heads/.invalid" {
+			return "", "", false
+		}
+		return strings.TrimPrefix(ref, "refs/heads/"), r.resolveRef(ref), true
+	}
+
+	if isObjectID(content) {
+		return content[:7], content, true
+	}
+	return "", "", false
+}
+
+// resolveRef returns the commit a ref points at, following symbolic refs,
+// from its loose file or packed-refs, or "" if it doesn't exist
+func (r *gitRepository) resolveRef(ref string) string {
+	for range 5 {
+		data, err := os.ReadFile(filepath.Join(r.refDir(ref), filepath.FromSlash(ref)))
+		if err != nil {
+			return r.packedRef(ref)
+		}
+
+		content := strings.TrimSpace(string(data))
+		target, symbolic := strings.CutPrefix(content, "ref:")
+		if !symbolic {
+			if isObjectID(content) {
+				return content
+			}
+			return ""
+		}
+		ref = strings.TrimSpace(target)
+	}
+	return ""
+}
+
+// refDir returns where a ref lives: per-worktree refs in the worktree's
+// git dir, everything else in the common one
+func (r *gitRepository) refDir(ref string) string {
+	if !strings.HasPrefix(ref, "refs/") || strings.HasPrefix(ref, "refs/worktree/") ||
+		strings.HasPrefix(ref, "refs/bisect/") || strings.HasPrefix(ref, "refs/rewritten/") {
+		return r.gitDir
+	}
+	return r.commonDir
+}
+
+// packedRef looks ref up in packed-refs, whose lines are "<id> <ref>",
+// with "#" header lines and "^<id>" lines peeling the tag above
+func (r *gitRepository) packedRef(ref string) string {
+	file, err := os.Open(filepath.Join(r.commonDir, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+		if id, name, ok := strings.Cut(line, " "); ok && name == ref && isObjectID(id) {
+			return id
+		}
+	}
+	return ""
+}
+
+// isObjectID reports whether s is a full SHA-1 or SHA-256 object name
+func isObjectID(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// gitBranch returns the branch checked out for dir, or a detached HEAD's
+// short commit. Repositories are found once per directory; HEAD itself is
+// read every time, as a long-running watcher sees checkouts.
+func (t *Tracker) gitBranch(dir string) string {
+	// GIT_DIR moves the repository somewhere only git knows how to find
+	if os.Getenv("GIT_DIR") != "" {
+		return gitBranchFromCommand(dir)
+	}
+
+	repo, cached := t.repositories[dir]
+	if !cached {
+		repo = findGitRepository(dir)
+		t.repositories[dir] = repo
+	}
+	if repo == nil {
+		return ""
+	}
+
+	branch, _, ok := repo.head()
+	if !ok {
+		return gitBranchFromCommand(dir)
+	}
+	return branch
+}
+
+// gitHeadCommit returns the commit HEAD points at in dir, or ""
+func gitHeadCommit(dir string) string {
+	if os.Getenv("GIT_DIR") == "" {
+		if repo := findGitRepository(dir); repo != nil {
+			if _, commit, ok := repo.head(); ok {
+				return commit
+			}
+		}
+	}
+	return gitRevParse(dir, "HEAD")
+}
+
+// gitBranchFromCommand asks git for the branch, for repositories HEAD
+// can't be read from directly
+func gitBranchFromCommand(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	branch := strings.TrimSpace(string(output))
+	if branch == "HEAD" {
+		cmd = exec.Command("git", "rev-parse", "--short", "HEAD")
+		cmd.Dir = dir
+		if output, err = cmd.Output(); err == nil {
+			branch = strings.TrimSpace(string(output))
+		}
+	}
+	return branch
+}
@@ -0,0 +1,126 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+)
+
+func TestGitBranch(t *testing.T) {
+	dir, git := gitRepo(t)
+	tracker := NewTracker(&config.Config{})
+
+	// An unborn branch has a name but no commit
+	git("checkout", "-q", "-b", "feature/login")
+	if branch := tracker.gitBranch(dir); branch != "feature/login" {
+		t.Errorf("Expected unborn branch feature/login, got %q", branch)
+	}
+
+	writeRepoFile(t, dir, "main.go", "package main\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+
+	sub := filepath.Join(dir, "cmd", "app")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if branch := tracker.gitBranch(sub); branch != "feature/login" {
+		t.Errorf("Expected feature/login from a subdirectory, got %q", branch)
+	}
+
+	// Refs moved into packed-refs still resolve
+	git("pack-refs", "--all")
+	repo := findGitRepository(dir)
+	branch, commit, ok := repo.head()
+	if !ok || branch != "feature/login" || commit != gitRevParse(dir, "HEAD") {
+		t.Errorf("Expected feature/login at HEAD from packed-refs, got %q %q ok=%t", branch, commit, ok)
+	}
+
+	// The cached repository still sees checkouts
+	head := gitRevParse(dir, "HEAD")
+	git("checkout", "-q", "--detach")
+	if branch := tracker.gitBranch(sub); branch != head[:7] {
+		t.Errorf("Expected detached HEAD %q, got %q", head[:7], branch)
+	}
+
+	if branch := tracker.gitBranch(t.TempDir()); branch != "" {
+		t.Errorf("Expected no branch outside a repository, got %q", branch)
+	}
+}
+
+func TestGitBranchWorktree(t *testing.T) {
+	dir, git := gitRepo(t)
+	git("commit", "-q", "--allow-empty", "-m", "initial")
+	git("branch", "hotfix")
+	git("pack-refs", "--all")
+
+	worktree := filepath.Join(t.TempDir(), "hotfix")
+	git("worktree", "add", "-q", worktree, "hotfix")
+
+	repo := findGitRepository(worktree)
+	if repo == nil {
+		t.Fatal("Expected to find the worktree's repository")
+	}
+	if repo.commonDir != filepath.Join(dir, ".git") {
+		t.Errorf("Expected the main .git as common dir, got %q", repo.commonDir)
+	}
+
+	branch, commit, ok := repo.head()
+	if !ok || branch != "hotfix" || commit != gitRevParse(worktree, "HEAD") {
+		t.Errorf("Expected hotfix at HEAD, got %q %q ok=%t", branch, commit, ok)
+	}
+}
+
+func TestGitBranchGitdirFile(t *testing.T) {
+	// Submodules point at their git dir with a relative path
+	root := t.TempDir()
+	gitDir := filepath.Join(root, ".git", "modules", "lib")
+	if err := os.MkdirAll(gitDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lib := filepath.Join(root, "lib")
+	if err := os.MkdirAll(lib, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(lib, ".git"), []byte("gitdir: ../.git/modules/lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := findGitRepository(lib)
+	if repo == nil || repo.gitDir != gitDir || repo.commonDir != gitDir {
+		t.Fatalf("Expected git dir %q, got %+v", gitDir, repo)
+	}
+	if branch, _, ok := repo.head(); !ok || branch != "main" {
+		t.Errorf("Expected branch main, got %q ok=%t", branch, ok)
+	}
+}
+
+func TestDetectProjectCached(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "myapp")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "go.mod"), []byte("module myapp\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := NewTracker(&config.Config{})
+	if got := tracker.detectProject(project); got != "myapp" {
+		t.Fatalf("Expected myapp, got %q", got)
+	}
+
+	// The answer is remembered for the directory
+	if err := os.Remove(filepath.Join(project, "go.mod")); err != nil {
+		t.Fatal(err)
+	}
+	if got := tracker.detectProject(project); got != "myapp" {
+		t.Errorf("Expected the cached myapp, got %q", got)
+	}
+}
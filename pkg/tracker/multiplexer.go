@@ -0,0 +1,118 @@
+package tracker
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Multiplexer identifies the terminal multiplexer pane a command ran in
+type Multiplexer struct {
+	// Name is "tmux", "screen" or "zellij"
+	Name    string `json:"name"`
+	Session string `json:"session,omitempty"`
+	Pane    string `json:"pane,omitempty"`
+}
+
+// tmuxSessionLookup returns the name of the session a tmux pane is in. It
+// is a variable so tests can run without a tmux server.
+var tmuxSessionLookup = func(pane string) string {
+	args := []string{"display-message", "-p"}
+	if pane != "" {
+		args = append(args, "-t", pane)
+	}
+	output, err := exec.Command("tmux", append(args, "#{session_name}")...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// detectMultiplexer reads the variables multiplexers set in their panes.
+// tmux only exports its socket, so the session name is asked of tmux.
+func detectMultiplexer(getenv func(string) string) *Multiplexer {
+	switch {
+	case getenv("TMUX") != "":
+		pane := getenv("TMUX_PANE")
+		return &Multiplexer{Name: "tmux", Session: tmuxSessionLookup(pane), Pane: pane}
+
+	case getenv("ZELLIJ") != "":
+		return &Multiplexer{Name: "zellij", Session: getenv("ZELLIJ_SESSION_NAME"), Pane: getenv("ZELLIJ_PANE_ID")}
+
+	case getenv("STY") != "":
+		// STY is "<pid>.<name>"; the window stands in for the pane
+		_, session, _ := strings.Cut(getenv("STY"), ".")
+		return &Multiplexer{Name: "screen", Session: session, Pane: getenv("WINDOW")}
+	}
+	return nil
+}
+
+// multiplexer returns the multiplexer this process runs in, or nil
+func (t *Tracker) multiplexer() *Multiplexer {
+	if !t.multiplexerChecked {
+		t.multiplexerInfo = detectMultiplexer(os.Getenv)
+		t.multiplexerChecked = true
+	}
+	return t.multiplexerInfo
+}
+
+// sessionProject returns the tmux session name as a project, when that is
+// enabled and the session has a name rather than tmux's default number
+func (t *Tracker) sessionProject() string {
+	if !t.config.TmuxSessionProject {
+		return ""
+	}
+
+	mux := t.multiplexer()
+	if mux == nil || mux.Name != "tmux" || mux.Session == "" || isDigits(mux.Session) {
+		return ""
+	}
+	return mux.Session
+}
+
+// isMultiplexerSession reports whether a command starts, attaches to or
+// detaches from a multiplexer session. These run for as long as the
+// session is attached, and the commands inside it are tracked themselves.
+func isMultiplexerSession(cmdName string, fields []string) bool {
+	switch cmdName {
+	case "tmux":
+		command := firstOperand(fields[1:], []string{"-c", "-f", "-L", "-S", "-T"})
+		return command == "" || strings.HasPrefix("attach-session", command) ||
+			(len(command) >= 3 && (strings.HasPrefix("new-session", command) || strings.HasPrefix("detach-client", command)))
+
+	case "screen":
+		// Everything but queries and commands sent to a running session
+		for _, arg := range fields[1:] {
+			if arg == "-ls" || arg == "-list" || arg == "-Q" || arg == "-X" || arg == "-v" || arg == "-version" {
+				return false
+			}
+		}
+		return true
+
+	case "zellij":
+		command := firstOperand(fields[1:], []string{"-s", "--session", "-l", "--layout", "-c", "--config", "--config-dir", "--data-dir", "--max-panes"})
+		return command == "" || command == "attach" || command == "a" || command == "options"
+	}
+	return false
+}
+
+// firstOperand returns the first argument that isn't an option or the value
+// of one of valueOptions
+func firstOperand(args []string, valueOptions []string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return ""
+		}
+		if !strings.HasPrefix(arg, "-") {
+			return arg
+		}
+		if containsString(valueOptions, arg) {
+			i++
+		}
+	}
+	return ""
+}
@@ -0,0 +1,145 @@
+package tracker
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+)
+
+// stubTmuxSession answers session lookups with name, for pane "%3" only
+func stubTmuxSession(t *testing.T, name string) {
+	t.Helper()
+
+	saved := tmuxSessionLookup
+	tmuxSessionLookup = func(pane string) string {
+		if pane != "%3" {
+			return ""
+		}
+		return name
+	}
+	t.Cleanup(func() { tmuxSessionLookup = saved })
+}
+
+func TestDetectMultiplexer(t *testing.T) {
+	stubTmuxSession(t, "api")
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected *Multiplexer
+	}{
+		{"none", map[string]string{}, nil},
+		{"tmux", map[string]string{"TMUX": "/tmp/tmux-1000/default,4242,0", "TMUX_PANE": "%3"}, &Multiplexer{Name: "tmux", Session: "api", Pane: "%3"}},
+		{"screen", map[string]string{"STY": "4242.pts-0.laptop", "WINDOW": "2"}, &Multiplexer{Name: "screen", Session: "pts-0.laptop", Pane: "2"}},
+		{"zellij", map[string]string{"ZELLIJ": "0", "ZELLIJ_SESSION_NAME": "web", "ZELLIJ_PANE_ID": "1"}, &Multiplexer{Name: "zellij", Session: "web", Pane: "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectMultiplexer(func(key string) string { return tt.env[key] })
+			if (got == nil) != (tt.expected == nil) || (got != nil && *got != *tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestIsMultiplexerSession(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected bool
+	}{
+		{"tmux", true},
+		{"tmux attach", true},
+		{"tmux a -t work", true},
+		{"tmux -L other attach-session", true},
+		{"tmux new -s api", true},
+		{"tmux new-session -A -s api", true},
+		{"tmux detach", true},
+		{"tmux ls", false},
+		{"tmux kill-session -t api", false},
+		{"tmux -f tmux.conf source-file tmux.conf", false},
+		{"screen", true},
+		{"screen -r work", true},
+		{"screen -S work", true},
+		{"screen -ls", false},
+		{"screen -S work -X quit", false},
+		{"zellij", true},
+		{"zellij -s api", true},
+		{"zellij attach api", true},
+		{"zellij ls", false},
+		{"zellij run -- make", false},
+		{"make tmux", false},
+	}
+
+	for _, tt := range tests {
+		fields := strings.Fields(tt.command)
+		if got := isMultiplexerSession(fields[0], fields); got != tt.expected {
+			t.Errorf("isMultiplexerSession(%q) = %t, expected %t", tt.command, got, tt.expected)
+		}
+	}
+
+	tracker := NewTracker(&config.Config{})
+	if activity := tracker.parseCommandToSingleActivity("tmux attach -t work", t.TempDir()); activity != nil {
+		t.Errorf("Expected tmux attach to be ignored, got %+v", activity)
+	}
+}
+
+func TestSessionProject(t *testing.T) {
+	stubTmuxSession(t, "api")
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,4242,0")
+	t.Setenv("TMUX_PANE", "%3")
+	t.Setenv("STY", "")
+	t.Setenv("ZELLIJ", "")
+
+	dir := t.TempDir()
+
+	if got := NewTracker(&config.Config{}).detectProject(dir); got == "api" {
+		t.Error("Expected the session name to be ignored unless enabled")
+	}
+
+	tracker := NewTracker(&config.Config{TmuxSessionProject: true})
+	if got := tracker.detectProject(dir); got != "api" {
+		t.Errorf("Expected project api from the session, got %q", got)
+	}
+
+	if got := NewTracker(&config.Config{TmuxSessionProject: true, Project: "fixed"}).detectProject(dir); got != "fixed" {
+		t.Errorf("Expected the configured project to win, got %q", got)
+	}
+
+	// Unnamed sessions are numbered, which says nothing about the project
+	stubTmuxSession(t, "0")
+	if got := NewTracker(&config.Config{TmuxSessionProject: true}).detectProject(dir); got == "0" {
+		t.Error("Expected a numbered session not to name the project")
+	}
+}
+
+func TestJournalRecordsMultiplexer(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TMUX", "")
+	t.Setenv("STY", "")
+	t.Setenv("ZELLIJ", "0")
+	t.Setenv("ZELLIJ_SESSION_NAME", "web")
+	t.Setenv("ZELLIJ_PANE_ID", "4")
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+	NewTracker(cfg).recordHeartbeat(&Activity{
+		Entity:     "make",
+		EntityType: ActivityApp,
+		Category:   "building",
+		Timestamp:  time.Now(),
+	})
+
+	records, err := ReadJournal(cfg, time.Time{}, time.Time{})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("Expected one journaled heartbeat, got %d (err %v)", len(records), err)
+	}
+	if mux := records[0].Multiplexer; mux == nil || *mux != (Multiplexer{Name: "zellij", Session: "web", Pane: "4"}) {
+		t.Errorf("Expected the zellij pane in the journal, got %+v", mux)
+	}
+}
@@ -0,0 +1,215 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/language"
+)
+
+const (
+	// ProjectLanguageCacheFile remembers the dominant language per project root
+	ProjectLanguageCacheFile = "project_languages.json"
+
+	// How long a census result is reused, and kept at all
+	projectLanguageTTL       = time.Hour
+	projectLanguageRetention = 30 * 24 * time.Hour
+
+	// Bounds on the source file sample
+	censusMaxFiles = 2000
+	censusMaxDepth = 4
+)
+
+// projectManifests weigh a manifest at the project root as this many
+// source files of its language. The order only matters for readability;
+// scores are summed.
+var projectManifests = []struct {
+	file     string
+	language string
+	weight   int
+}{
+	{"go.mod", "Go", 25},
+	{"Cargo.toml", "Rust", 25},
+	{"package.json", "JavaScript", 10},
+	{"tsconfig.json", "TypeScript", 30},
+	{"deno.json", "TypeScript", 25},
+	{"pyproject.toml", "Python", 25},
+	{"setup.py", "Python", 20},
+	{"requirements.txt", "Python", 15},
+	{"Pipfile", "Python", 15},
+	{"Gemfile", "Ruby", 20},
+	{"composer.json", "PHP", 20},
+	{"pom.xml", "Java", 20},
+	{"build.gradle", "Java", 15},
+	{"build.gradle.kts", "Kotlin", 20},
+	{"build.sbt", "Scala", 20},
+	{"mix.exs", "Elixir", 25},
+	{"rebar.config", "Erlang", 20},
+	{"Package.swift", "Swift", 25},
+	{"build.zig", "Zig", 25},
+	{"pubspec.yaml", "Dart", 25},
+	{"gleam.toml", "Gleam", 25},
+	{"stack.yaml", "Haskell", 20},
+	{"dune-project", "OCaml", 20},
+	{"deps.edn", "Clojure", 20},
+	{"project.clj", "Clojure", 20},
+	{"shard.yml", "Crystal", 20},
+	{"CMakeLists.txt", "C++", 15},
+	{"flake.nix", "Nix", 5},
+}
+
+// Directories that hold dependencies or build output, not the project's code
+var censusSkipDirs = map[string]bool{
+	"node_modules":     true,
+	"bower_components": true,
+	"vendor":           true,
+	"target":           true,
+	"dist":             true,
+	"build":            true,
+	"out":              true,
+	"__pycache__":      true,
+	"venv":             true,
+	"Pods":             true,
+	"DerivedData":      true,
+	"_build":           true,
+	"deps":             true,
+}
+
+// Languages of data and documentation files, which don't decide what a
+// project is written in
+var censusIgnoredLanguages = map[string]bool{
+	"CSV":              true,
+	"Diff":             true,
+	"INI":              true,
+	"JSON":             true,
+	"Markdown":         true,
+	"MDX":              true,
+	"reStructuredText": true,
+	"Text":             true,
+	"TOML":             true,
+	"XML":              true,
+	"YAML":             true,
+}
+
+// projectLanguageEntry is a cached census result
+type projectLanguageEntry struct {
+	Language  string    `json:"language"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// detectProjectLanguage returns the dominant language of the project that
+// workingDir belongs to, from its manifests and a sample of its files
+func (t *Tracker) detectProjectLanguage(workingDir string) string {
+	root := findProjectRoot(workingDir)
+	if root == "" {
+		root = workingDir
+	}
+
+	cache := t.loadProjectLanguages()
+	if entry, ok := cache[root]; ok && time.Since(entry.CheckedAt) < projectLanguageTTL {
+		return entry.Language
+	}
+
+	detected := projectLanguageCensus(root)
+
+	cache[root] = projectLanguageEntry{Language: detected, CheckedAt: time.Now()}
+	t.saveProjectLanguages(cache)
+
+	return detected
+}
+
+// projectLanguageCensus scores languages by manifests at root plus one
+// point per source file, breadth first so large trees are sampled evenly
+// near the top. Ties go to the alphabetically first language, so the
+// result never depends on map or directory order.
+func projectLanguageCensus(root string) string {
+	scores := map[string]int{}
+
+	for _, manifest := range projectManifests {
+		if _, err := os.Stat(filepath.Join(root, manifest.file)); err == nil {
+			scores[manifest.language] += manifest.weight
+		}
+	}
+
+	type level struct {
+		dir   string
+		depth int
+	}
+
+	queue := []level{{root, 0}}
+	files := 0
+
+	for len(queue) > 0 && files < censusMaxFiles {
+		current := queue[0]
+		queue = queue[1:]
+
+		entries, err := os.ReadDir(current.dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+
+			if entry.IsDir() {
+				if current.depth < censusMaxDepth && !censusSkipDirs[name] {
+					queue = append(queue, level{filepath.Join(current.dir, name), current.depth + 1})
+				}
+				continue
+			}
+
+			if !entry.Type().IsRegular() {
+				continue
+			}
+
+			files++
+			if lang := language.DetectFilename(name); lang != "" && !censusIgnoredLanguages[lang] {
+				scores[lang]++
+			}
+
+			if files >= censusMaxFiles {
+				break
+			}
+		}
+	}
+
+	languages := make([]string, 0, len(scores))
+	for lang := range scores {
+		languages = append(languages, lang)
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if scores[languages[i]] != scores[languages[j]] {
+			return scores[languages[i]] > scores[languages[j]]
+		}
+		return languages[i] < languages[j]
+	})
+
+	if len(languages) == 0 {
+		return ""
+	}
+	return languages[0]
+}
+
+func (t *Tracker) loadProjectLanguages() map[string]projectLanguageEntry {
+	cache := make(map[string]projectLanguageEntry)
+	if err := t.config.ReadState(ProjectLanguageCacheFile, &cache); err != nil {
+		return make(map[string]projectLanguageEntry)
+	}
+	return cache
+}
+
+func (t *Tracker) saveProjectLanguages(cache map[string]projectLanguageEntry) {
+	for root, entry := range cache {
+		if time.Since(entry.CheckedAt) > projectLanguageRetention {
+			delete(cache, root)
+		}
+	}
+
+	t.writeState(ProjectLanguageCacheFile, cache)
+}
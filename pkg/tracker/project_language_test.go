@@ -0,0 +1,128 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+)
+
+// writeTree creates files (with parent directories) under root
+func writeTree(t *testing.T, root string, files ...string) {
+	t.Helper()
+
+	for _, file := range files {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", file, err)
+		}
+	}
+}
+
+func TestProjectLanguageCensus(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		expected string
+	}{
+		{
+			name:     "go module with a web frontend",
+			files:    []string{"go.mod", "package.json", "main.go", "server.go", "web/app.js"},
+			expected: "Go",
+		},
+		{
+			name:     "typescript over javascript",
+			files:    []string{"package.json", "tsconfig.json", "src/index.ts", "jest.config.js"},
+			expected: "TypeScript",
+		},
+		{
+			name: "dependencies are not sampled",
+			files: []string{
+				"requirements.txt", "app.py",
+				"node_modules/a/index.js", "node_modules/b/index.js", "node_modules/c/index.js",
+				".venv/lib/site.js",
+			},
+			expected: "Python",
+		},
+		{
+			name:     "source files without a manifest",
+			files:    []string{"README.md", "notes.txt", "main.zig", "build.zig", "src/lib.zig"},
+			expected: "Zig",
+		},
+		{
+			name:     "ties are broken by name",
+			files:    []string{"a.rb", "b.py"},
+			expected: "Python",
+		},
+		{
+			name:     "only data files",
+			files:    []string{"data.json", "config.yaml"},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, tt.files...)
+
+			// Deterministic across runs
+			for i := 0; i < 10; i++ {
+				if result := projectLanguageCensus(root); result != tt.expected {
+					t.Fatalf("Run %d: expected %q, got %q", i, tt.expected, result)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectProjectLanguageUsesProjectRoot(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, ".git/HEAD", "go.mod", "main.go", "cmd/app/main.go", "docs/site/index.html")
+
+	tracker := NewTracker(&config.Config{})
+
+	if result := tracker.detectProjectLanguage(filepath.Join(root, "docs", "site")); result != "Go" {
+		t.Errorf("Expected the project root's language Go, got %q", result)
+	}
+}
+
+func TestDetectProjectLanguageCache(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+	tracker := NewTracker(cfg)
+
+	root := filepath.Join(tempDir, "project")
+	writeTree(t, root, "Cargo.toml", "src/main.rs")
+
+	if result := tracker.detectProjectLanguage(root); result != "Rust" {
+		t.Fatalf("Expected Rust, got %q", result)
+	}
+
+	// A fresh entry is reused without walking the tree again
+	writeTree(t, root, "go.mod", "a.go", "b.go", "c.go")
+	if result := tracker.detectProjectLanguage(root); result != "Rust" {
+		t.Errorf("Expected the cached Rust, got %q", result)
+	}
+
+	// A stale one is recomputed
+	cache := tracker.loadProjectLanguages()
+	cache[root] = projectLanguageEntry{Language: "Rust", CheckedAt: time.Now().Add(-2 * projectLanguageTTL)}
+	tracker.saveProjectLanguages(cache)
+
+	if result := tracker.detectProjectLanguage(root); result != "Go" {
+		t.Errorf("Expected the stale entry to be recomputed as Go, got %q", result)
+	}
+}
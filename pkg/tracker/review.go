@@ -0,0 +1,329 @@
+package tracker
+
+import (
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// reviewCLI describes a forge's command line client, whose pull or merge
+// request commands are tracked per request
+type reviewCLI struct {
+	// command is the request subcommand group: "pr" or "mr"
+	command string
+
+	// separator joins a repository and a request number, as the forge
+	// writes references: owner/repo#123 or group/project!45
+	separator string
+
+	// resolvedKey marks the remote the client was told to use in git
+	// config, as remote.<name>.<resolvedKey> = base
+	resolvedKey string
+
+	// repoEnv names an environment variable that sets the repository
+	repoEnv string
+
+	// hostPrefix is set when repositories may be given as HOST/OWNER/REPO.
+	// Otherwise the whole path is the repository, as with GitLab's nested
+	// groups.
+	hostPrefix bool
+
+	// valueFlags take a value, so the word after them isn't a reference
+	valueFlags []string
+
+	// refless are subcommands that never take a reference
+	refless []string
+
+	// writes change the request
+	writes []string
+}
+
+var reviewCLIs = map[string]*reviewCLI{
+	"gh": {
+		command:     "pr",
+		separator:   "#",
+		resolvedKey: "gh-resolved",
+		repoEnv:     "GH_REPO",
+		hostPrefix:  true,
+		valueFlags: []string{
+			"-R", "--repo", "-b", "--body", "-F", "--body-file", "-q", "--jq", "-t", "--template", "--message",
+			"--json", "--color", "-B", "--base", "-T", "--title", "--subject", "--author-email",
+			"--match-head-commit", "-m", "--milestone", "-i", "--interval", "--add-label", "--remove-label",
+			"--add-reviewer", "--remove-reviewer", "--add-assignee", "--remove-assignee",
+			"--add-project", "--remove-project",
+		},
+		refless: []string{"create", "new", "list", "ls", "status"},
+		writes:  []string{"review", "comment", "merge", "create", "new", "edit", "close", "reopen", "ready"},
+	},
+	"glab": {
+		command:     "mr",
+		separator:   "!",
+		resolvedKey: "glab-resolved",
+		valueFlags: []string{
+			"-R", "--repo", "-m", "--message", "-b", "--branch", "-p", "--page", "-P", "--per-page",
+			"-F", "--output", "--color", "--sha", "--squash-message", "-t", "--title",
+			"-d", "--description", "--body", "--body-file",
+		},
+		refless: []string{"create", "new", "list", "ls"},
+		writes:  []string{"approve", "revoke", "note", "merge", "accept", "create", "new", "update", "close", "reopen", "rebase"},
+	},
+}
+
+// requestURL matches pull and merge request URLs:
+// https://github.com/owner/repo/pull/123 and
+// https://gitlab.com/group/sub/project/-/merge_requests/45
+var requestURL = regexp.MustCompile(`^https?://[^/]+/(.+?)(?:/-)?/(?:pull|pulls|merge_requests)/(\d+)`)
+
+// handleReviewCommandSingle attributes `gh pr ...` and `glab mr ...` to
+// the request they act on. It returns nil for other commands of the CLI.
+func (t *Tracker) handleReviewCommandSingle(cmdName string, fields []string, workingDir string) *Activity {
+	cli, ok := reviewCLIs[cmdName]
+	if !ok || len(fields) < 2 {
+		return nil
+	}
+
+	// Global -R may come before the request command
+	args := fields[1:]
+	repo := ""
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		if value, consumed := flagValue(args, "-R", "--repo"); consumed > 0 {
+			repo = value
+			args = args[consumed:]
+			continue
+		}
+		args = args[1:]
+	}
+	if len(args) < 2 || args[0] != cli.command {
+		return nil
+	}
+
+	subcommand := args[1]
+	ref := ""
+
+	for i := 2; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			if i+1 < len(args) && ref == "" {
+				ref = args[i+1]
+			}
+			break
+		}
+
+		if value, consumed := flagValue(args[i:], "-R", "--repo"); consumed > 0 {
+			repo = value
+			i += consumed - 1
+			continue
+		}
+
+		if strings.HasPrefix(arg, "-") {
+			if !strings.Contains(arg, "=") && containsString(cli.valueFlags, arg) {
+				i++
+			}
+			continue
+		}
+
+		if ref == "" && !containsString(cli.refless, subcommand) {
+			ref = arg
+		}
+	}
+
+	if repo == "" && cli.repoEnv != "" {
+		repo = os.Getenv(cli.repoEnv)
+	}
+
+	number := ""
+	if match := requestURL.FindStringSubmatch(ref); match != nil {
+		repo, number = match[1], match[2]
+	} else if trimmed := strings.TrimLeft(ref, "#!"); trimmed != "" && isDigits(trimmed) {
+		number = trimmed
+	}
+
+	sameRepo := repo == ""
+	if sameRepo {
+		repo = remoteRepository(workingDir, cli.resolvedKey)
+	}
+	repo = normalizeRepository(repo, cli.hostPrefix)
+
+	// The branch: the one named, the current one when the request is
+	// implied by it (or was just checked out), or a local branch fetched
+	// for the request. A word that can't be a branch is left out rather
+	// than sent.
+	branch := ""
+	switch {
+	case ref != "" && number == "":
+		if validBranchName(ref) {
+			branch = ref
+		}
+	case sameRepo && (ref == "" || subcommand == "checkout" || subcommand == "co"):
+		branch = t.gitBranch(workingDir)
+	case sameRepo:
+		branch = requestBranch(workingDir, number)
+	}
+
+	entity := cmdName + " " + cli.command + " " + subcommand
+	if repo != "" {
+		switch {
+		case number != "":
+			entity = repo + cli.separator + number
+		case branch != "" && !containsString(cli.refless, subcommand):
+			entity = repo + ":" + branch
+		}
+	}
+
+	project := t.detectProject(workingDir)
+	if !sameRepo && t.config.Project == "" && repo != "" {
+		project = path.Base(repo)
+	}
+
+	return &Activity{
+		Entity:     entity,
+		EntityType: ActivityApp,
+		Category:   categoryReviewing,
+		Project:    project,
+		Branch:     branch,
+		IsWrite:    containsString(cli.writes, subcommand),
+		Timestamp:  time.Now(),
+	}
+}
+
+// flagValue returns the value of a flag at args[0], if it is one of names,
+// and how many words it spans
+func flagValue(args []string, names ...string) (string, int) {
+	name, value, hasValue := strings.Cut(args[0], "=")
+	if !containsString(names, name) {
+		return "", 0
+	}
+	if hasValue {
+		return value, 1
+	}
+	if len(args) < 2 {
+		return "", 1
+	}
+	return args[1], 2
+}
+
+// remoteRepository returns the owner/repo path of the remote a forge CLI
+// would use in dir: the one it resolved before, else upstream, github,
+// gitlab or origin, else the first
+func remoteRepository(dir, resolvedKey string) string {
+	cmd := exec.Command("git", "config", "--get-regexp", `^remote\..*\.(url|`+regexp.QuoteMeta(resolvedKey)+`)$`)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	urls := map[string]string{}
+	var names []string
+	resolved := ""
+
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		key = strings.TrimPrefix(key, "remote.")
+
+		switch {
+		case strings.HasSuffix(key, ".url"):
+			name := strings.TrimSuffix(key, ".url")
+			if _, seen := urls[name]; !seen {
+				names = append(names, name)
+			}
+			urls[name] = value
+		case strings.HasSuffix(key, "."+resolvedKey) && value == "base":
+			resolved = strings.TrimSuffix(key, "."+resolvedKey)
+		}
+	}
+
+	for _, name := range append([]string{resolved, "upstream", "github", "gitlab", "origin"}, names...) {
+		if remote, ok := urls[name]; ok {
+			return repositoryFromURL(remote)
+		}
+	}
+	return ""
+}
+
+// repositoryFromURL returns the path of a remote URL without ".git":
+// https://github.com/owner/repo.git, git@github.com:owner/repo.git and
+// ssh://git@gitlab.com/group/sub/project all work
+func repositoryFromURL(remote string) string {
+	var repoPath string
+
+	if parsed, err := url.Parse(remote); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+		repoPath = parsed.Path
+	} else if _, after, ok := strings.Cut(remote, ":"); ok && !strings.Contains(remote, "://") {
+		repoPath = after
+	} else {
+		return ""
+	}
+
+	return normalizeRepository(repoPath, false)
+}
+
+// normalizeRepository trims slashes and ".git". With hostPrefix, it drops
+// the host from HOST/OWNER/REPO forms gh accepts.
+func normalizeRepository(repo string, hostPrefix bool) string {
+	if strings.Contains(repo, "://") {
+		repo = repositoryFromURL(repo)
+	}
+
+	repo = strings.Trim(strings.TrimSuffix(strings.Trim(repo, "/"), ".git"), "/")
+
+	if parts := strings.Split(repo, "/"); hostPrefix && len(parts) == 3 && strings.Contains(parts[0], ".") {
+		repo = parts[1] + "/" + parts[2]
+	}
+	return repo
+}
+
+// requestBranch returns a local branch that tracks request number, as
+// gh and glab set up when checking out requests from forks
+func requestBranch(dir, number string) string {
+	cmd := exec.Command("git", "config", "--get-regexp", `^branch\..*\.merge$`)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		if value == "refs/pull/"+number+"/head" || value == "refs/merge-requests/"+number+"/head" {
+			return strings.TrimSuffix(strings.TrimPrefix(key, "branch."), ".merge")
+		}
+	}
+	return ""
+}
+
+// validBranchName reports whether name is a valid branch name by the rules
+// of git check-ref-format --branch
+func validBranchName(name string) bool {
+	if name == "" || name == "@" || strings.HasPrefix(name, "-") ||
+		strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") ||
+		strings.Contains(name, "..") || strings.Contains(name, "@{") || strings.Contains(name, "//") {
+		return false
+	}
+
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return false
+		}
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
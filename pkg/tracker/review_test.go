@@ -0,0 +1,152 @@
+package tracker
+
+import (
+	"testing"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+)
+
+func TestRepositoryFromURL(t *testing.T) {
+	tests := []struct {
+		remote   string
+		expected string
+	}{
+		{"https://github.com/hackclub/terminal-wakatime.git", "hackclub/terminal-wakatime"},
+		{"https://github.com/hackclub/terminal-wakatime", "hackclub/terminal-wakatime"},
+		{"git@github.com:hackclub/terminal-wakatime.git", "hackclub/terminal-wakatime"},
+		{"ssh://git@gitlab.com/group/sub/project.git", "group/sub/project"},
+		{"/srv/git/project.git", ""},
+	}
+
+	for _, tt := range tests {
+		if got := repositoryFromURL(tt.remote); got != tt.expected {
+			t.Errorf("repositoryFromURL(%q) = %q, expected %q", tt.remote, got, tt.expected)
+		}
+	}
+}
+
+func TestNormalizeRepository(t *testing.T) {
+	tests := []struct {
+		repo       string
+		hostPrefix bool
+		expected   string
+	}{
+		{"github.com/acme/widgets", true, "acme/widgets"},
+		{"acme/widgets.git", true, "acme/widgets"},
+		{"group/sub.group/project", false, "group/sub.group/project"},
+		{"my.group/sub/project", false, "my.group/sub/project"},
+		{"https://gitlab.com/group/sub/project.git", false, "group/sub/project"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeRepository(tt.repo, tt.hostPrefix); got != tt.expected {
+			t.Errorf("normalizeRepository(%q, %t) = %q, expected %q", tt.repo, tt.hostPrefix, got, tt.expected)
+		}
+	}
+}
+
+func TestValidBranchName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"fix-login", true},
+		{"feature/sso", true},
+		{"release-1.2", true},
+		{"LGTM thanks", false},
+		{"-x", false},
+		{"a..b", false},
+		{"feature/.hidden", false},
+		{"topic.lock", false},
+		{"what?", false},
+		{"refs@{1}", false},
+		{"trailing/", false},
+	}
+
+	for _, tt := range tests {
+		if got := validBranchName(tt.name); got != tt.valid {
+			t.Errorf("validBranchName(%q) = %t, expected %t", tt.name, got, tt.valid)
+		}
+	}
+}
+
+func TestHandleReviewCommandSingle(t *testing.T) {
+	dir, git := gitRepo(t)
+	git("remote", "set-url", "origin", "git@github.com:me/fork.git")
+	git("remote", "add", "upstream", "https://github.com/acme/widgets.git")
+	git("commit", "-q", "--allow-empty", "-m", "initial")
+	git("checkout", "-q", "-b", "fix-login")
+	git("config", "branch.contributor-patch.merge", "refs/pull/77/head")
+	t.Setenv("GH_REPO", "")
+
+	tracker := NewTracker(&config.Config{})
+
+	tests := []struct {
+		command string
+		entity  string
+		project string
+		branch  string
+		isWrite bool
+	}{
+		{"gh pr checkout 123", "acme/widgets#123", "work", "fix-login", false},
+		{"gh pr diff", "acme/widgets:fix-login", "work", "fix-login", false},
+		{"gh pr review 77 --approve -b lgtm", "acme/widgets#77", "work", "contributor-patch", true},
+		{"gh pr view --json title -R other/repo 9", "other/repo#9", "repo", "", false},
+		{"gh -R github.com/other/repo pr view 9", "other/repo#9", "repo", "", false},
+		{"gh pr view https://github.com/octo/site/pull/5", "octo/site#5", "site", "", false},
+		{"gh pr view some-branch", "acme/widgets:some-branch", "work", "some-branch", false},
+		{"gh pr list --state open", "gh pr list", "work", "fix-login", false},
+		{"glab mr view 45 -R group/sub/project", "group/sub/project!45", "project", "", false},
+		{"glab mr note !45 -m done", "acme/widgets!45", "work", "", true},
+		{"glab mr view https://gitlab.com/group/app/-/merge_requests/3", "group/app!3", "app", "", false},
+		{"glab mr view 3 -R group/sub.group/project", "group/sub.group/project!3", "project", "", false},
+
+		// Quoted text is never a reference
+		{`gh pr review --approve --body "LGTM thanks"`, "acme/widgets:fix-login", "work", "fix-login", true},
+		{`gh pr comment --body "secret token here" 12`, "acme/widgets#12", "work", "", true},
+		{`gh pr comment -b 'see main' 12`, "acme/widgets#12", "work", "", true},
+		{`gh pr create --title "Fix login" --body-file notes.md`, "gh pr create", "work", "fix-login", true},
+		{`glab mr note 45 --message "looks good"`, "acme/widgets!45", "work", "", true},
+		{`glab mr create -t "Fix login" -d "it works now"`, "glab mr create", "work", "fix-login", true},
+		{`gh pr view "not a branch"`, "gh pr view", "work", "", false},
+		{`gh pr view -- 'a..b'`, "gh pr view", "work", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			fields := shellFields(tt.command)
+			activity := tracker.handleReviewCommandSingle(fields[0], fields, dir)
+			if activity == nil {
+				t.Fatal("Expected an activity")
+			}
+
+			if activity.Entity != tt.entity || activity.Project != tt.project || activity.Branch != tt.branch || activity.IsWrite != tt.isWrite {
+				t.Errorf("Got %q project %q branch %q write=%t, expected %q project %q branch %q write=%t",
+					activity.Entity, activity.Project, activity.Branch, activity.IsWrite,
+					tt.entity, tt.project, tt.branch, tt.isWrite)
+			}
+			if activity.Category != categoryReviewing {
+				t.Errorf("Expected category %q, got %q", categoryReviewing, activity.Category)
+			}
+		})
+	}
+
+	// Other gh commands are left to the generic app handling
+	if activity := tracker.handleReviewCommandSingle("gh", []string{"gh", "repo", "clone", "acme/widgets"}, dir); activity != nil {
+		t.Errorf("Expected no request activity for gh repo clone, got %+v", activity)
+	}
+}
+
+func TestHandleReviewCommandPrefersResolvedRemote(t *testing.T) {
+	dir, git := gitRepo(t)
+	git("remote", "add", "upstream", "https://github.com/acme/widgets.git")
+	git("remote", "add", "mine", "https://github.com/me/widgets.git")
+	git("config", "remote.mine.gh-resolved", "base")
+	t.Setenv("GH_REPO", "")
+
+	fields := []string{"gh", "pr", "view", "8"}
+	activity := NewTracker(&config.Config{}).handleReviewCommandSingle("gh", fields, dir)
+	if activity == nil || activity.Entity != "me/widgets#8" {
+		t.Errorf("Expected the gh-resolved remote, got %+v", activity)
+	}
+}
@@ -0,0 +1,370 @@
+package tracker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+)
+
+const (
+	// LastHeartbeatFile records the most recent heartbeat sent to wakatime-cli
+	LastHeartbeatFile = "last_heartbeat.json"
+
+	// JournalFile is the single journal earlier versions appended every
+	// heartbeat to. It is still read, but no longer written.
+	JournalFile = "activity.jsonl"
+
+	// JournalMonthFilePattern names the append-only log of a month's
+	// heartbeats, one JSON HeartbeatRecord per line
+	JournalMonthFilePattern = "activity-%s.jsonl"
+
+	// JournalRetentionMonths is how many months of journal are kept,
+	// including the current one
+	JournalRetentionMonths = 13
+
+	journalMonthFormat = "2006-01"
+
+	// DailySummaryFile holds today's locally accounted time for the prompt
+	DailySummaryFile = "daily_summary.json"
+
+	// SummaryTimeout caps the time credited between two heartbeats, matching
+	// WakaTime's default keystroke timeout
+	SummaryTimeout = 15 * time.Minute
+
+	summaryDateFormat = "2006-01-02"
+
+	// How long a process waits for another one's state update, and the age
+	// at which a lock is left over from a crashed process
+	stateLockWait  = 2 * time.Second
+	staleStateLock = 10 * time.Second
+
+	stateLockPollInterval = 10 * time.Millisecond
+)
+
+// HeartbeatRecord is the persisted summary of a sent heartbeat
+type HeartbeatRecord struct {
+	Time     time.Time `json:"time"`
+	Entity   string    `json:"entity"`
+	Type     string    `json:"type"`
+	Category string    `json:"category"`
+	Language string    `json:"language,omitempty"`
+	Project  string    `json:"project,omitempty"`
+	Branch   string    `json:"branch,omitempty"`
+	Command  string    `json:"command,omitempty"`
+
+	// Multiplexer is the tmux, screen or zellij pane the heartbeat came from
+	Multiplexer *Multiplexer `json:"multiplexer,omitempty"`
+}
+
+// DailySummary is a local, approximate tally of today's tracked time. It is
+// only meant for quick displays; the WakaTime API remains authoritative.
+type DailySummary struct {
+	Date          string             `json:"date"`
+	TotalSeconds  float64            `json:"total_seconds"`
+	Projects      map[string]float64 `json:"projects"`
+	LastHeartbeat time.Time          `json:"last_heartbeat"`
+	LastProject   string             `json:"last_project"`
+}
+
+// Total returns the tracked time for the day
+func (s *DailySummary) Total() time.Duration {
+	return time.Duration(s.TotalSeconds * float64(time.Second))
+}
+
+// ProjectTotal returns the tracked time for project
+func (s *DailySummary) ProjectTotal(project string) time.Duration {
+	return time.Duration(s.Projects[project] * float64(time.Second))
+}
+
+// add credits the time since the previous heartbeat, up to SummaryTimeout,
+// to the project of the previous heartbeat
+func (s *DailySummary) add(activity *Activity) {
+	if !s.LastHeartbeat.IsZero() {
+		elapsed := activity.Timestamp.Sub(s.LastHeartbeat)
+		if elapsed > 0 && elapsed <= SummaryTimeout {
+			s.TotalSeconds += elapsed.Seconds()
+			if s.LastProject != "" {
+				s.Projects[s.LastProject] += elapsed.Seconds()
+			}
+		}
+	}
+
+	if activity.Timestamp.After(s.LastHeartbeat) {
+		s.LastHeartbeat = activity.Timestamp
+		s.LastProject = activity.Project
+	}
+}
+
+// LoadDailySummary returns the summary for the current local day. A stored
+// summary from an earlier day is treated as empty.
+func LoadDailySummary(cfg *config.Config) (*DailySummary, error) {
+	return loadDailySummary(cfg, time.Now())
+}
+
+func loadDailySummary(cfg *config.Config, now time.Time) (*DailySummary, error) {
+	summary := &DailySummary{
+		Date:     now.Format(summaryDateFormat),
+		Projects: make(map[string]float64),
+	}
+
+	data, err := os.ReadFile(filepath.Join(cfg.WakaTimeDir(), DailySummaryFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return summary, nil
+		}
+		return nil, err
+	}
+
+	var stored DailySummary
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", DailySummaryFile, err)
+	}
+
+	if stored.Date != summary.Date {
+		return summary, nil
+	}
+	if stored.Projects == nil {
+		stored.Projects = make(map[string]float64)
+	}
+
+	return &stored, nil
+}
+
+// LoadLastHeartbeat returns the last heartbeat recorded for cfg, or nil if
+// none has been sent yet
+func LoadLastHeartbeat(cfg *config.Config) (*HeartbeatRecord, error) {
+	data, err := os.ReadFile(filepath.Join(cfg.WakaTimeDir(), LastHeartbeatFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var record HeartbeatRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", LastHeartbeatFile, err)
+	}
+
+	return &record, nil
+}
+
+// recordHeartbeat persists activity as the last sent heartbeat, appends it to
+// the journal and adds it to the daily summary. Errors are ignored since this is informational only.
+func (t *Tracker) recordHeartbeat(activity *Activity) {
+	record := HeartbeatRecord{
+		Time:     activity.Timestamp,
+		Entity:   activity.Entity,
+		Type:     string(activity.EntityType),
+		Category: activity.Category,
+		Language: activity.Language,
+		Project:  activity.Project,
+		Branch:   activity.Branch,
+		Command:  activity.Command,
+
+		Multiplexer: t.multiplexer(),
+	}
+	t.writeState(LastHeartbeatFile, record)
+	t.appendJournal(record)
+
+	// Shells, the editor watcher and the forward server all record
+	// heartbeats, so the read-modify-write has to be serialised
+	t.withStateLock(DailySummaryFile, func() {
+		summary, err := loadDailySummary(t.config, activity.Timestamp)
+		if err != nil {
+			return
+		}
+		summary.add(activity)
+		t.writeState(DailySummaryFile, summary)
+	})
+}
+
+// withStateLock runs fn while holding the inter-process lock for the state
+// file name. Like the wakatime-cli install lock, it is a file created with
+// O_EXCL. If the lock can't be taken in time, fn runs anyway: a lost update
+// to informational state beats dropping it.
+func (t *Tracker) withStateLock(name string, fn func()) {
+	dir := t.config.WakaTimeDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fn()
+		return
+	}
+
+	path := filepath.Join(dir, name+".lock")
+	deadline := time.Now().Add(stateLockWait)
+
+	for {
+		lock, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			lock.Close()
+			defer os.Remove(path)
+			fn()
+			return
+		}
+		if !os.IsExist(err) {
+			break
+		}
+
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > staleStateLock {
+			os.Remove(path)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(stateLockPollInterval)
+	}
+
+	fn()
+}
+
+// appendJournal adds record to the journal of its month. Each record is a
+// single write of one line, so appends from concurrent shells don't
+// interleave. Starting a month's journal prunes the ones past retention.
+func (t *Tracker) appendJournal(record HeartbeatRecord) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+
+	dir := t.config.WakaTimeDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	path := filepath.Join(dir, journalMonthFile(record.Time))
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		pruneJournal(t.config, record.Time)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	file.Write(append(data, '\n'))
+}
+
+// journalMonthFile returns the name of the journal holding heartbeats from
+// the month of t, in UTC
+func journalMonthFile(t time.Time) string {
+	return fmt.Sprintf(JournalMonthFilePattern, t.UTC().Format(journalMonthFormat))
+}
+
+// journalMonth parses the month of a journal file name
+func journalMonth(name string) (time.Time, bool) {
+	t, err := time.Parse(fmt.Sprintf(JournalMonthFilePattern, journalMonthFormat), name)
+	return t, err == nil
+}
+
+// pruneJournal removes the month journals more than JournalRetentionMonths
+// months before now
+func pruneJournal(cfg *config.Config, now time.Time) {
+	year, month, _ := now.UTC().Date()
+	oldest := time.Date(year, month-JournalRetentionMonths+1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, path := range JournalFiles(cfg) {
+		if start, ok := journalMonth(filepath.Base(path)); ok && start.Before(oldest) {
+			os.Remove(path)
+		}
+	}
+}
+
+// JournalFiles returns the journal files in the WakaTime directory, the
+// legacy single journal first and then the months in order
+func JournalFiles(cfg *config.Config) []string {
+	var files []string
+	legacy := filepath.Join(cfg.WakaTimeDir(), JournalFile)
+	if _, err := os.Stat(legacy); err == nil {
+		files = append(files, legacy)
+	}
+
+	months, _ := filepath.Glob(filepath.Join(cfg.WakaTimeDir(), fmt.Sprintf(JournalMonthFilePattern, "*")))
+	sort.Strings(months)
+	for _, path := range months {
+		if _, ok := journalMonth(filepath.Base(path)); ok {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// ReadJournal returns the journaled heartbeats with since <= Time < until.
+// A zero since or until leaves that side unbounded. Only the months in the
+// period are read. Malformed lines are skipped.
+func ReadJournal(cfg *config.Config, since, until time.Time) ([]HeartbeatRecord, error) {
+	var records []HeartbeatRecord
+
+	for _, path := range JournalFiles(cfg) {
+		if start, ok := journalMonth(filepath.Base(path)); ok {
+			if !until.IsZero() && !start.Before(until) {
+				continue
+			}
+			if !since.IsZero() && !start.AddDate(0, 1, 0).After(since) {
+				continue
+			}
+		}
+
+		read, err := readJournalFile(path, since, until)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, read...)
+	}
+
+	return records, nil
+}
+
+func readJournalFile(path string, since, until time.Time) ([]HeartbeatRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []HeartbeatRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record HeartbeatRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if !since.IsZero() && record.Time.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !record.Time.Before(until) {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+
+	return records, nil
+}
+
+// writeState stores v in the state file name. Errors are ignored since the
+// state is informational only.
+func (t *Tracker) writeState(name string, v interface{}) {
+	t.config.WriteState(name, v)
+}
+
+// ProjectForDir returns the project and branch that heartbeats from dir are
+// attributed to
+func (t *Tracker) ProjectForDir(dir string) (string, string) {
+	return t.detectProject(dir), t.gitBranch(dir)
+}
@@ -4,14 +4,16 @@ import (
 	"bufio"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/hackclub/terminal-wakatime/pkg/config"
+	"github.com/hackclub/terminal-wakatime/pkg/cursor"
+	"github.com/hackclub/terminal-wakatime/pkg/language"
+	"github.com/hackclub/terminal-wakatime/pkg/ssh"
 	"github.com/hackclub/terminal-wakatime/pkg/wakatime"
 )
 
@@ -37,6 +39,13 @@ type Activity struct {
 	CursorPos     *int
 	LineAdditions *int
 	LineDeletions *int
+
+	// Command is the program name that triggered the activity, if any
+	Command string
+
+	// Hostname is the machine a forwarded heartbeat comes from, "" for
+	// this one
+	Hostname string
 }
 
 type Tracker struct {
@@ -45,26 +54,42 @@ type Tracker struct {
 	lastSentTime time.Time
 	lastSentFile string
 	suggestions  map[string]time.Time
+
+	// gitSnapshot is the state before the command being tracked, if the
+	// hooks captured it
+	gitSnapshot *GitSnapshot
+
+	// Per-directory lookups, which hold for the life of the process
+	projects     map[string]string
+	repositories map[string]*gitRepository
+
+	// The multiplexer session, looked up on first use
+	multiplexerInfo    *Multiplexer
+	multiplexerChecked bool
 }
 
 var (
-	// Editor patterns for detection
-	editorPatterns = map[string]*regexp.Regexp{
-		"vim":     regexp.MustCompile(`\b(vi|vim|nvim|gvim)\b`),
-		"emacs":   regexp.MustCompile(`\b(emacs|emacsclient)\b`),
-		"nano":    regexp.MustCompile(`\b(nano|pico)\b`),
-		"code":    regexp.MustCompile(`\b(code|code-insiders)\b`),
-		"cursor":  regexp.MustCompile(`\bcursor\b`),
-		"sublime": regexp.MustCompile(`\b(subl|sublime_text|sublime)\b`),
-		"atom":    regexp.MustCompile(`\batom\b`),
-		"idea":    regexp.MustCompile(`\b(idea|intellij|pycharm|webstorm|clion|goland|rider|phpstorm|rubymine|dataspell|datagrip|android-studio)\b`),
-		"helix":   regexp.MustCompile(`\b(hx|helix)\b`),
-		"zed":     regexp.MustCompile(`\bzed\b`),
-		"micro":   regexp.MustCompile(`\bmicro\b`),
-		"kate":    regexp.MustCompile(`\bkate\b`),
-		"gedit":   regexp.MustCompile(`\bgedit\b`),
-		"crush":   regexp.MustCompile(`\bcrush\b`),
-	}
+	// Editor commands for detection, by editor
+	editorCommands = map[string][]string{
+		"vim":     {"vi", "vim", "nvim", "gvim"},
+		"emacs":   {"emacs", "emacsclient"},
+		"nano":    {"nano", "pico"},
+		"code":    {"code", "code-insiders"},
+		"cursor":  {"cursor"},
+		"sublime": {"subl", "sublime_text", "sublime"},
+		"atom":    {"atom"},
+		"idea":    {"idea", "intellij", "pycharm", "webstorm", "clion", "goland", "rider", "phpstorm", "rubymine", "dataspell", "datagrip", "android-studio"},
+		"helix":   {"hx", "helix"},
+		"zed":     {"zed"},
+		"micro":   {"micro"},
+		"kate":    {"kate"},
+		"gedit":   {"gedit"},
+		"crush":   {"crush"},
+	}
+
+	// editorPattern matches an editor command as a word, so suffixes such
+	// as vim.exe are recognised too
+	editorPattern = regexp.MustCompile(`\b(` + strings.Join(EditorCommands(), "|") + `)\b`)
 
 	// App patterns for coding tools
 	codingApps = map[string]string{
@@ -143,7 +168,6 @@ var (
 
 	// Remote connection patterns
 	remotePatterns = []*regexp.Regexp{
-		regexp.MustCompile(`ssh\s+(?:.*@)?([^@\s]+)`),
 		regexp.MustCompile(`mysql\s+.*-h\s+([^\s]+)`),
 		regexp.MustCompile(`psql\s+.*-h\s+([^\s]+)`),
 		regexp.MustCompile(`redis-cli\s+.*-h\s+([^\s]+)`),
@@ -156,38 +180,146 @@ var (
 
 func NewTracker(cfg *config.Config) *Tracker {
 	return &Tracker{
-		config:      cfg,
-		wakatime:    wakatime.NewCLI(cfg),
-		suggestions: make(map[string]time.Time),
+		config:       cfg,
+		wakatime:     wakatime.NewCLI(cfg),
+		suggestions:  make(map[string]time.Time),
+		projects:     make(map[string]string),
+		repositories: make(map[string]*gitRepository),
 	}
 }
 
 func (t *Tracker) TrackCommand(command string, workingDir string) error {
+	command = withoutPrivilegeWrapper(command)
 	activity := t.parseCommandToSingleActivity(command, workingDir)
 	if activity != nil {
+		activity.Command = filepath.Base(strings.Fields(command)[0])
 		return t.sendActivity(activity)
 	}
 	return nil
 }
 
-func (t *Tracker) TrackFile(filePath string, isWrite bool) error {
+// TrackFile sends a heartbeat for filePath. editor is the command editing
+// it, used to read that editor's cursor position; with "", the most recent
+// position any editor recorded is used.
+func (t *Tracker) TrackFile(editor, filePath string, isWrite bool) error {
+	lineNo, cursorPos := cursorPosition(editor, filePath)
+
 	activity := &Activity{
 		Entity:     filePath,
 		EntityType: ActivityFile,
 		Category:   "coding",
-		Language:   detectLanguage(filePath),
+		Language:   language.Detect(filePath),
 		Project:    t.detectProject(filePath),
-		Branch:     getGitBranch(filepath.Dir(filePath)),
+		Branch:     t.gitBranch(filepath.Dir(filePath)),
 		IsWrite:    isWrite,
 		Timestamp:  time.Now(),
 		Lines:      getFileLines(filePath),
-		LineNo:     getDefaultLineNumber(),
-		CursorPos:  getDefaultCursorPos(),
+		LineNo:     lineNo,
+		CursorPos:  cursorPos,
 	}
 
 	return t.sendActivity(activity)
 }
 
+// privilegeWrappers run another command as a different user, and the options
+// of theirs that take a value
+var privilegeWrappers = map[string][]string{
+	"sudo": {"-u", "-g", "-C", "-D", "-h", "-p", "-R", "-r", "-T", "-t", "-U",
+		"--user", "--group", "--close-from", "--chdir", "--host", "--prompt", "--chroot", "--role", "--type", "--other-user", "--command-timeout"},
+	"doas": {"-u", "-C"},
+}
+
+// withoutPrivilegeWrapper returns the command sudo or doas runs, so the time
+// goes to that program. Wrappers that only open a shell are left as they are.
+func withoutPrivilegeWrapper(command string) string {
+	rest := strings.TrimLeft(command, " \t")
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return command
+	}
+
+	valueOptions, ok := privilegeWrappers[filepath.Base(fields[0])]
+	if !ok {
+		return command
+	}
+
+	skip := func(word string) {
+		rest = strings.TrimLeft(rest[len(word):], " \t")
+	}
+	skip(fields[0])
+
+	for i := 1; i < len(fields); i++ {
+		field := fields[i]
+		switch {
+		case field == "--":
+			skip(field)
+			if i+1 < len(fields) {
+				return withoutPrivilegeWrapper(rest)
+			}
+			return command
+		case containsString(valueOptions, field):
+			skip(field)
+			if i+1 < len(fields) {
+				i++
+				skip(fields[i])
+			}
+		case strings.HasPrefix(field, "-"), strings.Contains(field, "="):
+			// Flags, --option=value and VAR=value assignments
+			skip(field)
+		default:
+			return withoutPrivilegeWrapper(rest)
+		}
+	}
+
+	return command
+}
+
+// shellFields splits command into words the way a shell would, without
+// expanding anything: quotes keep words together and backslashes escape
+func shellFields(command string) []string {
+	var fields []string
+	var word strings.Builder
+	inWord, escaped := false, false
+	quote := rune(0)
+
+	for _, r := range command {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				fields = append(fields, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		fields = append(fields, word.String())
+	}
+	return fields
+}
+
 func (t *Tracker) parseCommandToSingleActivity(command string, workingDir string) *Activity {
 	fields := strings.Fields(command)
 	if len(fields) == 0 {
@@ -196,14 +328,20 @@ func (t *Tracker) parseCommandToSingleActivity(command string, workingDir string
 
 	cmdName := filepath.Base(fields[0])
 
+	// Attaching to a multiplexer would swallow the time of everything
+	// tracked inside it
+	if isMultiplexerSession(cmdName, fields) {
+		return nil
+	}
+
 	// Check for editor commands
 	if t.isEditor(cmdName) {
 		return t.handleEditorCommandSingle(fields, workingDir)
 	}
 
-	// Check for git commands - handle with rich metadata
-	if cmdName == "git" {
-		return t.handleGitCommandSingle(fields, workingDir)
+	// Check for version control commands - handle with rich metadata
+	if tool, ok := vcsTools[cmdName]; ok {
+		return t.handleVCSCommandSingle(tool, fields, workingDir)
 	}
 
 	// Check for build/test commands
@@ -211,6 +349,12 @@ func (t *Tracker) parseCommandToSingleActivity(command string, workingDir string
 		return t.handleBuildTestCommandSingle(fields, workingDir)
 	}
 
+	// Pull and merge request commands are attributed to the request. Their
+	// quoted titles and bodies mustn't be taken for references.
+	if activity := t.handleReviewCommandSingle(cmdName, shellFields(command), workingDir); activity != nil {
+		return activity
+	}
+
 	// Check for coding apps
 	if category, isCodingApp := codingApps[cmdName]; isCodingApp {
 		return &Activity{
@@ -218,7 +362,7 @@ func (t *Tracker) parseCommandToSingleActivity(command string, workingDir string
 			EntityType: ActivityApp,
 			Category:   category,
 			Project:    t.detectProject(workingDir),
-			Branch:     getGitBranch(workingDir),
+			Branch:     t.gitBranch(workingDir),
 			Timestamp:  time.Now(),
 		}
 	}
@@ -246,7 +390,7 @@ func (t *Tracker) parseCommandToSingleActivity(command string, workingDir string
 			EntityType: ActivityFile,
 			Category:   "browsing",
 			Project:    t.detectProject(targetDir),
-			Branch:     getGitBranch(targetDir),
+			Branch:     t.gitBranch(targetDir),
 			Timestamp:  time.Now(),
 		}
 	}
@@ -257,18 +401,28 @@ func (t *Tracker) parseCommandToSingleActivity(command string, workingDir string
 		EntityType: ActivityApp,
 		Category:   "coding",
 		Project:    t.detectProject(workingDir),
-		Branch:     getGitBranch(workingDir),
+		Branch:     t.gitBranch(workingDir),
 		Timestamp:  time.Now(),
 	}
 }
 
 func (t *Tracker) isEditor(cmdName string) bool {
-	for _, pattern := range editorPatterns {
-		if pattern.MatchString(cmdName) {
-			return true
-		}
+	return IsEditor(cmdName)
+}
+
+// IsEditor reports whether cmdName, a command's base name, is an editor
+func IsEditor(cmdName string) bool {
+	return editorPattern.MatchString(cmdName)
+}
+
+// EditorCommands returns the commands recognised as editors, sorted
+func EditorCommands() []string {
+	var commands []string
+	for _, names := range editorCommands {
+		commands = append(commands, names...)
 	}
-	return false
+	sort.Strings(commands)
+	return commands
 }
 
 func (t *Tracker) handleEditorCommand(fields []string, workingDir string) []*Activity {
@@ -293,18 +447,19 @@ func (t *Tracker) handleEditorCommand(fields []string, workingDir string) []*Act
 
 		// Check if file exists or could be created
 		if _, err := os.Stat(filePath); err == nil || !os.IsNotExist(err) {
+			lineNo, cursorPos := cursorPosition(cmdName, filePath)
 			activity := &Activity{
 				Entity:     filePath,
 				EntityType: ActivityFile,
 				Category:   "coding",
-				Language:   detectLanguage(filePath),
+				Language:   language.Detect(filePath),
 				Project:    t.detectProject(filePath),
-				Branch:     getGitBranch(filepath.Dir(filePath)),
+				Branch:     t.gitBranch(filepath.Dir(filePath)),
 				IsWrite:    true, // File editing is typically writing
 				Timestamp:  time.Now(),
 				Lines:      getFileLines(filePath),
-				LineNo:     getDefaultLineNumber(),
-				CursorPos:  getDefaultCursorPos(),
+				LineNo:     lineNo,
+				CursorPos:  cursorPos,
 			}
 			activities = append(activities, activity)
 		}
@@ -317,7 +472,7 @@ func (t *Tracker) handleEditorCommand(fields []string, workingDir string) []*Act
 			EntityType: ActivityApp,
 			Category:   "coding",
 			Project:    t.detectProject(workingDir),
-			Branch:     getGitBranch(workingDir),
+			Branch:     t.gitBranch(workingDir),
 			Timestamp:  time.Now(),
 		}
 		activities = append(activities, activity)
@@ -356,7 +511,7 @@ func (t *Tracker) handleEditorCommandSingle(fields []string, workingDir string)
 			fileCount++
 			if primaryFile == "" {
 				primaryFile = filePath
-				primaryLanguage = detectLanguage(filePath)
+				primaryLanguage = language.Detect(filePath)
 			}
 			if lines := getFileLines(filePath); lines != nil {
 				totalLines += *lines
@@ -366,18 +521,19 @@ func (t *Tracker) handleEditorCommandSingle(fields []string, workingDir string)
 
 	// If we found files, create activity for the primary file with aggregated metadata
 	if fileCount > 0 {
+		lineNo, cursorPos := cursorPosition(cmdName, primaryFile)
 		return &Activity{
 			Entity:     primaryFile,
 			EntityType: ActivityFile,
 			Category:   "coding",
 			Language:   primaryLanguage,
 			Project:    t.detectProject(primaryFile),
-			Branch:     getGitBranch(filepath.Dir(primaryFile)),
+			Branch:     t.gitBranch(filepath.Dir(primaryFile)),
 			IsWrite:    true,
 			Timestamp:  time.Now(),
 			Lines:      &totalLines,
-			LineNo:     getDefaultLineNumber(),
-			CursorPos:  getDefaultCursorPos(),
+			LineNo:     lineNo,
+			CursorPos:  cursorPos,
 		}
 	}
 
@@ -387,12 +543,22 @@ func (t *Tracker) handleEditorCommandSingle(fields []string, workingDir string)
 		EntityType: ActivityApp,
 		Category:   "coding",
 		Project:    t.detectProject(workingDir),
-		Branch:     getGitBranch(workingDir),
+		Branch:     t.gitBranch(workingDir),
 		Timestamp:  time.Now(),
 	}
 }
 
 func (t *Tracker) parseRemoteConnection(command string) string {
+	// ssh has too many options and aliases for a pattern
+	if fields := strings.Fields(command); len(fields) > 0 && filepath.Base(fields[0]) == "ssh" {
+		parsed, err := ssh.ParseCommand(fields[1:])
+		if err != nil || parsed.NoSession {
+			return ""
+		}
+		home, _ := os.UserHomeDir()
+		return parsed.Destination(home).HostName
+	}
+
 	for _, pattern := range remotePatterns {
 		matches := pattern.FindStringSubmatch(command)
 		if len(matches) > 1 {
@@ -406,12 +572,37 @@ func (t *Tracker) detectProject(filePath string) string {
 	if t.config.Project != "" {
 		return t.config.Project
 	}
+	if project := t.sessionProject(); project != "" {
+		return project
+	}
+
+	if project, ok := t.projects[filePath]; ok {
+		return project
+	}
 
 	dir := filePath
 	if !isDir(filePath) {
 		dir = filepath.Dir(filePath)
 	}
 
+	// Fallback to directory name
+	project := filepath.Base(filePath)
+	if root := findProjectRoot(dir); root != "" {
+		project = filepath.Base(root)
+	}
+
+	t.projects[filePath] = project
+	return project
+}
+
+// ProjectRoot is findProjectRoot for other packages
+func ProjectRoot(dir string) string {
+	return findProjectRoot(dir)
+}
+
+// findProjectRoot returns the nearest directory at or above dir with a
+// project indicator, or "" if there is none
+func findProjectRoot(dir string) string {
 	// Look for project indicators
 	projectFiles := []string{
 		".git",
@@ -430,19 +621,16 @@ func (t *Tracker) detectProject(filePath string) string {
 	for {
 		for _, file := range projectFiles {
 			if _, err := os.Stat(filepath.Join(dir, file)); err == nil {
-				return filepath.Base(dir)
+				return dir
 			}
 		}
 
 		parent := filepath.Dir(dir)
 		if parent == dir {
-			break
+			return ""
 		}
 		dir = parent
 	}
-
-	// Fallback to directory name
-	return filepath.Base(filePath)
 }
 
 func (t *Tracker) sendActivity(activity *Activity) error {
@@ -452,6 +640,14 @@ func (t *Tracker) sendActivity(activity *Activity) error {
 		return nil
 	}
 
+	// In an ssh session from another terminal-wakatime, that one sends it
+	if t.forwardActivity(activity) {
+		t.lastSentTime = activity.Timestamp
+		t.lastSentFile = activity.Entity
+		t.recordHeartbeat(activity)
+		return nil
+	}
+
 	// Ensure wakatime-cli is installed before sending heartbeat
 	if err := t.wakatime.EnsureInstalled(); err != nil {
 		return fmt.Errorf("failed to ensure wakatime-cli is installed: %w", err)
@@ -465,7 +661,10 @@ func (t *Tracker) sendActivity(activity *Activity) error {
 		activity.Language,
 		activity.Project,
 		activity.Branch,
+		activity.Hostname,
+		activity.Timestamp,
 		activity.IsWrite,
+		false,
 		activity.Lines,
 		activity.LineNo,
 		activity.CursorPos,
@@ -477,6 +676,7 @@ func (t *Tracker) sendActivity(activity *Activity) error {
 		// Update tracking for next decision
 		t.lastSentTime = activity.Timestamp
 		t.lastSentFile = activity.Entity
+		t.recordHeartbeat(activity)
 	}
 
 	return err
@@ -537,75 +737,6 @@ func isDir(path string) bool {
 	return err == nil && info.IsDir()
 }
 
-// detectLanguage detects programming language from file extension
-func detectLanguage(filePath string) string {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	switch ext {
-	case ".go":
-		return "Go"
-	case ".js", ".jsx":
-		return "JavaScript"
-	case ".ts", ".tsx":
-		return "TypeScript"
-	case ".py":
-		return "Python"
-	case ".rs":
-		return "Rust"
-	case ".java":
-		return "Java"
-	case ".c":
-		return "C"
-	case ".cpp", ".cc", ".cxx":
-		return "C++"
-	case ".h", ".hpp":
-		return "C Header"
-	case ".php":
-		return "PHP"
-	case ".rb":
-		return "Ruby"
-	case ".sh", ".bash", ".zsh":
-		return "Shell"
-	case ".md", ".markdown":
-		return "Markdown"
-	case ".html", ".htm":
-		return "HTML"
-	case ".css":
-		return "CSS"
-	case ".scss", ".sass":
-		return "SCSS"
-	case ".json":
-		return "JSON"
-	case ".yaml", ".yml":
-		return "YAML"
-	case ".xml":
-		return "XML"
-	case ".sql":
-		return "SQL"
-	case ".dockerfile":
-		return "Docker"
-	case ".toml":
-		return "TOML"
-	case ".ini":
-		return "INI"
-	default:
-		// Check for special cases
-		basename := strings.ToLower(filepath.Base(filePath))
-		switch basename {
-		case "dockerfile":
-			return "Docker"
-		case "makefile":
-			return "Makefile"
-		case "go.mod", "go.sum":
-			return "Go Module"
-		case "package.json", "package-lock.json":
-			return "JSON"
-		case "cargo.toml", "cargo.lock":
-			return "TOML"
-		}
-		return ""
-	}
-}
-
 // getFileLines returns the number of lines in a file
 func getFileLines(filePath string) *int {
 	file, err := os.Open(filePath)
@@ -627,206 +758,115 @@ func getFileLines(filePath string) *int {
 	return &lines
 }
 
-// getGitBranch returns the current git branch for the given directory
-func getGitBranch(dir string) string {
-	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-	cmd.Dir = dir
-	output, err := cmd.Output()
-	if err != nil {
-		return ""
-	}
-	return strings.TrimSpace(string(output))
-}
+// handleVCSCommand processes version control commands with rich metadata;
+// git write operations become one activity per changed file
+func (t *Tracker) handleVCSCommand(tool *vcsTool, fields []string, workingDir string) []*Activity {
+	parsed := parseVCSCommand(tool, fields, workingDir)
+	dir := parsed.dir
 
-// getGitChangedFiles returns files changed in the last commit with their line changes
-func getGitChangedFiles(dir string) ([]GitFileChange, error) {
-	cmd := exec.Command("git", "diff", "--stat", "HEAD~1", "HEAD", "--numstat")
-	cmd.Dir = dir
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	var changes []GitFileChange
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		parts := strings.Fields(line)
-		if len(parts) >= 3 {
-			added, _ := strconv.Atoi(parts[0])
-			deleted, _ := strconv.Atoi(parts[1])
-			filePath := parts[2]
-
-			changes = append(changes, GitFileChange{
-				FilePath:      filePath,
-				LineAdditions: added,
-				LineDeletions: deleted,
-			})
-		}
-	}
-
-	return changes, nil
-}
-
-type GitFileChange struct {
-	FilePath      string
-	LineAdditions int
-	LineDeletions int
-}
-
-// handleGitCommand processes git commands with rich metadata
-func (t *Tracker) handleGitCommand(fields []string, workingDir string) []*Activity {
 	var activities []*Activity
-
-	if len(fields) < 2 {
-		return activities
+	for _, change := range t.vcsChanges(parsed, workingDir) {
+		filePath := change.FilePath
+
+		// Line counts mean nothing for binary files
+		var lines *int
+		if !change.Binary {
+			lines = getFileLines(filePath)
+		}
+
+		activities = append(activities, &Activity{
+			Entity:        filePath,
+			EntityType:    ActivityFile,
+			Category:      parsed.category,
+			Language:      language.Detect(filePath),
+			Project:       t.detectProject(dir),
+			Branch:        t.gitBranch(dir),
+			IsWrite:       true,
+			Timestamp:     time.Now(),
+			Lines:         lines,
+			LineAdditions: &change.LineAdditions,
+			LineDeletions: &change.LineDeletions,
+		})
 	}
 
-	gitSubcommand := fields[1]
-
-	switch gitSubcommand {
-	case "commit", "push", "merge", "rebase":
-		// For commit operations, track the files being committed with line changes
-		changes, err := getGitChangedFiles(workingDir)
-		if err != nil || len(changes) == 0 {
-			// Fallback to simple git activity
-			activity := &Activity{
-				Entity:     "git " + gitSubcommand,
-				EntityType: ActivityApp,
-				Category:   "code reviewing",
-				Project:    t.detectProject(workingDir),
-				Branch:     getGitBranch(workingDir),
-				IsWrite:    true,
-				Timestamp:  time.Now(),
-			}
-			activities = append(activities, activity)
-		} else {
-			// Create activities for each changed file
-			for _, change := range changes {
-				filePath := filepath.Join(workingDir, change.FilePath)
-				activity := &Activity{
-					Entity:        filePath,
-					EntityType:    ActivityFile,
-					Category:      "code reviewing",
-					Language:      detectLanguage(filePath),
-					Project:       t.detectProject(workingDir),
-					Branch:        getGitBranch(workingDir),
-					IsWrite:       true,
-					Timestamp:     time.Now(),
-					Lines:         getFileLines(filePath),
-					LineAdditions: &change.LineAdditions,
-					LineDeletions: &change.LineDeletions,
-				}
-				activities = append(activities, activity)
-			}
-		}
-
-	case "status", "log", "diff", "show":
-		// Read operations
-		activity := &Activity{
-			Entity:     "git " + gitSubcommand,
-			EntityType: ActivityApp,
-			Category:   "code reviewing",
-			Project:    t.detectProject(workingDir),
-			Branch:     getGitBranch(workingDir),
-			IsWrite:    false,
-			Timestamp:  time.Now(),
-		}
-		activities = append(activities, activity)
-
-	default:
-		// Other git operations
-		activity := &Activity{
-			Entity:     "git " + gitSubcommand,
+	if len(activities) == 0 {
+		activities = append(activities, &Activity{
+			Entity:     parsed.Entity(),
 			EntityType: ActivityApp,
-			Category:   "code reviewing",
-			Project:    t.detectProject(workingDir),
-			Branch:     getGitBranch(workingDir),
+			Category:   parsed.category,
+			Project:    t.detectProject(dir),
+			Branch:     t.gitBranch(dir),
+			IsWrite:    parsed.isWrite,
 			Timestamp:  time.Now(),
-		}
-		activities = append(activities, activity)
+		})
 	}
 
 	return activities
 }
 
-// handleGitCommandSingle processes git commands into a single activity with aggregated metadata
-func (t *Tracker) handleGitCommandSingle(fields []string, workingDir string) *Activity {
-	if len(fields) < 2 {
-		return &Activity{
-			Entity:     "git",
-			EntityType: ActivityApp,
-			Category:   "coding",
-			Project:    t.detectProject(workingDir),
-			Branch:     getGitBranch(workingDir),
-			Timestamp:  time.Now(),
-		}
-	}
-
-	gitSubcommand := fields[1]
+// handleVCSCommandSingle processes version control commands into a single
+// activity, with line changes aggregated for git write operations
+func (t *Tracker) handleVCSCommandSingle(tool *vcsTool, fields []string, workingDir string) *Activity {
+	parsed := parseVCSCommand(tool, fields, workingDir)
+	dir := parsed.dir
 
-	// Privacy-safe command name (just git + subcommand, no arguments that might contain secrets)
-	entity := "git " + gitSubcommand
+	// Privacy-safe command name (tool + operation, no arguments that might contain secrets)
+	activity := &Activity{
+		Entity:     parsed.Entity(),
+		EntityType: ActivityApp,
+		Category:   parsed.category,
+		Project:    t.detectProject(dir),
+		Branch:     t.gitBranch(dir),
+		IsWrite:    parsed.isWrite,
+		Timestamp:  time.Now(),
+	}
 
-	switch gitSubcommand {
-	case "commit", "push", "merge", "rebase":
-		// For write operations, aggregate line changes from all affected files
-		changes, err := getGitChangedFiles(workingDir)
+	if tool == gitTool && gitLineStatOperations[parsed.operation] {
 		totalAdditions := 0
 		totalDeletions := 0
 		totalLines := 0
 
-		if err == nil && len(changes) > 0 {
-			for _, change := range changes {
-				totalAdditions += change.LineAdditions
-				totalDeletions += change.LineDeletions
-				filePath := filepath.Join(workingDir, change.FilePath)
-				if lines := getFileLines(filePath); lines != nil {
-					totalLines += *lines
-				}
+		for _, change := range t.vcsChanges(parsed, workingDir) {
+			totalAdditions += change.LineAdditions
+			totalDeletions += change.LineDeletions
+			if change.Binary {
+				continue
+			}
+			if lines := getFileLines(change.FilePath); lines != nil {
+				totalLines += *lines
 			}
 		}
 
-		return &Activity{
-			Entity:        entity,
-			EntityType:    ActivityApp,
-			Category:      "coding",
-			Project:       t.detectProject(workingDir),
-			Branch:        getGitBranch(workingDir),
-			IsWrite:       true,
-			Timestamp:     time.Now(),
-			Lines:         &totalLines,
-			LineAdditions: &totalAdditions,
-			LineDeletions: &totalDeletions,
-		}
+		activity.Lines = &totalLines
+		activity.LineAdditions = &totalAdditions
+		activity.LineDeletions = &totalDeletions
+	}
 
-	case "status", "log", "diff", "show":
-		// Read operations
-		return &Activity{
-			Entity:     entity,
-			EntityType: ActivityApp,
-			Category:   "coding",
-			Project:    t.detectProject(workingDir),
-			Branch:     getGitBranch(workingDir),
-			IsWrite:    false,
-			Timestamp:  time.Now(),
-		}
+	return activity
+}
 
-	default:
-		// Generic git command
-		return &Activity{
-			Entity:     entity,
-			EntityType: ActivityApp,
-			Category:   "coding",
-			Project:    t.detectProject(workingDir),
-			Branch:     getGitBranch(workingDir),
-			Timestamp:  time.Now(),
-		}
+// Git operations whose line changes are reported
+var gitLineStatOperations = map[string]bool{
+	"commit": true,
+	"push":   true,
+	"merge":  true,
+	"rebase": true,
+	"pull":   true,
+}
+
+// vcsChanges returns the files a git write operation changed. The hooks'
+// snapshot was taken in the shell's directory, so it only applies when
+// the command ran there.
+func (t *Tracker) vcsChanges(parsed *vcsCommand, workingDir string) []GitFileChange {
+	if parsed.tool != gitTool || !gitLineStatOperations[parsed.operation] {
+		return nil
+	}
+
+	snapshot := t.gitSnapshot
+	if parsed.dir != workingDir {
+		snapshot = nil
 	}
+	return gitCommandChanges(parsed.operation, parsed.dir, snapshot)
 }
 
 // isBuildTestCommand checks if command is a build/test operation
@@ -872,15 +912,15 @@ func (t *Tracker) handleBuildTestCommand(fields []string, workingDir string) []*
 	}
 
 	// Try to detect language from project context
-	language := t.detectProjectLanguage(workingDir)
+	projectLanguage := t.detectProjectLanguage(workingDir)
 
 	activity := &Activity{
 		Entity:     cmdName + " " + subcommand,
 		EntityType: ActivityApp,
 		Category:   category,
-		Language:   language,
+		Language:   projectLanguage,
 		Project:    t.detectProject(workingDir),
-		Branch:     getGitBranch(workingDir),
+		Branch:     t.gitBranch(workingDir),
 		Timestamp:  time.Now(),
 	}
 
@@ -915,15 +955,15 @@ func (t *Tracker) handleBuildTestCommandSingle(fields []string, workingDir strin
 	}
 
 	// Try to detect language from project context
-	language := t.detectProjectLanguage(workingDir)
+	projectLanguage := t.detectProjectLanguage(workingDir)
 
 	return &Activity{
 		Entity:     entity,
 		EntityType: ActivityApp,
 		Category:   category,
-		Language:   language,
+		Language:   projectLanguage,
 		Project:    t.detectProject(workingDir),
-		Branch:     getGitBranch(workingDir),
+		Branch:     t.gitBranch(workingDir),
 		Timestamp:  time.Now(),
 	}
 }
@@ -950,27 +990,15 @@ func isBuildCommand(subcommand string) bool {
 	return false
 }
 
-// detectProjectLanguage detects primary language from project files
-func (t *Tracker) detectProjectLanguage(workingDir string) string {
-	// Check for language-specific project files
-	languageFiles := map[string]string{
-		"go.mod":           "Go",
-		"package.json":     "JavaScript",
-		"Cargo.toml":       "Rust",
-		"pom.xml":          "Java",
-		"requirements.txt": "Python",
-		"setup.py":         "Python",
-		"Gemfile":          "Ruby",
-		"composer.json":    "PHP",
+// cursorPosition returns the line and column the editor last recorded for
+// filePath, or the defaults if it recorded none. An empty editor accepts
+// any editor's state.
+func cursorPosition(editor, filePath string) (*int, *int) {
+	if position, ok := cursor.Lookup(editor, filePath); ok {
+		return &position.Line, &position.Column
 	}
 
-	for file, language := range languageFiles {
-		if _, err := os.Stat(filepath.Join(workingDir, file)); err == nil {
-			return language
-		}
-	}
-
-	return ""
+	return getDefaultLineNumber(), getDefaultCursorPos()
 }
 
 // getDefaultLineNumber returns a default line number for file operations
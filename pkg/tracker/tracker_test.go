@@ -3,7 +3,9 @@ package tracker
 import (
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -177,6 +179,45 @@ func TestHandleEditorCommand(t *testing.T) {
 	}
 }
 
+func TestWithoutPrivilegeWrapper(t *testing.T) {
+	tests := map[string]string{
+		"sudo vim /etc/hosts":                   "vim /etc/hosts",
+		"sudo -u postgres psql -h db":           "psql -h db",
+		"sudo -E --user=www-data make deploy":   "make deploy",
+		"sudo DEBUG=1 -- docker compose up":     "docker compose up",
+		"/usr/bin/sudo sudo -n systemctl start": "systemctl start",
+		"doas -u root apk add go":               "apk add go",
+		"sudo -i":                               "sudo -i",
+		"sudo -u root":                          "sudo -u root",
+		"vim sudo":                              "vim sudo",
+	}
+
+	for command, expected := range tests {
+		if got := withoutPrivilegeWrapper(command); got != expected {
+			t.Errorf("withoutPrivilegeWrapper(%q) = %q, want %q", command, got, expected)
+		}
+	}
+}
+
+func TestShellFields(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected []string
+	}{
+		{"gh pr view 12", []string{"gh", "pr", "view", "12"}},
+		{`gh pr comment --body "secret token here" 12`, []string{"gh", "pr", "comment", "--body", "secret token here", "12"}},
+		{`git commit -m 'it''s "done"'`, []string{"git", "commit", "-m", `its "done"`}},
+		{`vim my\ notes.md ""`, []string{"vim", "my notes.md", ""}},
+		{`echo "a \"b\" c"`, []string{"echo", `a "b" c`}},
+	}
+
+	for _, tt := range tests {
+		if got := shellFields(tt.command); strings.Join(got, "|") != strings.Join(tt.expected, "|") || len(got) != len(tt.expected) {
+			t.Errorf("shellFields(%q) = %q, want %q", tt.command, got, tt.expected)
+		}
+	}
+}
+
 func TestParseRemoteConnection(t *testing.T) {
 	cfg := &config.Config{}
 	tracker := NewTracker(cfg)
@@ -187,6 +228,9 @@ func TestParseRemoteConnection(t *testing.T) {
 	}{
 		{"ssh user@example.com", "example.com"},
 		{"ssh example.com", "example.com"},
+		{"ssh -p 2222 -i key user@host.example.com", "host.example.com"},
+		{"ssh -J bastion -o ConnectTimeout=5 db.example.com uptime", "db.example.com"},
+		{"ssh -V", ""},
 		{"mysql -h db.example.com -u user", "db.example.com"},
 		{"psql -h localhost -d mydb", "localhost"},
 		{"redis-cli -h redis.example.com", "redis.example.com"},
@@ -245,7 +289,7 @@ func TestTrackFile(t *testing.T) {
 
 	// This would normally send a heartbeat, but we can't test that without mocking
 	// We'll just ensure the method doesn't panic and accepts the parameters
-	err := tracker.TrackFile(testFile, false)
+	err := tracker.TrackFile("vim", testFile, false)
 
 	// We expect an error because wakatime-cli is not installed in test environment
 	// But we want to make sure the method processes the parameters correctly
@@ -360,3 +404,235 @@ func TestShouldSendHeartbeat(t *testing.T) {
 		t.Error("Expected to send first heartbeat")
 	}
 }
+
+func TestRecordAndLoadLastHeartbeat(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", originalHome)
+	os.Setenv("HOME", tempDir)
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+	tracker := NewTracker(cfg)
+
+	record, err := LoadLastHeartbeat(cfg)
+	if err != nil || record != nil {
+		t.Fatalf("Expected no heartbeat yet, got %+v (err %v)", record, err)
+	}
+
+	sent := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	tracker.recordHeartbeat(&Activity{
+		Entity:     "/src/api/main.go",
+		EntityType: ActivityFile,
+		Category:   "coding",
+		Language:   "Go",
+		Project:    "api",
+		Branch:     "main",
+		Timestamp:  sent,
+	})
+
+	record, err = LoadLastHeartbeat(cfg)
+	if err != nil {
+		t.Fatalf("LoadLastHeartbeat() failed: %v", err)
+	}
+	if record == nil || !record.Time.Equal(sent) || record.Project != "api" || record.Branch != "main" {
+		t.Errorf("Unexpected heartbeat record %+v", record)
+	}
+}
+
+func TestDailySummary(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", originalHome)
+	os.Setenv("HOME", tempDir)
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+	tracker := NewTracker(cfg)
+
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local)
+	heartbeats := []struct {
+		offset  time.Duration
+		project string
+	}{
+		{0, "api"},
+		{2 * time.Minute, "api"},        // +2m api
+		{5 * time.Minute, "web"},        // +3m api
+		{6 * time.Minute, "web"},        // +1m web
+		{60 * time.Minute, "web"},       // gap over the timeout, not counted
+		{62 * time.Minute, "api"},       // +2m web
+		{61 * time.Minute, "late-sync"}, // out of order, not counted
+	}
+
+	for _, hb := range heartbeats {
+		tracker.recordHeartbeat(&Activity{
+			Entity:     hb.project,
+			EntityType: ActivityApp,
+			Project:    hb.project,
+			Timestamp:  start.Add(hb.offset),
+		})
+	}
+
+	summary, err := loadDailySummary(cfg, start)
+	if err != nil {
+		t.Fatalf("loadDailySummary() failed: %v", err)
+	}
+
+	if summary.Total() != 8*time.Minute {
+		t.Errorf("Expected 8m total, got %v", summary.Total())
+	}
+	if summary.ProjectTotal("api") != 5*time.Minute {
+		t.Errorf("Expected 5m for api, got %v", summary.ProjectTotal("api"))
+	}
+	if summary.ProjectTotal("web") != 3*time.Minute {
+		t.Errorf("Expected 3m for web, got %v", summary.ProjectTotal("web"))
+	}
+	if summary.LastProject != "api" {
+		t.Errorf("Expected last project api, got %s", summary.LastProject)
+	}
+
+	// A new day starts from zero
+	summary, err = loadDailySummary(cfg, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("loadDailySummary() failed: %v", err)
+	}
+	if summary.Total() != 0 || summary.LastProject != "" {
+		t.Errorf("Expected empty summary for the next day, got %+v", summary)
+	}
+}
+
+func TestWithStateLock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+
+	// Separate trackers stand in for separate processes doing an unlocked
+	// read-modify-write of the same file
+	counter := filepath.Join(cfg.WakaTimeDir(), "counter")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			NewTracker(cfg).withStateLock("counter", func() {
+				data, _ := os.ReadFile(counter)
+				n, _ := strconv.Atoi(string(data))
+				time.Sleep(time.Millisecond)
+				os.WriteFile(counter, []byte(strconv.Itoa(n+1)), 0644)
+			})
+		}()
+	}
+	wg.Wait()
+
+	if data, _ := os.ReadFile(counter); string(data) != "20" {
+		t.Errorf("Expected 20 serialised increments, got %q", data)
+	}
+	if _, err := os.Stat(counter + ".lock"); !os.IsNotExist(err) {
+		t.Error("Expected the lock to be released")
+	}
+}
+
+func TestReadJournal(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", originalHome)
+	os.Setenv("HOME", tempDir)
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+	tracker := NewTracker(cfg)
+
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		tracker.recordHeartbeat(&Activity{
+			Entity:     "make",
+			EntityType: ActivityApp,
+			Category:   "building",
+			Project:    "api",
+			Command:    "make",
+			Timestamp:  start.Add(time.Duration(i) * time.Hour),
+		})
+	}
+
+	// Garbage lines are skipped
+	journal, err := os.OpenFile(filepath.Join(cfg.WakaTimeDir(), journalMonthFile(start)), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	journal.WriteString("not json\n")
+	journal.Close()
+
+	records, err := ReadJournal(cfg, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("ReadJournal() failed: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+	if records[0].Command != "make" || records[0].Project != "api" {
+		t.Errorf("Unexpected record %+v", records[0])
+	}
+
+	records, err = ReadJournal(cfg, start.Add(time.Hour), start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("ReadJournal() failed: %v", err)
+	}
+	if len(records) != 1 || !records[0].Time.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected only the 10:00 record, got %+v", records)
+	}
+}
+
+func TestJournalRotation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+	tracker := NewTracker(cfg)
+
+	// A journal from before rotation is still read
+	if err := os.MkdirAll(cfg.WakaTimeDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	legacy := `{"time":"2023-01-15T10:00:00Z","entity":"vim","type":"app"}` + "\n"
+	if err := os.WriteFile(filepath.Join(cfg.WakaTimeDir(), JournalFile), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	months := []time.Time{
+		time.Date(2023, 3, 31, 23, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC),
+	}
+	for _, month := range months {
+		tracker.recordHeartbeat(&Activity{Entity: "make", EntityType: ActivityApp, Timestamp: month})
+	}
+
+	// Starting April 2024 pruned March 2023, which is past retention
+	if _, err := os.Stat(filepath.Join(cfg.WakaTimeDir(), "activity-2023-03.jsonl")); !os.IsNotExist(err) {
+		t.Error("Expected the journal past retention to be pruned")
+	}
+	if files := JournalFiles(cfg); len(files) != 3 || filepath.Base(files[0]) != JournalFile {
+		t.Errorf("Expected the legacy and two month journals, got %v", files)
+	}
+
+	records, err := ReadJournal(cfg, time.Time{}, time.Time{})
+	if err != nil || len(records) != 3 {
+		t.Errorf("Expected 3 records, got %+v (err %v)", records, err)
+	}
+
+	records, err = ReadJournal(cfg, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+	if err != nil || len(records) != 1 || !records[0].Time.Equal(months[2]) {
+		t.Errorf("Expected only the April record, got %+v (err %v)", records, err)
+	}
+}
@@ -0,0 +1,468 @@
+package tracker
+
+import (
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Categories of version control operations, as WakaTime names them
+const (
+	categoryReviewing = "code reviewing"
+	categoryCoding    = "coding"
+	categoryBuilding  = "building"
+	categoryDebugging = "debugging"
+)
+
+// vcsRule classifies one operation. Rules are tried in order, so more
+// specific ones (with flags or a sub-subcommand) come first.
+type vcsRule struct {
+	// command is the subcommand, with a second word for nested commands
+	// such as "bisect run" or jj's "git push"
+	command string
+
+	// flags, if set, must include at least one of these
+	flags []string
+
+	category string
+	isWrite  bool
+}
+
+// vcsTool describes how a version control tool's command line is laid out
+type vcsTool struct {
+	name string
+
+	// Global options before the subcommand that take a value. dirOptions
+	// change the directory the command runs in and configOptions set
+	// configuration, such as aliases, for one invocation.
+	valueOptions  []string
+	dirOptions    []string
+	configOptions []string
+
+	// aliasKey prefixes alias names in the tool's configuration
+	aliasKey string
+
+	// builtins are commands aliases can't shadow, besides those in rules
+	builtins []string
+
+	// implicit is what the tool runs without a subcommand
+	implicit string
+
+	rules []vcsRule
+}
+
+// vcsCommand is a parsed version control command line
+type vcsCommand struct {
+	tool *vcsTool
+
+	// dir is where the command ran, after -C or --cwd
+	dir string
+
+	// operation is the matched rule's command, or the bare subcommand
+	operation string
+	category  string
+	isWrite   bool
+}
+
+// Entity returns the privacy-safe "tool operation" name
+func (c *vcsCommand) Entity() string {
+	if c.operation == "" {
+		return c.tool.name
+	}
+	return c.tool.name + " " + c.operation
+}
+
+// Flags that put commands into interactive patch mode, where hunks are
+// picked or edited by hand
+var gitPatchFlags = []string{"-p", "--patch", "-i", "--interactive", "-e", "--edit"}
+
+var gitTool = &vcsTool{
+	name:          "git",
+	valueOptions:  []string{"-C", "-c", "--git-dir", "--work-tree", "--namespace", "--super-prefix", "--config-env"},
+	dirOptions:    []string{"-C"},
+	configOptions: []string{"-c"},
+	aliasKey:      "alias.",
+	builtins: []string{
+		"branch", "checkout", "clean", "clone", "config", "fetch", "gc", "init", "ls-files",
+		"mv", "notes", "prune", "remote", "reset", "restore", "rm", "show-branch", "sparse-checkout",
+		"stash", "submodule", "switch", "tag", "worktree", "help", "version",
+	},
+	implicit: "help",
+	rules: []vcsRule{
+		{command: "log", category: categoryReviewing},
+		{command: "diff", category: categoryReviewing},
+		{command: "show", category: categoryReviewing},
+		{command: "blame", category: categoryReviewing},
+		{command: "annotate", category: categoryReviewing},
+		{command: "range-diff", category: categoryReviewing},
+		{command: "shortlog", category: categoryReviewing},
+		{command: "whatchanged", category: categoryReviewing},
+		{command: "difftool", category: categoryReviewing},
+		{command: "reflog", category: categoryReviewing},
+		{command: "grep", category: categoryReviewing},
+		{command: "status", category: categoryReviewing},
+
+		{command: "commit", category: categoryCoding, isWrite: true},
+		{command: "add", flags: gitPatchFlags, category: categoryCoding},
+		{command: "restore", flags: gitPatchFlags, category: categoryCoding},
+		{command: "checkout", flags: gitPatchFlags, category: categoryCoding},
+		{command: "reset", flags: gitPatchFlags, category: categoryCoding},
+		{command: "stash", flags: gitPatchFlags, category: categoryCoding},
+		{command: "rebase", flags: []string{"-i", "--interactive"}, category: categoryCoding, isWrite: true},
+		{command: "mergetool", category: categoryCoding},
+		{command: "apply", category: categoryCoding, isWrite: true},
+		{command: "am", category: categoryCoding, isWrite: true},
+
+		{command: "bisect run", category: categoryBuilding},
+		{command: "bisect", category: categoryDebugging},
+
+		{command: "add", category: categoryReviewing},
+		{command: "push", category: categoryReviewing, isWrite: true},
+		{command: "pull", category: categoryReviewing, isWrite: true},
+		{command: "merge", category: categoryReviewing, isWrite: true},
+		{command: "rebase", category: categoryReviewing, isWrite: true},
+		{command: "cherry-pick", category: categoryReviewing, isWrite: true},
+		{command: "revert", category: categoryReviewing, isWrite: true},
+	},
+}
+
+var jjTool = &vcsTool{
+	name:          "jj",
+	valueOptions:  []string{"-R", "--repository", "--at-operation", "--at-op", "--color", "--config", "--config-toml", "--config-file"},
+	dirOptions:    []string{"-R", "--repository"},
+	configOptions: []string{"--config"},
+	aliasKey:      "aliases.",
+	builtins: []string{
+		"bookmark", "branch", "config", "duplicate", "file", "git", "help", "init", "operation",
+		"op", "parallelize", "prev", "next", "root", "sparse", "tag", "util", "version", "workspace",
+	},
+	implicit: "log",
+	rules: []vcsRule{
+		{command: "log", category: categoryReviewing},
+		{command: "diff", category: categoryReviewing},
+		{command: "show", category: categoryReviewing},
+		{command: "status", category: categoryReviewing},
+		{command: "st", category: categoryReviewing},
+		{command: "evolog", category: categoryReviewing},
+		{command: "obslog", category: categoryReviewing},
+		{command: "interdiff", category: categoryReviewing},
+		{command: "op log", category: categoryReviewing},
+		{command: "operation log", category: categoryReviewing},
+		{command: "file annotate", category: categoryReviewing},
+
+		{command: "commit", category: categoryCoding, isWrite: true},
+		{command: "ci", category: categoryCoding, isWrite: true},
+		{command: "describe", category: categoryCoding, isWrite: true},
+		{command: "desc", category: categoryCoding, isWrite: true},
+		{command: "new", category: categoryCoding, isWrite: true},
+		{command: "split", category: categoryCoding, isWrite: true},
+		{command: "squash", category: categoryCoding, isWrite: true},
+		{command: "absorb", category: categoryCoding, isWrite: true},
+		{command: "diffedit", category: categoryCoding, isWrite: true},
+		{command: "edit", category: categoryCoding},
+		{command: "restore", category: categoryCoding},
+		{command: "resolve", category: categoryCoding},
+
+		{command: "bisect run", category: categoryBuilding},
+
+		{command: "git push", category: categoryReviewing, isWrite: true},
+		{command: "rebase", category: categoryReviewing, isWrite: true},
+		{command: "abandon", category: categoryReviewing, isWrite: true},
+	},
+}
+
+// Mercurial and Sapling share a command line; Sapling's bare `sl` shows
+// the smartlog
+var hgRules = []vcsRule{
+	{command: "log", category: categoryReviewing},
+	{command: "diff", category: categoryReviewing},
+	{command: "show", category: categoryReviewing},
+	{command: "status", category: categoryReviewing},
+	{command: "st", category: categoryReviewing},
+	{command: "annotate", category: categoryReviewing},
+	{command: "blame", category: categoryReviewing},
+	{command: "export", category: categoryReviewing},
+	{command: "incoming", category: categoryReviewing},
+	{command: "outgoing", category: categoryReviewing},
+	{command: "summary", category: categoryReviewing},
+	{command: "grep", category: categoryReviewing},
+	{command: "smartlog", category: categoryReviewing},
+	{command: "sl", category: categoryReviewing},
+	{command: "ssl", category: categoryReviewing},
+
+	{command: "commit", category: categoryCoding, isWrite: true},
+	{command: "ci", category: categoryCoding, isWrite: true},
+	{command: "amend", category: categoryCoding, isWrite: true},
+	{command: "record", category: categoryCoding, isWrite: true},
+	{command: "absorb", category: categoryCoding, isWrite: true},
+	{command: "histedit", category: categoryCoding, isWrite: true},
+	{command: "split", category: categoryCoding, isWrite: true},
+	{command: "fold", category: categoryCoding, isWrite: true},
+	{command: "metaedit", category: categoryCoding, isWrite: true},
+	{command: "uncommit", category: categoryCoding, isWrite: true},
+	{command: "resolve", category: categoryCoding},
+
+	{command: "bisect", flags: []string{"-c", "--command"}, category: categoryBuilding},
+	{command: "bisect", category: categoryDebugging},
+
+	{command: "push", category: categoryReviewing, isWrite: true},
+	{command: "pull", category: categoryReviewing, isWrite: true},
+	{command: "merge", category: categoryReviewing, isWrite: true},
+	{command: "rebase", category: categoryReviewing, isWrite: true},
+	{command: "graft", category: categoryReviewing, isWrite: true},
+	{command: "backout", category: categoryReviewing, isWrite: true},
+}
+
+var hgGlobalValueOptions = []string{"-R", "--repository", "--repo", "--cwd", "--config", "--encoding", "--encodingmode", "--color", "--pager"}
+
+var hgTool = &vcsTool{
+	name:          "hg",
+	valueOptions:  hgGlobalValueOptions,
+	dirOptions:    []string{"-R", "--repository", "--repo", "--cwd"},
+	configOptions: []string{"--config"},
+	aliasKey:      "alias.",
+	implicit:      "help",
+	rules:         hgRules,
+}
+
+var slTool = &vcsTool{
+	name:          "sl",
+	valueOptions:  hgGlobalValueOptions,
+	dirOptions:    []string{"-R", "--repository", "--repo", "--cwd"},
+	configOptions: []string{"--config"},
+	aliasKey:      "alias.",
+	implicit:      "smartlog",
+	rules:         hgRules,
+}
+
+var fossilTool = &vcsTool{
+	name:         "fossil",
+	valueOptions: []string{"-R", "--repository", "--chdir", "--comfmtflags", "--errorlog", "-U", "--user"},
+	dirOptions:   []string{"--chdir"},
+	implicit:     "help",
+	rules: []vcsRule{
+		{command: "timeline", category: categoryReviewing},
+		{command: "diff", category: categoryReviewing},
+		{command: "gdiff", category: categoryReviewing},
+		{command: "status", category: categoryReviewing},
+		{command: "changes", category: categoryReviewing},
+		{command: "annotate", category: categoryReviewing},
+		{command: "blame", category: categoryReviewing},
+		{command: "praise", category: categoryReviewing},
+		{command: "finfo", category: categoryReviewing},
+		{command: "info", category: categoryReviewing},
+		{command: "search", category: categoryReviewing},
+		{command: "grep", category: categoryReviewing},
+
+		{command: "commit", category: categoryCoding, isWrite: true},
+		{command: "ci", category: categoryCoding, isWrite: true},
+		{command: "amend", category: categoryCoding, isWrite: true},
+
+		{command: "bisect", category: categoryDebugging},
+
+		{command: "push", category: categoryReviewing, isWrite: true},
+		{command: "pull", category: categoryReviewing, isWrite: true},
+		{command: "sync", category: categoryReviewing, isWrite: true},
+		{command: "merge", category: categoryReviewing, isWrite: true},
+		{command: "update", category: categoryReviewing, isWrite: true},
+	},
+}
+
+// vcsTools are the version control tools by command name
+var vcsTools = map[string]*vcsTool{
+	"git":    gitTool,
+	"jj":     jjTool,
+	"hg":     hgTool,
+	"sl":     slTool,
+	"fossil": fossilTool,
+}
+
+// Aliases can expand to other aliases; git stops loops, we stop at depth
+const maxAliasDepth = 5
+
+// vcsAliasLookup returns the configured alias for name, or "". It runs the
+// tool itself, and is a variable so tests can stub it.
+var vcsAliasLookup = func(tool, dir, key string) string {
+	var cmd *exec.Cmd
+	switch tool {
+	case "git":
+		cmd = exec.Command("git", "config", "--get", key)
+	case "jj":
+		// jj aliases are arrays, printed in TOML
+		cmd = exec.Command("jj", "config", "get", key)
+	case "hg", "sl":
+		cmd = exec.Command(tool, "config", key)
+	default:
+		return ""
+	}
+	cmd.Dir = dir
+
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// quotedString matches the strings of a TOML array, as jj prints aliases
+var quotedString = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"|'([^']*)'`)
+
+// parseVCSCommand parses fields, a command line of tool, run in workingDir
+func parseVCSCommand(tool *vcsTool, fields []string, workingDir string) *vcsCommand {
+	parsed := &vcsCommand{tool: tool, dir: workingDir, category: categoryReviewing}
+	config := map[string]string{}
+
+	// Global options come before the subcommand
+	args := fields[1:]
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-" {
+		option, value, hasValue := strings.Cut(args[0], "=")
+		args = args[1:]
+
+		if !hasValue {
+			if !containsString(tool.valueOptions, option) {
+				continue
+			}
+			if len(args) == 0 {
+				break
+			}
+			value, args = args[0], args[1:]
+		}
+
+		switch {
+		case containsString(tool.dirOptions, option):
+			if filepath.IsAbs(value) {
+				parsed.dir = value
+			} else {
+				parsed.dir = filepath.Join(parsed.dir, value)
+			}
+		case containsString(tool.configOptions, option):
+			if key, setting, ok := strings.Cut(value, "="); ok {
+				config[key] = setting
+			}
+		}
+	}
+
+	if len(args) == 0 {
+		args = []string{tool.implicit}
+	}
+
+	args = expandVCSAlias(tool, parsed.dir, args, config)
+	parsed.operation = args[0]
+
+	for _, rule := range tool.rules {
+		if words, ok := rule.matches(args); ok {
+			parsed.operation = strings.Join(args[:words], " ")
+			parsed.category = rule.category
+			parsed.isWrite = rule.isWrite
+			break
+		}
+	}
+
+	return parsed
+}
+
+// expandVCSAlias replaces an alias in args[0] with what it stands for.
+// Shell aliases ("!...") are classified by the first command of the tool
+// they run, if any.
+func expandVCSAlias(tool *vcsTool, dir string, args []string, config map[string]string) []string {
+	if tool.aliasKey == "" {
+		return args
+	}
+
+	for depth := 0; depth < maxAliasDepth; depth++ {
+		name := args[0]
+		if tool.isBuiltin(name) {
+			return args
+		}
+
+		key := tool.aliasKey + name
+		definition, ok := config[key]
+		if !ok {
+			definition = vcsAliasLookup(tool.name, dir, key)
+		}
+		if definition == "" {
+			return args
+		}
+
+		var expansion []string
+		if strings.HasPrefix(definition, "!") {
+			expansion = shellAliasCommand(tool.name, definition[1:])
+		} else if strings.HasPrefix(definition, "[") {
+			for _, match := range quotedString.FindAllStringSubmatch(definition, -1) {
+				expansion = append(expansion, match[1]+match[2])
+			}
+		} else {
+			expansion = strings.Fields(definition)
+		}
+
+		if len(expansion) == 0 || expansion[0] == name {
+			return args
+		}
+		args = append(expansion, args[1:]...)
+	}
+
+	return args
+}
+
+// shellAliasCommand finds "<tool> <subcommand> ..." in a shell alias
+func shellAliasCommand(tool, script string) []string {
+	fields := strings.FieldsFunc(script, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ';' || r == '&' || r == '|' || r == '(' || r == ')' || r == '{' || r == '}'
+	})
+
+	for i, field := range fields {
+		if filepath.Base(field) == tool && i+1 < len(fields) {
+			return fields[i+1:]
+		}
+	}
+	return nil
+}
+
+// matches reports whether args run the rule's command, and how many words
+// of args the command spans
+func (r vcsRule) matches(args []string) (int, bool) {
+	words := strings.Fields(r.command)
+	if len(args) < len(words) {
+		return 0, false
+	}
+	for i, word := range words {
+		if args[i] != word {
+			return 0, false
+		}
+	}
+
+	if len(r.flags) == 0 {
+		return len(words), true
+	}
+	for _, arg := range args[len(words):] {
+		if arg == "--" {
+			break
+		}
+		if containsString(r.flags, arg) {
+			return len(words), true
+		}
+	}
+	return 0, false
+}
+
+func (t *vcsTool) isBuiltin(name string) bool {
+	if containsString(t.builtins, name) {
+		return true
+	}
+	for _, rule := range t.rules {
+		if first, _, _ := strings.Cut(rule.command, " "); first == name {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
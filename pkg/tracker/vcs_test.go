@@ -0,0 +1,175 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+)
+
+// stubAliases replaces alias lookups with a fixed table keyed by
+// "tool key"
+func stubAliases(t *testing.T, aliases map[string]string) {
+	t.Helper()
+
+	saved := vcsAliasLookup
+	vcsAliasLookup = func(tool, dir, key string) string {
+		return aliases[tool+" "+key]
+	}
+	t.Cleanup(func() { vcsAliasLookup = saved })
+}
+
+func TestVCSAliasLookup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake jj is a shell script")
+	}
+
+	// A jj that only knows the documented way to read a setting
+	bin := t.TempDir()
+	script := "#!/bin/sh\n" +
+		"[ \"$1 $2 $3\" = \"config get aliases.l\" ] || exit 1\n" +
+		"echo '[\"log\", \"-r\", \"@\"]'\n"
+	if err := os.WriteFile(filepath.Join(bin, "jj"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	if got := vcsAliasLookup("jj", t.TempDir(), "aliases.l"); got != `["log", "-r", "@"]` {
+		t.Errorf("Expected the alias from jj config get, got %q", got)
+	}
+
+	parsed := parseVCSCommand(vcsTools["jj"], []string{"jj", "l"}, t.TempDir())
+	if parsed == nil || parsed.operation != "log" {
+		t.Errorf("Expected jj l to expand to log, got %+v", parsed)
+	}
+}
+
+func TestParseVCSCommand(t *testing.T) {
+	stubAliases(t, map[string]string{
+		"git alias.lg":    "log --oneline --graph",
+		"git alias.ap":    "add -p",
+		"git alias.cm":    "ci -m",
+		"git alias.ci":    "commit",
+		"git alias.sync":  "!git fetch && git rebase origin/main",
+		"git alias.loop":  "loop",
+		"git alias.fetch": "log", // builtins can't be shadowed
+		"jj aliases.l":    `["log", "-r", "(main..@):: | (main..@)-"]`,
+		"hg alias.slog":   "log --graph",
+	})
+
+	tests := []struct {
+		command  string
+		entity   string
+		category string
+		isWrite  bool
+		dir      string
+	}{
+		// Git categories
+		{"git log -p", "git log", categoryReviewing, false, "/work"},
+		{"git diff --cached", "git diff", categoryReviewing, false, "/work"},
+		{"git blame main.go", "git blame", categoryReviewing, false, "/work"},
+		{"git range-diff main...topic", "git range-diff", categoryReviewing, false, "/work"},
+		{"git commit -m msg", "git commit", categoryCoding, true, "/work"},
+		{"git add -p", "git add", categoryCoding, false, "/work"},
+		{"git add .", "git add", categoryReviewing, false, "/work"},
+		{"git add -- -p", "git add", categoryReviewing, false, "/work"},
+		{"git rebase -i HEAD~3", "git rebase", categoryCoding, true, "/work"},
+		{"git rebase main", "git rebase", categoryReviewing, true, "/work"},
+		{"git bisect run make test", "git bisect run", categoryBuilding, false, "/work"},
+		{"git bisect good", "git bisect", categoryDebugging, false, "/work"},
+		{"git push", "git push", categoryReviewing, true, "/work"},
+		{"git fetch origin", "git fetch", categoryReviewing, false, "/work"},
+		{"git", "git help", categoryReviewing, false, "/work"},
+
+		// Global options
+		{"git -C ../other -c color.ui=never --no-pager log", "git log", categoryReviewing, false, "/other"},
+		{"git -C /abs/repo commit", "git commit", categoryCoding, true, "/abs/repo"},
+		{"git --git-dir=/x/.git --work-tree /x status", "git status", categoryReviewing, false, "/work"},
+
+		// Aliases, from config and from -c
+		{"git lg", "git log", categoryReviewing, false, "/work"},
+		{"git ap", "git add", categoryCoding, false, "/work"},
+		{"git cm fix", "git commit", categoryCoding, true, "/work"},
+		{"git sync", "git fetch", categoryReviewing, false, "/work"},
+		{"git loop", "git loop", categoryReviewing, false, "/work"},
+		{"git fetch", "git fetch", categoryReviewing, false, "/work"},
+		{"git -c alias.d=diff d", "git diff", categoryReviewing, false, "/work"},
+
+		// jj
+		{"jj", "jj log", categoryReviewing, false, "/work"},
+		{"jj -R ../repo describe -m msg", "jj describe", categoryCoding, true, "/repo"},
+		{"jj git push", "jj git push", categoryReviewing, true, "/work"},
+		{"jj git fetch", "jj git", categoryReviewing, false, "/work"},
+		{"jj op log", "jj op log", categoryReviewing, false, "/work"},
+		{"jj l", "jj log", categoryReviewing, false, "/work"},
+		{"jj --config ui.color=never split", "jj split", categoryCoding, true, "/work"},
+
+		// Mercurial and Sapling
+		{"hg --cwd sub ci -m msg", "hg ci", categoryCoding, true, "/work/sub"},
+		{"hg bisect --command 'make test'", "hg bisect", categoryBuilding, false, "/work"},
+		{"hg slog", "hg log", categoryReviewing, false, "/work"},
+		{"hg --config alias.x=diff x", "hg diff", categoryReviewing, false, "/work"},
+		{"sl", "sl smartlog", categoryReviewing, false, "/work"},
+		{"sl amend", "sl amend", categoryCoding, true, "/work"},
+
+		// Fossil
+		{"fossil timeline", "fossil timeline", categoryReviewing, false, "/work"},
+		{"fossil --chdir /src/app commit", "fossil commit", categoryCoding, true, "/src/app"},
+		{"fossil -R ~/repos/app.fossil sync", "fossil sync", categoryReviewing, true, "/work"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			fields := splitTestCommand(tt.command)
+			parsed := parseVCSCommand(vcsTools[fields[0]], fields, "/work")
+
+			if parsed.Entity() != tt.entity || parsed.category != tt.category || parsed.isWrite != tt.isWrite || parsed.dir != tt.dir {
+				t.Errorf("Got %q %q write=%t in %s, expected %q %q write=%t in %s",
+					parsed.Entity(), parsed.category, parsed.isWrite, parsed.dir,
+					tt.entity, tt.category, tt.isWrite, tt.dir)
+			}
+		})
+	}
+}
+
+// splitTestCommand splits on spaces, keeping single-quoted words together
+func splitTestCommand(command string) []string {
+	var fields []string
+	var current []rune
+	quoted := false
+
+	for _, r := range command {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			if len(current) > 0 {
+				fields = append(fields, string(current))
+				current = nil
+			}
+		default:
+			current = append(current, r)
+		}
+	}
+	if len(current) > 0 {
+		fields = append(fields, string(current))
+	}
+	return fields
+}
+
+func TestHandleVCSCommandSingleCategory(t *testing.T) {
+	stubAliases(t, nil)
+	tracker := NewTracker(&config.Config{})
+
+	activity := tracker.parseCommandToSingleActivity("git log --oneline", t.TempDir())
+	if activity == nil || activity.Entity != "git log" || activity.Category != categoryReviewing || activity.IsWrite {
+		t.Errorf("Expected a code reviewing git log activity, got %+v", activity)
+	}
+
+	activity = tracker.parseCommandToSingleActivity("hg commit -m msg", t.TempDir())
+	if activity == nil || activity.Entity != "hg commit" || activity.Category != categoryCoding || !activity.IsWrite {
+		t.Errorf("Expected a coding hg commit activity, got %+v", activity)
+	}
+}
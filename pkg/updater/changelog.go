@@ -0,0 +1,168 @@
+package updater
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// Release notes of the versions covered by the last update
+	ChangelogFile = "update_changelog.json"
+
+	// Most releases kept, e.g. when updating from a dev build
+	maxChangelogReleases = 20
+)
+
+// ReleaseNotes are the notes of one release
+type ReleaseNotes struct {
+	Version     string    `json:"version"`
+	Name        string    `json:"name,omitempty"`
+	PublishedAt time.Time `json:"published_at,omitempty"`
+	Body        string    `json:"body"`
+}
+
+// Changelog holds the notes of every release between the version an update
+// replaced and the one it installed, newest first
+type Changelog struct {
+	FromVersion string         `json:"from_version"`
+	ToVersion   string         `json:"to_version"`
+	Releases    []ReleaseNotes `json:"releases"`
+}
+
+// SaveChangelog stores the notes of every release after the running
+// version up to and including release. If the release list can't be
+// fetched, only release's own notes are kept.
+func (u *Updater) SaveChangelog(release *GitHubRelease) error {
+	changelog := Changelog{FromVersion: u.currentVersion, ToVersion: release.TagName}
+
+	// On error the loop is skipped and only release is recorded
+	releases, _ := u.fetchReleases()
+
+	for _, r := range releases {
+		if len(changelog.Releases) == maxChangelogReleases {
+			break
+		}
+		if r.Draft || (r.PreRelease && !u.includePrereleases && r.TagName != release.TagName) {
+			continue
+		}
+
+		// Keep (current, installed]
+		if newer, err := versionNewer(release.TagName, r.TagName); err != nil || newer {
+			continue
+		}
+		if newer, err := u.isVersionNewer(r.TagName); err != nil || !newer {
+			continue
+		}
+
+		changelog.Releases = append(changelog.Releases, notesOf(r))
+	}
+
+	if len(changelog.Releases) == 0 || changelog.Releases[0].Version != release.TagName {
+		changelog.Releases = append([]ReleaseNotes{notesOf(*release)}, changelog.Releases...)
+	}
+
+	data, err := json.MarshalIndent(changelog, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal changelog: %w", err)
+	}
+
+	return os.WriteFile(filepath.Join(u.wakatimeDir, ChangelogFile), data, 0644)
+}
+
+// fetchReleases returns the release list, newest first
+func (u *Updater) fetchReleases() ([]GitHubRelease, error) {
+	resp, err := u.client(10 * time.Second).Get(u.releasesBase() + "?per_page=100")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch releases: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+	}
+
+	var releases []GitHubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("failed to decode releases: %w", err)
+	}
+
+	return releases, nil
+}
+
+func notesOf(release GitHubRelease) ReleaseNotes {
+	return ReleaseNotes{
+		Version:     release.TagName,
+		Name:        release.Name,
+		PublishedAt: release.PublishedAt,
+		Body:        strings.TrimSpace(strings.ReplaceAll(release.Body, "\r\n", "\n")),
+	}
+}
+
+// GetChangelog returns the notes stored by the last update, or nil
+func (u *Updater) GetChangelog() (*Changelog, error) {
+	data, err := os.ReadFile(filepath.Join(u.wakatimeDir, ChangelogFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read changelog: %w", err)
+	}
+
+	var changelog Changelog
+	if err := json.Unmarshal(data, &changelog); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal changelog: %w", err)
+	}
+
+	return &changelog, nil
+}
+
+// Summary returns up to max highlights: the list items of the notes,
+// newest release first, or their first lines if the notes have no lists.
+// The second value is how many highlights were left out.
+func (c *Changelog) Summary(max int) ([]string, int) {
+	var items, lines []string
+
+	for _, release := range c.Releases {
+		first := ""
+		for _, line := range strings.Split(release.Body, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			if item, ok := listItem(line); ok {
+				items = append(items, item)
+			} else if first == "" {
+				first = line
+			}
+		}
+		if first != "" {
+			lines = append(lines, first)
+		}
+	}
+
+	if len(items) == 0 {
+		items = lines
+	}
+
+	if len(items) <= max {
+		return items, 0
+	}
+	return items[:max], len(items) - max
+}
+
+// listItem returns the text of a Markdown bullet
+func listItem(line string) (string, bool) {
+	for _, bullet := range []string{"- ", "* ", "+ "} {
+		if strings.HasPrefix(line, bullet) {
+			return strings.TrimSpace(strings.TrimPrefix(line, bullet)), true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,127 @@
+package updater
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func newChangelogServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/releases" {
+			http.NotFound(w, r)
+			return
+		}
+
+		json.NewEncoder(w).Encode([]GitHubRelease{
+			{TagName: "v0.5.0", Draft: true, Body: "- draft"},
+			{TagName: "v0.4.0", Body: "- not installed yet"},
+			{TagName: "v0.3.0", Name: "Faster heartbeats", Body: "## Changes\r\n- Batch heartbeats\r\n- Track jj commands"},
+			{TagName: "v0.2.1-rc1", PreRelease: true, Body: "- prerelease"},
+			{TagName: "v0.2.0", Body: "Git commands now count as coding."},
+			{TagName: "v0.1.0", Body: "- already installed"},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestUpdater_SaveChangelog(t *testing.T) {
+	server := newChangelogServer(t)
+
+	updater := NewUpdater("v0.1.0", t.TempDir(), "/fake/path")
+	updater.SetReleasesURL(server.URL + "/releases/latest")
+
+	if err := updater.SaveChangelog(&GitHubRelease{TagName: "v0.3.0"}); err != nil {
+		t.Fatalf("SaveChangelog failed: %v", err)
+	}
+
+	changelog, err := updater.GetChangelog()
+	if err != nil || changelog == nil {
+		t.Fatalf("Expected a changelog, got %v (%v)", changelog, err)
+	}
+
+	var versions []string
+	for _, release := range changelog.Releases {
+		versions = append(versions, release.Version)
+	}
+	if !reflect.DeepEqual(versions, []string{"v0.3.0", "v0.2.0"}) {
+		t.Errorf("Expected notes for v0.3.0 and v0.2.0, got %v", versions)
+	}
+
+	if changelog.FromVersion != "v0.1.0" || changelog.ToVersion != "v0.3.0" {
+		t.Errorf("Unexpected versions %s → %s", changelog.FromVersion, changelog.ToVersion)
+	}
+
+	if body := changelog.Releases[0].Body; body != "## Changes\n- Batch heartbeats\n- Track jj commands" {
+		t.Errorf("Unexpected body %q", body)
+	}
+}
+
+func TestUpdater_SaveChangelogWithoutReleaseList(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	updater := NewUpdater("v0.1.0", t.TempDir(), "/fake/path")
+	updater.SetReleasesURL(server.URL + "/releases/latest")
+
+	if err := updater.SaveChangelog(&GitHubRelease{TagName: "v0.3.0", Body: "- Batch heartbeats"}); err != nil {
+		t.Fatalf("SaveChangelog failed: %v", err)
+	}
+
+	changelog, _ := updater.GetChangelog()
+	if changelog == nil || len(changelog.Releases) != 1 || changelog.Releases[0].Body != "- Batch heartbeats" {
+		t.Errorf("Expected only the installed release's notes, got %+v", changelog)
+	}
+}
+
+func TestChangelog_Summary(t *testing.T) {
+	tests := []struct {
+		name     string
+		releases []ReleaseNotes
+		max      int
+		expected []string
+		more     int
+	}{
+		{
+			name: "list items, newest first",
+			releases: []ReleaseNotes{
+				{Body: "## Changes\n- Batch heartbeats\n* Track jj commands"},
+				{Body: "- Fix fish hook"},
+			},
+			max:      2,
+			expected: []string{"Batch heartbeats", "Track jj commands"},
+			more:     1,
+		},
+		{
+			name: "first lines without lists",
+			releases: []ReleaseNotes{
+				{Body: "# v0.3.0\n\nGit commands now count as coding.\nMore details."},
+				{Body: ""},
+			},
+			max:      3,
+			expected: []string{"Git commands now count as coding."},
+		},
+		{
+			name:     "no notes",
+			releases: []ReleaseNotes{{Body: ""}},
+			max:      3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changelog := &Changelog{Releases: tt.releases}
+
+			highlights, more := changelog.Summary(tt.max)
+			if !reflect.DeepEqual(highlights, tt.expected) || more != tt.more {
+				t.Errorf("Expected %v (+%d), got %v (+%d)", tt.expected, tt.more, highlights, more)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Package managers whose installs must not replace their own binary
+const (
+	ManagerNix       = "Nix"
+	ManagerHomebrew  = "Homebrew"
+	ManagerGoInstall = "go install"
+)
+
+// ManagedBy returns the package manager that installed the binary at path,
+// or "" for a standalone install that may update itself
+func ManagedBy(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	switch {
+	case strings.HasPrefix(path, "/nix/store/"):
+		return ManagerNix
+	case strings.Contains(path, "/Cellar/"),
+		strings.HasPrefix(path, "/opt/homebrew/"),
+		strings.HasPrefix(path, "/home/linuxbrew/.linuxbrew/"):
+		return ManagerHomebrew
+	}
+
+	dir := filepath.Dir(path)
+	for _, binDir := range goBinDirs() {
+		if resolved, err := filepath.EvalSymlinks(binDir); err == nil {
+			binDir = resolved
+		}
+		if dir == filepath.Clean(binDir) {
+			return ManagerGoInstall
+		}
+	}
+
+	return ""
+}
+
+// goBinDirs returns where `go install` puts binaries: $GOBIN, or bin in
+// each $GOPATH entry (~/go by default)
+func goBinDirs() []string {
+	if gobin := os.Getenv("GOBIN"); gobin != "" {
+		return []string{gobin}
+	}
+
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		gopath = filepath.Join(home, "go")
+	}
+
+	var dirs []string
+	for _, entry := range filepath.SplitList(gopath) {
+		if entry != "" {
+			dirs = append(dirs, filepath.Join(entry, "bin"))
+		}
+	}
+	return dirs
+}
+
+// UpgradeCommand returns how to update an install made by manager
+func UpgradeCommand(manager string) string {
+	switch manager {
+	case ManagerNix:
+		return "nix profile upgrade terminal-wakatime"
+	case ManagerHomebrew:
+		return "brew upgrade terminal-wakatime"
+	case ManagerGoInstall:
+		return "go install github.com/hackclub/terminal-wakatime/cmd/terminal-wakatime@latest"
+	}
+
+	return "terminal-wakatime update"
+}
@@ -0,0 +1,59 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManagedBy(t *testing.T) {
+	home := t.TempDir()
+	originalHome, originalGOPATH, originalGOBIN := os.Getenv("HOME"), os.Getenv("GOPATH"), os.Getenv("GOBIN")
+	t.Cleanup(func() {
+		os.Setenv("HOME", originalHome)
+		os.Setenv("GOPATH", originalGOPATH)
+		os.Setenv("GOBIN", originalGOBIN)
+	})
+	os.Setenv("HOME", home)
+	os.Unsetenv("GOPATH")
+	os.Unsetenv("GOBIN")
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/nix/store/abc123-terminal-wakatime-0.1.0/bin/terminal-wakatime", ManagerNix},
+		{"/opt/homebrew/bin/terminal-wakatime", ManagerHomebrew},
+		{"/usr/local/Cellar/terminal-wakatime/0.1.0/bin/terminal-wakatime", ManagerHomebrew},
+		{"/home/linuxbrew/.linuxbrew/bin/terminal-wakatime", ManagerHomebrew},
+		{filepath.Join(home, "go", "bin", "terminal-wakatime"), ManagerGoInstall},
+		{filepath.Join(home, ".wakatime", "terminal-wakatime"), ""},
+		{"/usr/local/bin/terminal-wakatime", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ManagedBy(tt.path); got != tt.expected {
+			t.Errorf("ManagedBy(%s) = %q, want %q", tt.path, got, tt.expected)
+		}
+	}
+
+	// GOBIN takes precedence over GOPATH
+	os.Setenv("GOBIN", filepath.Join(home, "gobin"))
+	if got := ManagedBy(filepath.Join(home, "gobin", "terminal-wakatime")); got != ManagerGoInstall {
+		t.Errorf("Expected GOBIN install to be detected, got %q", got)
+	}
+	if got := ManagedBy(filepath.Join(home, "go", "bin", "terminal-wakatime")); got != "" {
+		t.Errorf("Expected ~/go/bin to be ignored when GOBIN is set, got %q", got)
+	}
+
+	// Symlinks into a package manager's store count as managed
+	link := filepath.Join(home, "terminal-wakatime")
+	os.MkdirAll(filepath.Join(home, "gobin"), 0755)
+	target := filepath.Join(home, "gobin", "terminal-wakatime")
+	os.WriteFile(target, []byte("binary"), 0755)
+	if err := os.Symlink(target, link); err == nil {
+		if got := ManagedBy(link); got != ManagerGoInstall {
+			t.Errorf("Expected symlinked go install to be detected, got %q", got)
+		}
+	}
+}
@@ -1,11 +1,15 @@
 package updater
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -24,19 +28,42 @@ const (
 	LastCheckFile  = "terminal-wakatime_last_update_check.txt"
 	UpdateInfoFile = "update_info"
 	TempBinaryFile = "terminal-wakatime.new"
+
+	// The binary replaced by the last update, for Rollback
+	PreviousBinaryFile = "terminal-wakatime.previous"
+
+	// Version rolled back from, which automatic updates skip
+	RollbackFile = "update_rollback"
+
+	// Newer version found in notify-only mode
+	AvailableUpdateFile = "update_available"
+
+	// Release asset listing the SHA-256 of every binary
+	ChecksumsAsset = "checksums_sha256.txt"
+
+	// How long the post-install `version` check may take
+	smokeTestTimeout = 10 * time.Second
 )
 
 type Updater struct {
 	currentVersion string
 	wakatimeDir    string
 	binaryPath     string
+	releasesURL    string
+	httpClient     *http.Client
+
+	includePrereleases bool
+	notifyOnly         bool
 }
 
 type GitHubRelease struct {
-	TagName    string `json:"tag_name"`
-	Name       string `json:"name"`
-	PreRelease bool   `json:"prerelease"`
-	Assets     []struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Body        string    `json:"body"`
+	Draft       bool      `json:"draft"`
+	PreRelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+	Assets      []struct {
 		Name               string `json:"name"`
 		BrowserDownloadURL string `json:"browser_download_url"`
 	} `json:"assets"`
@@ -48,12 +75,55 @@ type UpdateInfo struct {
 	UpdateTime  time.Time `json:"update_time"`
 }
 
+// AvailableUpdate is a newer version found in notify-only mode
+type AvailableUpdate struct {
+	Version  string `json:"version"`
+	Notified bool   `json:"notified"`
+}
+
 func NewUpdater(currentVersion, wakatimeDir, binaryPath string) *Updater {
 	return &Updater{
 		currentVersion: currentVersion,
 		wakatimeDir:    wakatimeDir,
 		binaryPath:     binaryPath,
+		releasesURL:    ReleasesAPI,
+	}
+}
+
+// SetReleasesURL overrides the release endpoint, e.g. for a mirror. An
+// empty url keeps the default.
+func (u *Updater) SetReleasesURL(url string) {
+	if url != "" {
+		u.releasesURL = url
+	}
+}
+
+// SetHTTPClient sets the client used for update checks and downloads, so
+// proxy and CA bundle settings apply. Request timeouts are still enforced.
+func (u *Updater) SetHTTPClient(client *http.Client) {
+	u.httpClient = client
+}
+
+// SetIncludePrereleases makes update checks consider pre-releases, for the
+// prerelease channel
+func (u *Updater) SetIncludePrereleases(include bool) {
+	u.includePrereleases = include
+}
+
+// SetNotifyOnly makes background checks record newer versions for a
+// notification instead of installing them
+func (u *Updater) SetNotifyOnly(notifyOnly bool) {
+	u.notifyOnly = notifyOnly
+}
+
+// client returns a copy of the configured client with the given timeout
+func (u *Updater) client(timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if u.httpClient != nil {
+		*client = *u.httpClient
+		client.Timeout = timeout
 	}
+	return client
 }
 
 // ShouldCheckForUpdate returns true if it's time to check for updates
@@ -85,41 +155,86 @@ func (u *Updater) UpdateLastCheckTime() error {
 
 // CheckForUpdate checks GitHub for a newer version
 func (u *Updater) CheckForUpdate() (*GitHubRelease, bool, error) {
-	client := &http.Client{Timeout: 5 * time.Second}
+	release, err := u.fetchRelease()
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Skip pre-releases unless on the prerelease channel
+	if release == nil || (release.PreRelease && !u.includePrereleases) {
+		return nil, false, nil
+	}
+
+	// Don't reinstall a version the user rolled back from
+	if release.TagName == u.rolledBackFrom() {
+		return release, false, nil
+	}
+
+	// Compare versions
+	isNewer, err := u.isVersionNewer(release.TagName)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to compare versions: %w", err)
+	}
+
+	return release, isNewer, nil
+}
+
+// fetchRelease returns the latest release. releases/latest never includes
+// pre-releases, so the prerelease channel reads the newest entry of the
+// release list instead.
+func (u *Updater) fetchRelease() (*GitHubRelease, error) {
+	endpoint := u.releasesURL
+	if u.includePrereleases {
+		endpoint = u.releasesBase() + "?per_page=10"
+	}
 
-	resp, err := client.Get(ReleasesAPI)
+	resp, err := u.client(5 * time.Second).Get(endpoint)
 	if err != nil {
-		return nil, false, fmt.Errorf("failed to check for updates: %w", err)
+		return nil, fmt.Errorf("failed to check for updates: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, false, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+		return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
 	}
 
-	var release GitHubRelease
-	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
-		return nil, false, fmt.Errorf("failed to decode release info: %w", err)
+	if !u.includePrereleases {
+		var release GitHubRelease
+		if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+			return nil, fmt.Errorf("failed to decode release info: %w", err)
+		}
+		return &release, nil
 	}
 
-	// Skip pre-releases
-	if release.PreRelease {
-		return nil, false, nil
+	var releases []GitHubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("failed to decode release info: %w", err)
 	}
 
-	// Compare versions
-	isNewer, err := u.isVersionNewer(release.TagName)
-	if err != nil {
-		return nil, false, fmt.Errorf("failed to compare versions: %w", err)
+	for i := range releases {
+		if !releases[i].Draft {
+			return &releases[i], nil
+		}
 	}
 
-	return &release, isNewer, nil
+	return nil, nil
+}
+
+// releasesBase is the release list endpoint, releasesURL without /latest
+func (u *Updater) releasesBase() string {
+	return strings.TrimSuffix(strings.TrimRight(u.releasesURL, "/"), "/latest")
 }
 
-// isVersionNewer compares semantic versions (simple implementation)
+// isVersionNewer reports whether newVersion is newer than the running one
 func (u *Updater) isVersionNewer(newVersion string) (bool, error) {
-	current := strings.TrimPrefix(u.currentVersion, "v")
-	new := strings.TrimPrefix(newVersion, "v")
+	return versionNewer(u.currentVersion, newVersion)
+}
+
+// versionNewer compares semantic versions (simple implementation). A
+// pre-release such as 0.2.0-rc1 is older than 0.2.0.
+func versionNewer(currentVersion, newVersion string) (bool, error) {
+	current, currentPre, _ := strings.Cut(strings.TrimPrefix(currentVersion, "v"), "-")
+	new, newPre, _ := strings.Cut(strings.TrimPrefix(newVersion, "v"), "-")
 
 	// Handle development version - always consider any release newer than "dev"
 	if current == "dev" || current == "" {
@@ -157,30 +272,129 @@ func (u *Updater) isVersionNewer(newVersion string) (bool, error) {
 		// Continue to next part if equal
 	}
 
+	// Same release; a final release is newer than its pre-releases
+	switch {
+	case currentPre != "" && newPre == "":
+		return true, nil
+	case currentPre != "" && newPre != "":
+		return newPre > currentPre, nil
+	}
+
 	return false, nil // Versions are equal
 }
 
-// GetAssetURL returns the download URL for the current platform
-func (u *Updater) GetAssetURL(release *GitHubRelease) (string, error) {
+// assetName is the release asset for the current platform
+func assetName() string {
 	platform := fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH)
 	if runtime.GOOS == "windows" {
 		platform += ".exe"
 	}
 
-	expectedName := fmt.Sprintf("terminal-wakatime-%s", platform)
+	return fmt.Sprintf("terminal-wakatime-%s", platform)
+}
+
+// GetAssetURL returns the download URL for the current platform
+func (u *Updater) GetAssetURL(release *GitHubRelease) (string, error) {
+	return findAsset(release, assetName())
+}
 
+func findAsset(release *GitHubRelease, name string) (string, error) {
 	for _, asset := range release.Assets {
-		if asset.Name == expectedName {
+		if asset.Name == name {
 			return asset.BrowserDownloadURL, nil
 		}
 	}
 
-	return "", fmt.Errorf("no asset found for platform %s", platform)
+	return "", fmt.Errorf("no asset %s in release %s", name, release.TagName)
+}
+
+// DownloadRelease downloads the binary for the current platform and
+// verifies it against the release's checksum file. Releases without
+// checksums are refused.
+func (u *Updater) DownloadRelease(release *GitHubRelease) error {
+	downloadURL, err := u.GetAssetURL(release)
+	if err != nil {
+		return err
+	}
+
+	checksumsURL, err := findAsset(release, ChecksumsAsset)
+	if err != nil {
+		return fmt.Errorf("refusing unverified update: %w", err)
+	}
+
+	expected, err := u.fetchChecksum(checksumsURL, assetName())
+	if err != nil {
+		return err
+	}
+
+	if err := u.DownloadUpdate(downloadURL); err != nil {
+		return err
+	}
+
+	tempFile := filepath.Join(u.wakatimeDir, TempBinaryFile)
+	actual, err := fileSHA256(tempFile)
+	if err != nil {
+		return err
+	}
+
+	if actual != expected {
+		os.Remove(tempFile)
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", assetName(), expected, actual)
+	}
+
+	return nil
+}
+
+// fetchChecksum returns the SHA-256 listed for name in a sha256sum-style
+// checksum file
+func (u *Updater) fetchChecksum(url, name string) (string, error) {
+	resp, err := u.client(30 * time.Second).Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download checksums: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download checksums: status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to download checksums: %w", err)
+	}
+
+	for _, line := range strings.Split(string(body), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			digest := strings.ToLower(fields[0])
+			if _, err := hex.DecodeString(digest); err != nil || len(digest) != sha256.Size*2 {
+				return "", fmt.Errorf("invalid checksum for %s: %q", name, fields[0])
+			}
+			return digest, nil
+		}
+	}
+
+	return "", fmt.Errorf("no checksum listed for %s", name)
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
 // DownloadUpdate downloads the new binary to a temporary location
 func (u *Updater) DownloadUpdate(downloadURL string) error {
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := u.client(30 * time.Second)
 
 	resp, err := client.Get(downloadURL)
 	if err != nil {
@@ -192,11 +406,13 @@ func (u *Updater) DownloadUpdate(downloadURL string) error {
 		return fmt.Errorf("download failed with status %d", resp.StatusCode)
 	}
 
-	tempFile := filepath.Join(u.wakatimeDir, TempBinaryFile)
-	file, err := os.Create(tempFile)
+	// Write beside the final name so a concurrent or interrupted download
+	// never leaves a partial TempBinaryFile
+	file, err := os.CreateTemp(u.wakatimeDir, TempBinaryFile+"-*")
 	if err != nil {
 		return fmt.Errorf("failed to create temp file: %w", err)
 	}
+	defer os.Remove(file.Name())
 	defer file.Close()
 
 	_, err = io.Copy(file, resp.Body)
@@ -204,15 +420,21 @@ func (u *Updater) DownloadUpdate(downloadURL string) error {
 		return fmt.Errorf("failed to write update: %w", err)
 	}
 
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write update: %w", err)
+	}
+
 	// Make executable
-	if err := os.Chmod(tempFile, 0755); err != nil {
+	if err := os.Chmod(file.Name(), 0755); err != nil {
 		return fmt.Errorf("failed to make binary executable: %w", err)
 	}
 
-	return nil
+	return os.Rename(file.Name(), filepath.Join(u.wakatimeDir, TempBinaryFile))
 }
 
-// InstallUpdate atomically replaces the current binary with the new one
+// InstallUpdate atomically replaces the current binary with the new one.
+// The replaced binary is kept, and restored if the new one fails to run
+// `version`, since the shell hooks call this binary after every command.
 func (u *Updater) InstallUpdate(newVersion string) error {
 	tempFile := filepath.Join(u.wakatimeDir, TempBinaryFile)
 
@@ -221,11 +443,26 @@ func (u *Updater) InstallUpdate(newVersion string) error {
 		return fmt.Errorf("temp file not found: %w", err)
 	}
 
+	if err := u.keepPrevious(); err != nil {
+		return fmt.Errorf("failed to keep previous binary: %w", err)
+	}
+
 	// Atomic replace
 	if err := os.Rename(tempFile, u.binaryPath); err != nil {
 		return fmt.Errorf("failed to replace binary: %w", err)
 	}
 
+	if err := SmokeTest(u.binaryPath); err != nil {
+		previous := filepath.Join(u.wakatimeDir, PreviousBinaryFile)
+		if restoreErr := installFile(previous, u.binaryPath); restoreErr != nil {
+			return fmt.Errorf("%s failed its smoke test (%v) and the previous binary could not be restored: %w", newVersion, err, restoreErr)
+		}
+		return fmt.Errorf("%s failed its smoke test, kept %s: %w", newVersion, u.currentVersion, err)
+	}
+
+	os.Remove(filepath.Join(u.wakatimeDir, RollbackFile))
+	os.Remove(filepath.Join(u.wakatimeDir, AvailableUpdateFile))
+
 	// Record update info for notification
 	updateInfo := UpdateInfo{
 		FromVersion: u.currentVersion,
@@ -236,6 +473,113 @@ func (u *Updater) InstallUpdate(newVersion string) error {
 	return u.SaveUpdateInfo(updateInfo)
 }
 
+// SmokeTest runs `<path> version` and checks that it identifies itself
+func SmokeTest(path string) error {
+	_, err := binaryVersion(path)
+	return err
+}
+
+// binaryVersion returns the version printed by `<path> version`
+func binaryVersion(path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), smokeTestTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, "version").Output()
+	if err != nil {
+		return "", fmt.Errorf("`%s version` failed: %w", filepath.Base(path), err)
+	}
+
+	const prefix = "terminal-wakatime version "
+	line := strings.TrimSpace(string(output))
+	if !strings.HasPrefix(line, prefix) {
+		return "", fmt.Errorf("`%s version` printed %q", filepath.Base(path), line)
+	}
+
+	return strings.TrimPrefix(line, prefix), nil
+}
+
+// keepPrevious copies the current binary to PreviousBinaryFile
+func (u *Updater) keepPrevious() error {
+	if _, err := os.Stat(u.binaryPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	return installFile(u.binaryPath, filepath.Join(u.wakatimeDir, PreviousBinaryFile))
+}
+
+// installFile copies src over dst through a temp file in dst's directory,
+// so dst is always either the old or the new binary
+func installFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(out.Name(), 0755); err != nil {
+		return err
+	}
+
+	return os.Rename(out.Name(), dst)
+}
+
+// Rollback swaps the running binary with the one replaced by the last
+// update and returns the version now installed. The version rolled back
+// from is skipped by automatic updates until a newer release appears.
+func (u *Updater) Rollback() (string, error) {
+	previous := filepath.Join(u.wakatimeDir, PreviousBinaryFile)
+	if _, err := os.Stat(previous); err != nil {
+		return "", fmt.Errorf("no previous binary to roll back to")
+	}
+
+	version, err := binaryVersion(previous)
+	if err != nil {
+		return "", fmt.Errorf("previous binary does not run: %w", err)
+	}
+
+	// Copy the current binary aside first; it becomes the new "previous"
+	swap := previous + ".swap"
+	if err := installFile(u.binaryPath, swap); err != nil {
+		return "", fmt.Errorf("failed to keep current binary: %w", err)
+	}
+	defer os.Remove(swap)
+
+	if err := installFile(previous, u.binaryPath); err != nil {
+		return "", fmt.Errorf("failed to restore previous binary: %w", err)
+	}
+	if err := os.Rename(swap, previous); err != nil {
+		return "", err
+	}
+
+	os.WriteFile(filepath.Join(u.wakatimeDir, RollbackFile), []byte(u.currentVersion), 0644)
+	u.ClearPendingUpdateInfo()
+
+	return version, nil
+}
+
+// rolledBackFrom returns the version recorded by the last Rollback
+func (u *Updater) rolledBackFrom() string {
+	data, err := os.ReadFile(filepath.Join(u.wakatimeDir, RollbackFile))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
 // SaveUpdateInfo saves update information for later notification
 func (u *Updater) SaveUpdateInfo(info UpdateInfo) error {
 	updateInfoFile := filepath.Join(u.wakatimeDir, UpdateInfoFile)
@@ -268,6 +612,60 @@ func (u *Updater) GetPendingUpdateInfo() (*UpdateInfo, error) {
 	return &info, nil
 }
 
+// GetAvailableUpdate returns a newer version found in notify-only mode
+// that hasn't been announced yet, or nil
+func (u *Updater) GetAvailableUpdate() (*AvailableUpdate, error) {
+	data, err := os.ReadFile(filepath.Join(u.wakatimeDir, AvailableUpdateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read available update: %w", err)
+	}
+
+	var available AvailableUpdate
+	if err := json.Unmarshal(data, &available); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal available update: %w", err)
+	}
+
+	// Installed some other way in the meantime
+	if newer, err := u.isVersionNewer(available.Version); err != nil || !newer {
+		return nil, nil
+	}
+
+	if available.Notified {
+		return nil, nil
+	}
+
+	return &available, nil
+}
+
+// MarkAvailableUpdateNotified records that the available update was
+// announced, so each version is announced once
+func (u *Updater) MarkAvailableUpdateNotified() error {
+	return u.saveAvailableUpdate(AvailableUpdate{Version: u.availableVersion(), Notified: true})
+}
+
+func (u *Updater) availableVersion() string {
+	data, err := os.ReadFile(filepath.Join(u.wakatimeDir, AvailableUpdateFile))
+	if err != nil {
+		return ""
+	}
+
+	var available AvailableUpdate
+	json.Unmarshal(data, &available)
+	return available.Version
+}
+
+func (u *Updater) saveAvailableUpdate(available AvailableUpdate) error {
+	data, err := json.Marshal(available)
+	if err != nil {
+		return fmt.Errorf("failed to marshal available update: %w", err)
+	}
+
+	return os.WriteFile(filepath.Join(u.wakatimeDir, AvailableUpdateFile), data, 0644)
+}
+
 // ClearPendingUpdateInfo removes the update notification file
 func (u *Updater) ClearPendingUpdateInfo() error {
 	updateInfoFile := filepath.Join(u.wakatimeDir, UpdateInfoFile)
@@ -290,14 +688,17 @@ func (u *Updater) PerformUpdateCheck() {
 		return
 	}
 
-	// Get download URL
-	downloadURL, err := u.GetAssetURL(release)
-	if err != nil {
-		return // Silently fail, don't update check time
+	// Announce rather than install; a version already announced stays quiet
+	if u.notifyOnly {
+		if release.TagName != u.availableVersion() {
+			u.saveAvailableUpdate(AvailableUpdate{Version: release.TagName})
+		}
+		u.UpdateLastCheckTime()
+		return
 	}
 
-	// Download and install update
-	if err := u.DownloadUpdate(downloadURL); err != nil {
+	// Download and verify the update
+	if err := u.DownloadRelease(release); err != nil {
 		return // Silently fail, don't update check time
 	}
 
@@ -305,6 +706,9 @@ func (u *Updater) PerformUpdateCheck() {
 		return // Silently fail, don't update check time
 	}
 
+	// Best effort; the update notification falls back to just the versions
+	u.SaveChangelog(release)
+
 	// Only update the last check time after successful completion
 	u.UpdateLastCheckTime()
 }
@@ -1,12 +1,16 @@
 package updater
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -58,6 +62,11 @@ func TestUpdater_IsVersionNewer(t *testing.T) {
 		{"v0.0.1", "v0.0.1", false},
 		{"0.0.1", "0.0.2", true},  // Without v prefix
 		{"v0.0.1", "0.0.2", true}, // Mixed prefixes
+		{"v0.1.0", "v0.2.0-rc1", true},
+		{"v0.2.0-rc1", "v0.2.0", true},
+		{"v0.2.0-rc1", "v0.2.0-rc2", true},
+		{"v0.2.0", "v0.2.0-rc1", false},
+		{"v0.2.0-rc2", "v0.2.0-rc2", false},
 	}
 
 	for _, tt := range tests {
@@ -102,31 +111,26 @@ func TestUpdater_CheckForUpdate(t *testing.T) {
 	defer server.Close()
 
 	updater := NewUpdater("v0.0.1", "/tmp", "/fake/path")
+	updater.SetReleasesURL(server.URL + "/repos/hackclub/terminal-wakatime/releases/latest")
 
-	// Temporarily replace the ReleasesAPI URL for testing
-	originalAPI := ReleasesAPI
-	defer func() {
-		// This is a bit hacky - in a real implementation we'd make this configurable
-		// ReleasesAPI = originalAPI
-		_ = originalAPI
-	}()
-
-	// We can't easily override the const, so let's test the parsing logic instead
-	// by creating the release directly
-	release := &GitHubRelease{
-		TagName:    "v0.0.2",
-		Name:       "Release v0.0.2",
-		PreRelease: false,
+	release, isNewer, err := updater.CheckForUpdate()
+	if err != nil {
+		t.Fatalf("CheckForUpdate() failed: %v", err)
 	}
 
-	isNewer, err := updater.isVersionNewer(release.TagName)
-	if err != nil {
-		t.Fatalf("Failed to compare versions: %v", err)
+	if release == nil || release.TagName != "v0.0.2" {
+		t.Fatalf("Expected release v0.0.2, got %+v", release)
 	}
 
 	if !isNewer {
 		t.Error("Expected v0.0.2 to be newer than v0.0.1")
 	}
+
+	// An empty URL keeps the configured endpoint
+	updater.SetReleasesURL("")
+	if _, _, err := updater.CheckForUpdate(); err != nil {
+		t.Errorf("Expected empty URL to be ignored, got %v", err)
+	}
 }
 
 func TestUpdater_GetAssetURL(t *testing.T) {
@@ -263,12 +267,21 @@ func TestUpdater_DownloadUpdate(t *testing.T) {
 	}
 }
 
+// fakeBinary is a script answering `version` like terminal-wakatime
+func fakeBinary(version string) string {
+	return "#!/bin/sh\necho terminal-wakatime version " + version + "\n"
+}
+
 func TestUpdater_IntegrationFlow(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binaries are shell scripts")
+	}
+
 	tempDir := t.TempDir()
 
 	// Create a fake current binary
 	currentBinary := filepath.Join(tempDir, "terminal-wakatime")
-	if err := os.WriteFile(currentBinary, []byte("old binary"), 0755); err != nil {
+	if err := os.WriteFile(currentBinary, []byte(fakeBinary("v0.0.1")), 0755); err != nil {
 		t.Fatalf("Failed to create current binary: %v", err)
 	}
 
@@ -276,7 +289,7 @@ func TestUpdater_IntegrationFlow(t *testing.T) {
 
 	// Create a fake new binary as temp file
 	tempBinary := filepath.Join(tempDir, TempBinaryFile)
-	if err := os.WriteFile(tempBinary, []byte("new binary"), 0755); err != nil {
+	if err := os.WriteFile(tempBinary, []byte(fakeBinary("v0.0.2")), 0755); err != nil {
 		t.Fatalf("Failed to create temp binary: %v", err)
 	}
 
@@ -291,8 +304,14 @@ func TestUpdater_IntegrationFlow(t *testing.T) {
 		t.Fatalf("Failed to read updated binary: %v", err)
 	}
 
-	if string(content) != "new binary" {
-		t.Errorf("Binary content mismatch: got %s, want %s", string(content), "new binary")
+	if string(content) != fakeBinary("v0.0.2") {
+		t.Errorf("Binary content mismatch: got %s, want %s", string(content), fakeBinary("v0.0.2"))
+	}
+
+	// The replaced binary is kept for rollback
+	previous, err := os.ReadFile(filepath.Join(tempDir, PreviousBinaryFile))
+	if err != nil || string(previous) != fakeBinary("v0.0.1") {
+		t.Errorf("Expected the previous binary to be kept, got %q (err %v)", previous, err)
 	}
 
 	// Verify temp file is removed
@@ -314,3 +333,271 @@ func TestUpdater_IntegrationFlow(t *testing.T) {
 			updateInfo.FromVersion, updateInfo.ToVersion)
 	}
 }
+
+func TestUpdater_InstallUpdateRestoresOnFailedSmokeTest(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binaries are shell scripts")
+	}
+
+	tempDir := t.TempDir()
+	currentBinary := filepath.Join(tempDir, "terminal-wakatime")
+	if err := os.WriteFile(currentBinary, []byte(fakeBinary("v0.0.1")), 0755); err != nil {
+		t.Fatalf("Failed to create current binary: %v", err)
+	}
+
+	// A release that crashes on start
+	tempBinary := filepath.Join(tempDir, TempBinaryFile)
+	if err := os.WriteFile(tempBinary, []byte("#!/bin/sh\nexit 2\n"), 0755); err != nil {
+		t.Fatalf("Failed to create temp binary: %v", err)
+	}
+
+	updater := NewUpdater("v0.0.1", tempDir, currentBinary)
+	err := updater.InstallUpdate("v0.0.2")
+	if err == nil || !strings.Contains(err.Error(), "smoke test") {
+		t.Fatalf("Expected the smoke test to fail, got %v", err)
+	}
+
+	content, _ := os.ReadFile(currentBinary)
+	if string(content) != fakeBinary("v0.0.1") {
+		t.Errorf("Expected the previous binary to be restored, got %q", content)
+	}
+	if info, _ := updater.GetPendingUpdateInfo(); info != nil {
+		t.Errorf("Expected no update notification, got %+v", info)
+	}
+}
+
+// newReleaseServer serves a release with a binary for this platform and,
+// if checksums is set, a checksum file
+func newReleaseServer(t *testing.T, binary string, checksums func(digest string) string) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest":
+			release := map[string]interface{}{
+				"tag_name": "v0.0.2",
+				"assets": []map[string]string{
+					{"name": assetName(), "browser_download_url": server.URL + "/binary"},
+				},
+			}
+			if checksums != nil {
+				release["assets"] = append(release["assets"].([]map[string]string),
+					map[string]string{"name": ChecksumsAsset, "browser_download_url": server.URL + "/checksums"})
+			}
+			json.NewEncoder(w).Encode(release)
+		case "/binary":
+			w.Write([]byte(binary))
+		case "/checksums":
+			sum := sha256.Sum256([]byte(binary))
+			w.Write([]byte(checksums(hex.EncodeToString(sum[:]))))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestUpdater_DownloadRelease(t *testing.T) {
+	tests := []struct {
+		name      string
+		checksums func(digest string) string
+		wantErr   string
+	}{
+		{
+			name:      "verified",
+			checksums: func(digest string) string { return digest + "  " + assetName() + "\n" },
+		},
+		{
+			name:      "checksum mismatch",
+			checksums: func(digest string) string { return strings.Repeat("0", 64) + "  " + assetName() + "\n" },
+			wantErr:   "checksum mismatch",
+		},
+		{
+			name:      "asset not listed",
+			checksums: func(digest string) string { return digest + "  terminal-wakatime-plan9-386\n" },
+			wantErr:   "no checksum listed",
+		},
+		{
+			name:    "no checksum file",
+			wantErr: "refusing unverified update",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			server := newReleaseServer(t, fakeBinary("v0.0.2"), tt.checksums)
+
+			updater := NewUpdater("v0.0.1", tempDir, filepath.Join(tempDir, "terminal-wakatime"))
+			updater.SetReleasesURL(server.URL + "/latest")
+
+			release, isNewer, err := updater.CheckForUpdate()
+			if err != nil || !isNewer {
+				t.Fatalf("CheckForUpdate() = %v, %v", isNewer, err)
+			}
+
+			err = updater.DownloadRelease(release)
+			tempFile := filepath.Join(tempDir, TempBinaryFile)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				if _, err := os.Stat(tempFile); !os.IsNotExist(err) {
+					t.Error("Expected no downloaded binary to be left behind")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("DownloadRelease() failed: %v", err)
+			}
+			if content, _ := os.ReadFile(tempFile); string(content) != fakeBinary("v0.0.2") {
+				t.Errorf("Unexpected download %q", content)
+			}
+		})
+	}
+}
+
+func TestUpdater_Rollback(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binaries are shell scripts")
+	}
+
+	tempDir := t.TempDir()
+	currentBinary := filepath.Join(tempDir, "terminal-wakatime")
+	os.WriteFile(currentBinary, []byte(fakeBinary("v0.0.1")), 0755)
+
+	updater := NewUpdater("v0.0.1", tempDir, currentBinary)
+	if _, err := updater.Rollback(); err == nil {
+		t.Error("Expected rollback without a previous binary to fail")
+	}
+
+	os.WriteFile(filepath.Join(tempDir, TempBinaryFile), []byte(fakeBinary("v0.0.2")), 0755)
+	if err := updater.InstallUpdate("v0.0.2"); err != nil {
+		t.Fatalf("InstallUpdate() failed: %v", err)
+	}
+
+	// The new binary is now the one running
+	updater = NewUpdater("v0.0.2", tempDir, currentBinary)
+	version, err := updater.Rollback()
+	if err != nil || version != "v0.0.1" {
+		t.Fatalf("Expected rollback to v0.0.1, got %q (err %v)", version, err)
+	}
+
+	if content, _ := os.ReadFile(currentBinary); string(content) != fakeBinary("v0.0.1") {
+		t.Errorf("Expected v0.0.1 to be restored, got %q", content)
+	}
+	if content, _ := os.ReadFile(filepath.Join(tempDir, PreviousBinaryFile)); string(content) != fakeBinary("v0.0.2") {
+		t.Errorf("Expected v0.0.2 to be kept as previous, got %q", content)
+	}
+	if info, _ := updater.GetPendingUpdateInfo(); info != nil {
+		t.Errorf("Expected the update notification to be cleared, got %+v", info)
+	}
+
+	// Automatic updates don't reinstall the version rolled back from
+	server := newReleaseServer(t, fakeBinary("v0.0.2"), nil)
+	updater = NewUpdater("v0.0.1", tempDir, currentBinary)
+	updater.SetReleasesURL(server.URL + "/latest")
+
+	if _, isNewer, err := updater.CheckForUpdate(); err != nil || isNewer {
+		t.Errorf("Expected v0.0.2 to be skipped after rollback, got newer=%t err=%v", isNewer, err)
+	}
+}
+
+// newChannelServer serves a release list whose newest entry is a
+// pre-release, like GitHub's releases endpoint
+func newChannelServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/releases/latest":
+			json.NewEncoder(w).Encode(GitHubRelease{TagName: "v0.2.0"})
+		case "/releases":
+			json.NewEncoder(w).Encode([]GitHubRelease{
+				{TagName: "v0.4.0", Draft: true},
+				{TagName: "v0.3.0-rc1", PreRelease: true},
+				{TagName: "v0.2.0"},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestUpdater_Channels(t *testing.T) {
+	server := newChannelServer(t)
+
+	tests := []struct {
+		name        string
+		prereleases bool
+		expected    string
+	}{
+		{"stable", false, "v0.2.0"},
+		{"prerelease", true, "v0.3.0-rc1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updater := NewUpdater("v0.1.0", t.TempDir(), "/fake/path")
+			updater.SetReleasesURL(server.URL + "/releases/latest")
+			updater.SetIncludePrereleases(tt.prereleases)
+
+			release, isNewer, err := updater.CheckForUpdate()
+			if err != nil {
+				t.Fatalf("CheckForUpdate() failed: %v", err)
+			}
+			if release == nil || release.TagName != tt.expected || !isNewer {
+				t.Errorf("Expected newer %s, got %+v (newer %t)", tt.expected, release, isNewer)
+			}
+		})
+	}
+}
+
+func TestUpdater_NotifyOnly(t *testing.T) {
+	tempDir := t.TempDir()
+	server := newChannelServer(t)
+
+	currentBinary := filepath.Join(tempDir, "terminal-wakatime")
+	os.WriteFile(currentBinary, []byte("current"), 0755)
+
+	updater := NewUpdater("v0.1.0", tempDir, currentBinary)
+	updater.SetReleasesURL(server.URL + "/releases/latest")
+	updater.SetNotifyOnly(true)
+	updater.PerformUpdateCheck()
+
+	if content, _ := os.ReadFile(currentBinary); string(content) != "current" {
+		t.Errorf("Expected the binary to be left alone, got %q", content)
+	}
+	if updater.ShouldCheckForUpdate() {
+		t.Error("Expected the check time to be recorded")
+	}
+
+	available, err := updater.GetAvailableUpdate()
+	if err != nil || available == nil || available.Version != "v0.2.0" {
+		t.Fatalf("Expected v0.2.0 to be available, got %+v (err %v)", available, err)
+	}
+
+	// Each version is announced once, even if found again
+	if err := updater.MarkAvailableUpdateNotified(); err != nil {
+		t.Fatalf("MarkAvailableUpdateNotified() failed: %v", err)
+	}
+	updater.PerformUpdateCheck()
+	if available, _ := updater.GetAvailableUpdate(); available != nil {
+		t.Errorf("Expected no second announcement, got %+v", available)
+	}
+
+	// Nor once that version is running
+	updater.saveAvailableUpdate(AvailableUpdate{Version: "v0.2.0"})
+	updater.currentVersion = "v0.2.0"
+	if available, _ := updater.GetAvailableUpdate(); available != nil {
+		t.Errorf("Expected no announcement for the running version, got %+v", available)
+	}
+}
@@ -4,14 +4,18 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,16 +27,36 @@ const (
 	WakaTimeCLIRepo     = "wakatime/wakatime-cli"
 	GitHubReleasesURL   = "https://api.github.com/repos/wakatime/wakatime-cli/releases/latest"
 	CheckUpdateInterval = 24 * time.Hour
+
+	// File in the WakaTime directory recording the last wakatime-cli update check
+	LastUpdateCheckFile = "last_update_check"
+
+	// Release asset listing the SHA-256 of every other asset
+	ChecksumsAsset = "checksums_sha256.txt"
+
+	// Suffix of the binary kept from before the last install, for Rollback
+	PreviousBinarySuffix = ".previous"
+
+	// SharedBinaryName is the link in the WakaTime directory that editor
+	// plugins use to find the wakatime-cli they share
+	SharedBinaryName = "wakatime-cli"
+
+	// AdoptedBinaryFile records the wakatime-cli the binary path links to
+	// when an existing install was adopted instead of downloading one
+	AdoptedBinaryFile = "wakatime-cli_adopted"
 )
 
 type CLI struct {
-	config  *config.Config
-	binPath string
+	config      *config.Config
+	binPath     string
+	releasesURL string
 }
 
 type GitHubRelease struct {
-	TagName string  `json:"tag_name"`
-	Assets  []Asset `json:"assets"`
+	TagName     string    `json:"tag_name"`
+	PreRelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+	Assets      []Asset   `json:"assets"`
 }
 
 type Asset struct {
@@ -48,10 +72,86 @@ func NewCLI(cfg *config.Config) *CLI {
 
 	binPath := filepath.Join(cfg.WakaTimeDir(), binName)
 
+	releasesURL := cfg.CLIReleasesURL
+	if releasesURL == "" {
+		releasesURL = GitHubReleasesURL
+	}
+
 	return &CLI{
-		config:  cfg,
-		binPath: binPath,
+		config:      cfg,
+		binPath:     binPath,
+		releasesURL: releasesURL,
+	}
+}
+
+// SharedBinaryPath returns the wakatime-cli link shared by WakaTime plugins
+func (c *CLI) SharedBinaryPath() string {
+	name := SharedBinaryName
+	if runtime.GOOS == "windows" {
+		name += ".exe"
 	}
+
+	return filepath.Join(c.config.WakaTimeDir(), name)
+}
+
+// findExisting looks for a working wakatime-cli behind the shared link in
+// the WakaTime directory, then on PATH
+func (c *CLI) findExisting() string {
+	var candidates []string
+	if target, err := filepath.EvalSymlinks(c.SharedBinaryPath()); err == nil {
+		candidates = append(candidates, target)
+	}
+	if found, err := exec.LookPath(SharedBinaryName); err == nil {
+		candidates = append(candidates, found)
+	}
+
+	for _, candidate := range candidates {
+		path, err := filepath.Abs(candidate)
+		if err != nil || path == c.binPath {
+			continue
+		}
+		if exec.Command(path, "--version").Run() == nil {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// linkShared points the shared wakatime-cli link at our binary so editor
+// plugins pick up the install. A regular file there belongs to someone else
+// and is left alone.
+func (c *CLI) linkShared() {
+	shared := c.SharedBinaryPath()
+	if info, err := os.Lstat(shared); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		return
+	}
+
+	if target, err := os.Readlink(shared); err == nil && target == c.binPath {
+		return
+	}
+
+	c.linkBinary(c.binPath, shared)
+}
+
+// linkBinary atomically makes link a symlink to target, building it beside
+// the final path and renaming it into place
+func (c *CLI) linkBinary(target, link string) error {
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		return fmt.Errorf("failed to create wakatime directory: %w", err)
+	}
+
+	tmpLink := link + ".link"
+	os.Remove(tmpLink)
+	if err := os.Symlink(target, tmpLink); err != nil {
+		return fmt.Errorf("failed to link %s: %w", target, err)
+	}
+	if err := os.Rename(tmpLink, link); err != nil {
+		os.Remove(tmpLink)
+		return err
+	}
+
+	return nil
 }
 
 func (c *CLI) EnsureInstalled() error {
@@ -59,7 +159,7 @@ func (c *CLI) EnsureInstalled() error {
 		return c.checkForUpdates()
 	}
 
-	return c.install()
+	return c.installOnce()
 }
 
 func (c *CLI) IsInstalled() bool {
@@ -81,25 +181,76 @@ func (c *CLI) install() error {
 		return fmt.Errorf("failed to create wakatime directory: %w", err)
 	}
 
-	release, err := c.getLatestRelease()
+	// Reuse a wakatime-cli installed by another plugin or a package manager
+	// rather than downloading a second copy
+	if _, err := os.Lstat(c.binPath); os.IsNotExist(err) {
+		if existing := c.findExisting(); existing != "" {
+			return c.adopt(existing)
+		}
+	}
+
+	return c.installPinned()
+}
+
+// installPinned downloads the pinned release, or the latest one
+func (c *CLI) installPinned() error {
+	release, err := c.getRelease(c.PinnedVersion())
 	if err != nil {
-		return fmt.Errorf("failed to get latest release: %w", err)
+		return fmt.Errorf("failed to get release: %w", err)
 	}
 
+	return c.installRelease(release)
+}
+
+// Reinstall replaces the binary with a fresh download of the pinned or
+// latest release. Unlike EnsureInstalled it never adopts a wakatime-cli
+// found elsewhere, which may be the broken one being replaced.
+func (c *CLI) Reinstall() error {
+	return c.withInstallLock(installLockWait, func() error {
+		if err := os.Remove(c.binPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		os.Remove(c.adoptedRecordPath())
+
+		return c.recordInstall(c.installPinned())
+	})
+}
+
+// InstallVersion installs the given release tag, or the latest release if
+// version is empty or "latest". The replaced binary is kept for Rollback.
+func (c *CLI) InstallVersion(version string) error {
+	release, err := c.getRelease(NormalizeVersion(version))
+	if err != nil {
+		return fmt.Errorf("failed to get release: %w", err)
+	}
+
+	return c.withInstallLock(installLockWait, func() error {
+		// Installing a release replaces a previously adopted system binary
+		if c.IsSystemBinary() {
+			if err := os.Remove(c.binPath); err != nil {
+				return err
+			}
+		}
+
+		if err := c.installRelease(release); err != nil {
+			return err
+		}
+
+		c.ResetInstallBackoff()
+		return nil
+	})
+}
+
+func (c *CLI) installRelease(release *GitHubRelease) error {
 	asset, err := c.findAssetForPlatform(release)
 	if err != nil {
 		return fmt.Errorf("failed to find asset for platform: %w", err)
 	}
 
-	if err := c.downloadAndExtract(asset); err != nil {
+	if err := c.downloadAndExtract(release, asset); err != nil {
 		return fmt.Errorf("failed to download and extract: %w", err)
 	}
 
-	// Make binary executable
-	if err := os.Chmod(c.binPath, 0755); err != nil {
-		return fmt.Errorf("failed to make binary executable: %w", err)
-	}
-
 	// Save installation timestamp
 	c.saveLastUpdateCheck()
 
@@ -107,12 +258,32 @@ func (c *CLI) install() error {
 }
 
 func (c *CLI) checkForUpdates() error {
+	// A binary adopted with UseSystem is updated by whoever installed it
+	if c.IsSystemBinary() {
+		return nil
+	}
+
+	// A changed pin applies right away instead of waiting for the next check
+	pinned := c.PinnedVersion()
+	if pinned != "" {
+		if current, err := c.getCurrentVersion(); err == nil && current != pinned {
+			err := c.installGuarded(0, func() bool {
+				current, err := c.getCurrentVersion()
+				return err == nil && current != pinned
+			})
+			if err == errInstallLocked {
+				return nil
+			}
+			return err
+		}
+	}
+
 	lastCheck := c.getLastUpdateCheck()
 	if time.Since(lastCheck) < CheckUpdateInterval {
 		return nil
 	}
 
-	release, err := c.getLatestRelease()
+	release, err := c.getRelease(pinned)
 	if err != nil {
 		return nil // Silently fail on update checks
 	}
@@ -123,13 +294,81 @@ func (c *CLI) checkForUpdates() error {
 	}
 
 	if currentVersion != release.TagName {
-		c.install() // Silently update
+		// Silently update, unless another process is already doing it
+		c.installGuarded(0, func() bool {
+			current, err := c.getCurrentVersion()
+			return err == nil && current != release.TagName
+		})
 	}
 
 	c.saveLastUpdateCheck()
 	return nil
 }
 
+// PinnedVersion returns the wakatime_cli_version setting as a release tag,
+// or "" when wakatime-cli follows the latest release
+func (c *CLI) PinnedVersion() string {
+	return NormalizeVersion(c.config.WakaTimeCLIVersion)
+}
+
+// NormalizeVersion turns "1.98.0" into the tag "v1.98.0"; "" and "latest"
+// both mean the latest release and normalize to ""
+func NormalizeVersion(version string) string {
+	version = strings.TrimSpace(version)
+	if version == "" || strings.EqualFold(version, "latest") {
+		return ""
+	}
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return version
+}
+
+// Version returns the version reported by the installed wakatime-cli
+func (c *CLI) Version() (string, error) {
+	return c.getCurrentVersion()
+}
+
+// OfflineCount returns the number of heartbeats waiting in wakatime-cli's
+// offline queue
+func (c *CLI) OfflineCount() (int, error) {
+	cmd := exec.Command(c.binPath, "--offline-count")
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read offline queue: %w", err)
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected offline count output: %q", strings.TrimSpace(string(output)))
+	}
+
+	return count, nil
+}
+
+// Today returns today's coding time as reported by the API, e.g.
+// "3 hrs 12 mins"
+func (c *CLI) Today() (string, error) {
+	cmd := exec.Command(c.binPath, "--today")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch today's time: %w", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// TodayJSON returns the raw output of `--today --output json`
+func (c *CLI) TodayJSON() ([]byte, error) {
+	cmd := exec.Command(c.binPath, "--today", "--output", "json")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch today's summary: %w", err)
+	}
+
+	return output, nil
+}
+
 func (c *CLI) getCurrentVersion() (string, error) {
 	cmd := exec.Command(c.binPath, "--version")
 	output, err := cmd.Output()
@@ -137,81 +376,426 @@ func (c *CLI) getCurrentVersion() (string, error) {
 		return "", err
 	}
 
-	// Parse version from output like "wakatime-cli v1.73.0"
-	parts := strings.Fields(string(output))
-	if len(parts) >= 2 {
-		return parts[1], nil
+	// Parse version from output like "v1.73.0" or "wakatime-cli v1.73.0"
+	lines := strings.SplitN(strings.TrimSpace(string(output)), "\n", 2)
+	parts := strings.Fields(lines[0])
+	if len(parts) >= 1 {
+		return parts[len(parts)-1], nil
 	}
 
 	return "", fmt.Errorf("unable to parse version")
 }
 
-func (c *CLI) getLatestRelease() (*GitHubRelease, error) {
-	resp, err := http.Get(GitHubReleasesURL)
-	if err != nil {
-		return nil, err
+// releasesBase returns the releases collection URL, e.g.
+// https://api.github.com/repos/wakatime/wakatime-cli/releases
+func (c *CLI) releasesBase() string {
+	releasesURL := c.releasesURL
+	if releasesURL == "" {
+		releasesURL = GitHubReleasesURL
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch releases: %s", resp.Status)
+	return strings.TrimSuffix(strings.TrimRight(releasesURL, "/"), "/latest")
+}
+
+// getRelease fetches the release tagged version, or the latest release if
+// version is empty
+func (c *CLI) getRelease(version string) (*GitHubRelease, error) {
+	endpoint := c.releasesBase() + "/latest"
+	if version != "" {
+		endpoint = c.releasesBase() + "/tags/" + url.PathEscape(version)
 	}
 
 	var release GitHubRelease
-	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+	if err := c.getJSON(endpoint, &release); err != nil {
+		if version != "" {
+			return nil, fmt.Errorf("release %s: %w", version, err)
+		}
 		return nil, err
 	}
 
 	return &release, nil
 }
 
+// ListReleases returns up to limit releases, newest first
+func (c *CLI) ListReleases(limit int) ([]GitHubRelease, error) {
+	var releases []GitHubRelease
+	if err := c.getJSON(fmt.Sprintf("%s?per_page=%d", c.releasesBase(), limit), &releases); err != nil {
+		return nil, err
+	}
+
+	if len(releases) > limit {
+		releases = releases[:limit]
+	}
+
+	return releases, nil
+}
+
+func (c *CLI) getJSON(endpoint string, v interface{}) error {
+	client, err := c.config.HTTPClient(30 * time.Second)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch releases: %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
 func (c *CLI) findAssetForPlatform(release *GitHubRelease) (*Asset, error) {
 	platform := fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH)
 
-	for _, asset := range release.Assets {
-		if strings.Contains(asset.Name, platform) {
-			return &asset, nil
+	// Match the exact archive name so similarly named assets (checksums,
+	// signatures, other architectures) are never picked up
+	for _, ext := range []string{".zip", ".tar.gz"} {
+		name := "wakatime-cli-" + platform + ext
+		for _, asset := range release.Assets {
+			if asset.Name == name {
+				return &asset, nil
+			}
 		}
 	}
 
 	return nil, fmt.Errorf("no asset found for platform %s", platform)
 }
 
-func (c *CLI) downloadAndExtract(asset *Asset) error {
-	resp, err := http.Get(asset.BrowserDownloadURL)
+// downloadAndExtract downloads asset, verifies it against the release's
+// checksum file and atomically replaces the binary with the one it contains
+func (c *CLI) downloadAndExtract(release *GitHubRelease, asset *Asset) error {
+	var checksums *Asset
+	for i := range release.Assets {
+		if release.Assets[i].Name == ChecksumsAsset {
+			checksums = &release.Assets[i]
+			break
+		}
+	}
+	if checksums == nil {
+		return fmt.Errorf("release %s has no %s, refusing to install unverified binary", release.TagName, ChecksumsAsset)
+	}
+
+	client, err := c.config.HTTPClient(5 * time.Minute)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download asset: %s", resp.Status)
+	expected, err := fetchChecksum(client, checksums.BrowserDownloadURL, asset.Name)
+	if err != nil {
+		return err
 	}
 
-	// Create temporary file
-	tempFile, err := os.CreateTemp("", "wakatime-cli-*")
+	archive, err := os.CreateTemp(filepath.Dir(c.binPath), ".wakatime-cli-download-*")
 	if err != nil {
 		return err
 	}
-	defer os.Remove(tempFile.Name())
-	defer tempFile.Close()
+	defer os.Remove(archive.Name())
+	defer archive.Close()
 
-	// Download to temp file
-	if _, err := io.Copy(tempFile, resp.Body); err != nil {
+	actual, err := downloadWithChecksum(client, asset.BrowserDownloadURL, archive)
+	if err != nil {
 		return err
 	}
 
-	// Extract based on file extension
-	if strings.HasSuffix(asset.Name, ".tar.gz") {
-		return c.extractTarGz(tempFile.Name())
-	} else if strings.HasSuffix(asset.Name, ".zip") {
-		return c.extractZip(tempFile.Name())
+	if actual != expected {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", asset.Name, expected, actual)
 	}
 
-	return fmt.Errorf("unsupported archive format")
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	return c.installArchive(archive.Name(), asset.Name)
 }
 
-func (c *CLI) extractTarGz(archivePath string) error {
+// InstallFromArchive installs wakatime-cli from a release archive that was
+// downloaded manually, e.g. for air-gapped machines. The archive is checked
+// against expectedSHA256 if given, otherwise against a checksums_sha256.txt
+// next to it. It reports whether the archive could be verified.
+func (c *CLI) InstallFromArchive(archivePath, expectedSHA256 string) (bool, error) {
+	name := filepath.Base(archivePath)
+
+	expected := strings.ToLower(strings.TrimSpace(expectedSHA256))
+	if expected == "" {
+		if content, err := os.ReadFile(filepath.Join(filepath.Dir(archivePath), ChecksumsAsset)); err == nil {
+			if expected, err = parseChecksum(string(content), name); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if expected != "" {
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return false, err
+		}
+		hash := sha256.New()
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return false, err
+		}
+
+		if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+			return false, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, expected, actual)
+		}
+	}
+
+	err := c.withInstallLock(installLockWait, func() error {
+		// Replace a previously adopted system binary rather than writing through it
+		if c.IsSystemBinary() {
+			if err := os.Remove(c.binPath); err != nil {
+				return err
+			}
+		}
+
+		if err := c.installArchive(archivePath, name); err != nil {
+			return err
+		}
+
+		c.ResetInstallBackoff()
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	c.saveLastUpdateCheck()
+	return expected != "", nil
+}
+
+// UseSystem points the managed binary path at an existing wakatime-cli,
+// such as one installed by a package manager. If path is empty, PATH is
+// searched. Automatic updates are skipped for adopted binaries.
+func (c *CLI) UseSystem(path string) (string, error) {
+	if path == "" {
+		found, err := exec.LookPath("wakatime-cli")
+		if err != nil {
+			return "", fmt.Errorf("wakatime-cli not found on PATH")
+		}
+		path = found
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	if err := exec.Command(path, "--version").Run(); err != nil {
+		return "", fmt.Errorf("%s does not run: %w", path, err)
+	}
+
+	if err := c.adopt(path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// adopt links the binary path to the wakatime-cli at path and records it,
+// so updates are left to whoever installed that one
+func (c *CLI) adopt(path string) error {
+	if err := c.linkBinary(path, c.binPath); err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.adoptedRecordPath(), []byte(path), 0644)
+}
+
+func (c *CLI) adoptedRecordPath() string {
+	return filepath.Join(c.config.WakaTimeDir(), AdoptedBinaryFile)
+}
+
+// IsSystemBinary reports whether the binary path is a link to a
+// wakatime-cli adopted with UseSystem or reused on install. A link that
+// wasn't recorded as adopted is treated like our own binary.
+func (c *CLI) IsSystemBinary() bool {
+	adopted, err := os.ReadFile(c.adoptedRecordPath())
+	if err != nil {
+		return false
+	}
+
+	target, err := os.Readlink(c.binPath)
+	return err == nil && target == string(adopted)
+}
+
+// installArchive extracts the binary from archivePath next to the final
+// path and renames it into place, so a failure never leaves a partial binary
+func (c *CLI) installArchive(archivePath, archiveName string) error {
+	binary, err := os.CreateTemp(filepath.Dir(c.binPath), ".wakatime-cli-new-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(binary.Name())
+	defer binary.Close()
+
+	switch {
+	case strings.HasSuffix(archiveName, ".tar.gz"):
+		err = extractTarGz(archivePath, binary)
+	case strings.HasSuffix(archiveName, ".zip"):
+		err = extractZip(archivePath, binary)
+	default:
+		err = fmt.Errorf("unsupported archive format")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := binary.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(binary.Name(), 0755); err != nil {
+		return fmt.Errorf("failed to make binary executable: %w", err)
+	}
+
+	if err := c.keepPrevious(); err != nil {
+		return fmt.Errorf("failed to keep previous binary: %w", err)
+	}
+
+	if err := os.Rename(binary.Name(), c.binPath); err != nil {
+		return err
+	}
+	os.Remove(c.adoptedRecordPath())
+
+	c.linkShared()
+	return nil
+}
+
+// PreviousBinaryPath is where the binary replaced by the last install is kept
+func (c *CLI) PreviousBinaryPath() string {
+	return c.binPath + PreviousBinarySuffix
+}
+
+// keepPrevious copies the current binary aside before it is replaced.
+// Adopted system binaries are not ours to keep.
+func (c *CLI) keepPrevious() error {
+	if c.IsSystemBinary() {
+		return nil
+	}
+
+	current, err := os.Open(c.binPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer current.Close()
+
+	previous, err := os.CreateTemp(filepath.Dir(c.binPath), ".wakatime-cli-previous-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(previous.Name())
+	defer previous.Close()
+
+	if _, err := io.Copy(previous, current); err != nil {
+		return err
+	}
+	if err := previous.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(previous.Name(), 0755); err != nil {
+		return err
+	}
+
+	return os.Rename(previous.Name(), c.PreviousBinaryPath())
+}
+
+// Rollback swaps the installed binary with the one it replaced and returns
+// the version now installed. Rolling back twice restores the original.
+func (c *CLI) Rollback() (string, error) {
+	if c.IsSystemBinary() {
+		return "", fmt.Errorf("wakatime-cli is a system binary; roll back with its package manager")
+	}
+
+	previous := c.PreviousBinaryPath()
+	if _, err := os.Stat(previous); err != nil {
+		return "", fmt.Errorf("no previous wakatime-cli to roll back to")
+	}
+
+	swap := c.binPath + ".rollback"
+	if err := os.Rename(c.binPath, swap); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if err := os.Rename(previous, c.binPath); err != nil {
+		os.Rename(swap, c.binPath)
+		return "", err
+	}
+	os.Rename(swap, previous)
+
+	return c.getCurrentVersion()
+}
+
+// fetchChecksum returns the SHA-256 listed for name in a checksum file with
+// lines of the form "<hex digest>  <file name>"
+func fetchChecksum(client *http.Client, url, name string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download checksums: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download checksums: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to download checksums: %w", err)
+	}
+
+	return parseChecksum(string(body), name)
+}
+
+func parseChecksum(content, name string) (string, error) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		// sha256sum marks binary mode with a leading "*"
+		if strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+
+		digest := strings.ToLower(fields[0])
+		if _, err := hex.DecodeString(digest); err != nil || len(digest) != sha256.Size*2 {
+			return "", fmt.Errorf("invalid checksum for %s: %q", name, fields[0])
+		}
+		return digest, nil
+	}
+
+	return "", fmt.Errorf("no checksum listed for %s", name)
+}
+
+// downloadWithChecksum writes the body of url to w and returns its SHA-256
+func downloadWithChecksum(client *http.Client, url string, w io.Writer) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download asset: %s", resp.Status)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), resp.Body); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func extractTarGz(archivePath string, out io.Writer) error {
 	file, err := os.Open(archivePath)
 	if err != nil {
 		return err
@@ -236,13 +820,7 @@ func (c *CLI) extractTarGz(archivePath string) error {
 		}
 
 		if strings.Contains(header.Name, "wakatime-cli") && header.Typeflag == tar.TypeReg {
-			outFile, err := os.Create(c.binPath)
-			if err != nil {
-				return err
-			}
-			defer outFile.Close()
-
-			_, err = io.Copy(outFile, tr)
+			_, err = io.Copy(out, tr)
 			return err
 		}
 	}
@@ -250,7 +828,7 @@ func (c *CLI) extractTarGz(archivePath string) error {
 	return fmt.Errorf("wakatime-cli binary not found in archive")
 }
 
-func (c *CLI) extractZip(archivePath string) error {
+func extractZip(archivePath string, out io.Writer) error {
 	r, err := zip.OpenReader(archivePath)
 	if err != nil {
 		return err
@@ -265,13 +843,7 @@ func (c *CLI) extractZip(archivePath string) error {
 			}
 			defer rc.Close()
 
-			outFile, err := os.Create(c.binPath)
-			if err != nil {
-				return err
-			}
-			defer outFile.Close()
-
-			_, err = io.Copy(outFile, rc)
+			_, err = io.Copy(out, rc)
 			return err
 		}
 	}
@@ -280,7 +852,7 @@ func (c *CLI) extractZip(archivePath string) error {
 }
 
 func (c *CLI) getLastUpdateCheck() time.Time {
-	timestampFile := filepath.Join(c.config.WakaTimeDir(), "last_update_check")
+	timestampFile := filepath.Join(c.config.WakaTimeDir(), LastUpdateCheckFile)
 	data, err := os.ReadFile(timestampFile)
 	if err != nil {
 		return time.Time{}
@@ -295,12 +867,12 @@ func (c *CLI) getLastUpdateCheck() time.Time {
 }
 
 func (c *CLI) saveLastUpdateCheck() {
-	timestampFile := filepath.Join(c.config.WakaTimeDir(), "last_update_check")
+	timestampFile := filepath.Join(c.config.WakaTimeDir(), LastUpdateCheckFile)
 	timestamp := time.Now().Format(time.RFC3339)
 	os.WriteFile(timestampFile, []byte(timestamp), 0644)
 }
 
-func (c *CLI) SendHeartbeat(entity, entityType, category, language, project, branch string, isWrite bool, lines, lineNo, cursorPos, lineAdditions, lineDeletions *int) error {
+func (c *CLI) SendHeartbeat(entity, entityType, category, language, project, branch, hostname string, at time.Time, isWrite, isUnsavedEntity bool, lines, lineNo, cursorPos, lineAdditions, lineDeletions *int) error {
 	// Format plugin string according to WakaTime spec: "shell/version terminal-wakatime/version"
 	pluginString := shell.FormatPluginString(config.PluginName, config.PluginVersion)
 
@@ -329,10 +901,26 @@ func (c *CLI) SendHeartbeat(entity, entityType, category, language, project, bra
 		args = append(args, "--alternate-branch", branch)
 	}
 
+	if hostname != "" {
+		args = append(args, "--hostname", hostname)
+	}
+
+	// Heartbeats sent later than they happened, like forwarded ones, keep
+	// their time; a zero time means now
+	if !at.IsZero() {
+		args = append(args, "--time", strconv.FormatFloat(float64(at.UnixNano())/float64(time.Second), 'f', 3, 64))
+	}
+
 	if isWrite {
 		args = append(args, "--write")
 	}
 
+	// Files that don't exist here, like a remote machine's, are otherwise
+	// skipped by wakatime-cli
+	if isUnsavedEntity {
+		args = append(args, "--is-unsaved-entity")
+	}
+
 	if lines != nil {
 		args = append(args, "--lines-in-file", fmt.Sprintf("%d", *lines))
 	}
@@ -365,7 +953,7 @@ func (c *CLI) SendHeartbeat(entity, entityType, category, language, project, bra
 	}
 
 	err := cmd.Run()
-	
+
 	// Handle known non-fatal wakatime-cli exit codes
 	if err != nil {
 		if exitError, ok := err.(*exec.ExitError); ok {
@@ -377,7 +965,7 @@ func (c *CLI) SendHeartbeat(entity, entityType, category, language, project, bra
 			}
 		}
 	}
-	
+
 	return err
 }
 
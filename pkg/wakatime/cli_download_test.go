@@ -0,0 +1,422 @@
+package wakatime
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+)
+
+// fakeRelease serves a GitHub-style release with a zipped wakatime-cli and
+// a checksum file
+type fakeRelease struct {
+	server    *httptest.Server
+	archive   []byte
+	checksums string
+	assets    []string
+
+	// Requests for release metadata and for the archive
+	lookups   atomic.Int32
+	downloads atomic.Int32
+}
+
+func newFakeRelease(t *testing.T, script string) *fakeRelease {
+	t.Helper()
+
+	assetName := fmt.Sprintf("wakatime-cli-%s-%s.zip", runtime.GOOS, runtime.GOARCH)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(fmt.Sprintf("wakatime-cli-%s-%s", runtime.GOOS, runtime.GOARCH))
+	if err != nil {
+		t.Fatalf("Failed to create zip entry: %v", err)
+	}
+	w.Write([]byte(script))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to write zip: %v", err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	release := &fakeRelease{
+		archive:   buf.Bytes(),
+		checksums: fmt.Sprintf("0000000000000000000000000000000000000000000000000000000000000000  wakatime-cli-plan9-386.zip\n%s  %s\n", hex.EncodeToString(sum[:]), assetName),
+		assets:    []string{assetName, ChecksumsAsset},
+	}
+
+	release.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var assets []Asset
+		for _, name := range release.assets {
+			assets = append(assets, Asset{Name: name, BrowserDownloadURL: release.server.URL + "/download/" + name})
+		}
+		latest := GitHubRelease{TagName: "v1.99.0", Assets: assets}
+
+		switch r.URL.Path {
+		case "/releases/latest", "/releases/tags/v1.99.0":
+			release.lookups.Add(1)
+			json.NewEncoder(w).Encode(latest)
+		case "/releases":
+			json.NewEncoder(w).Encode([]GitHubRelease{{TagName: "v2.0.0-rc1", PreRelease: true}, latest})
+		case "/download/" + assetName:
+			release.downloads.Add(1)
+			w.Write(release.archive)
+		case "/download/" + ChecksumsAsset:
+			w.Write([]byte(release.checksums))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(release.server.Close)
+
+	return release
+}
+
+func newDownloadTestCLI(t *testing.T, release *fakeRelease) *CLI {
+	t.Helper()
+
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	t.Cleanup(func() { os.Setenv("HOME", originalHome) })
+	os.Setenv("HOME", tempDir)
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+
+	cli := NewCLI(cfg)
+	cli.releasesURL = release.server.URL + "/releases/latest"
+	return cli
+}
+
+const fakeCLIScript = "#!/bin/sh\necho v1.99.0\n"
+
+func TestInstallVerifiesChecksum(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binary is a shell script")
+	}
+
+	release := newFakeRelease(t, fakeCLIScript)
+	cli := newDownloadTestCLI(t, release)
+
+	if err := cli.install(); err != nil {
+		t.Fatalf("install() failed: %v", err)
+	}
+
+	version, err := cli.Version()
+	if err != nil || version != "v1.99.0" {
+		t.Errorf("Expected installed binary to report v1.99.0, got %q (err %v)", version, err)
+	}
+
+	// No temp files are left behind
+	entries, err := os.ReadDir(filepath.Dir(cli.binPath))
+	if err != nil {
+		t.Fatalf("Failed to read wakatime dir: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".wakatime-cli-") {
+			t.Errorf("Leftover temp file %s", entry.Name())
+		}
+	}
+}
+
+func TestInstallRefusesUnverifiedDownloads(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(r *fakeRelease)
+		wantErr string
+	}{
+		{
+			name:    "archive does not match checksum",
+			tamper:  func(r *fakeRelease) { r.archive = append(r.archive, 0) },
+			wantErr: "checksum mismatch",
+		},
+		{
+			name:    "release has no checksum file",
+			tamper:  func(r *fakeRelease) { r.assets = r.assets[:1] },
+			wantErr: "refusing to install",
+		},
+		{
+			name:    "asset missing from checksum file",
+			tamper:  func(r *fakeRelease) { r.checksums = "" },
+			wantErr: "no checksum listed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := newFakeRelease(t, fakeCLIScript)
+			tt.tamper(release)
+			cli := newDownloadTestCLI(t, release)
+
+			// An existing binary must survive a failed update untouched
+			existing := "#!/bin/sh\necho v1.0.0\n"
+			if err := os.MkdirAll(filepath.Dir(cli.binPath), 0755); err != nil {
+				t.Fatalf("Failed to create wakatime dir: %v", err)
+			}
+			if err := os.WriteFile(cli.binPath, []byte(existing), 0755); err != nil {
+				t.Fatalf("Failed to write existing binary: %v", err)
+			}
+
+			err := cli.install()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+
+			content, err := os.ReadFile(cli.binPath)
+			if err != nil || string(content) != existing {
+				t.Errorf("Expected existing binary to be untouched, got %q (err %v)", content, err)
+			}
+		})
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	digest := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		wantErr  bool
+	}{
+		{"text mode", digest + "  wakatime-cli-linux-amd64.zip\n", digest, false},
+		{"binary mode", strings.ToUpper(digest) + " *wakatime-cli-linux-amd64.zip\n", digest, false},
+		{"other assets only", digest + "  wakatime-cli-linux-arm64.zip\n", "", true},
+		{"prefix is not a match", digest + "  wakatime-cli-linux-amd64.zip.sig\n", "", true},
+		{"malformed digest", "xyz  wakatime-cli-linux-amd64.zip\n", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseChecksum(tt.content, "wakatime-cli-linux-amd64.zip")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseChecksum() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("parseChecksum() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestInstallFromArchive(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binary is a shell script")
+	}
+
+	release := newFakeRelease(t, fakeCLIScript)
+	assetName := release.assets[0]
+	sum := sha256.Sum256(release.archive)
+	digest := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name         string
+		sha          string
+		checksums    string
+		wantVerified bool
+		wantErr      string
+	}{
+		{name: "expected sha256", sha: digest, wantVerified: true},
+		{name: "checksum file beside archive", checksums: release.checksums, wantVerified: true},
+		{name: "unverified", wantVerified: false},
+		{name: "sha256 mismatch", sha: strings.Repeat("0", 64), wantErr: "checksum mismatch"},
+		{name: "archive not in checksum file", checksums: digest + "  other.zip\n", wantErr: "no checksum listed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := newDownloadTestCLI(t, release)
+
+			dir := t.TempDir()
+			archivePath := filepath.Join(dir, assetName)
+			if err := os.WriteFile(archivePath, release.archive, 0644); err != nil {
+				t.Fatalf("Failed to write archive: %v", err)
+			}
+			if tt.checksums != "" {
+				os.WriteFile(filepath.Join(dir, ChecksumsAsset), []byte(tt.checksums), 0644)
+			}
+
+			verified, err := cli.InstallFromArchive(archivePath, tt.sha)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				if cli.IsInstalled() {
+					t.Error("Expected nothing to be installed after a failed verification")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InstallFromArchive() failed: %v", err)
+			}
+
+			if verified != tt.wantVerified {
+				t.Errorf("Expected verified=%t, got %t", tt.wantVerified, verified)
+			}
+			if version, err := cli.Version(); err != nil || version != "v1.99.0" {
+				t.Errorf("Expected installed binary to report v1.99.0, got %q (err %v)", version, err)
+			}
+		})
+	}
+}
+
+func TestUseSystem(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binary is a shell script")
+	}
+
+	release := newFakeRelease(t, fakeCLIScript)
+	cli := newDownloadTestCLI(t, release)
+
+	binDir := t.TempDir()
+	systemBinary := filepath.Join(binDir, "wakatime-cli")
+	if err := os.WriteFile(systemBinary, []byte("#!/bin/sh\necho v1.50.0\n"), 0755); err != nil {
+		t.Fatalf("Failed to write system binary: %v", err)
+	}
+
+	originalPath := os.Getenv("PATH")
+	t.Cleanup(func() { os.Setenv("PATH", originalPath) })
+	os.Setenv("PATH", binDir+string(os.PathListSeparator)+originalPath)
+
+	target, err := cli.UseSystem("")
+	if err != nil {
+		t.Fatalf("UseSystem() failed: %v", err)
+	}
+	if target != systemBinary {
+		t.Errorf("Expected %s, got %s", systemBinary, target)
+	}
+
+	if !cli.IsSystemBinary() {
+		t.Error("Expected the managed path to link to the system binary")
+	}
+	if version, err := cli.Version(); err != nil || version != "v1.50.0" {
+		t.Errorf("Expected v1.50.0 through the link, got %q (err %v)", version, err)
+	}
+
+	// Adopted binaries are never replaced by the automatic update check
+	if err := cli.checkForUpdates(); err != nil {
+		t.Fatalf("checkForUpdates() failed: %v", err)
+	}
+	if version, _ := cli.Version(); version != "v1.50.0" {
+		t.Errorf("Expected the system binary to be left alone, got %q", version)
+	}
+
+	if _, err := cli.UseSystem(filepath.Join(binDir, "missing")); err == nil {
+		t.Error("Expected a missing binary to be rejected")
+	}
+}
+
+func TestInstallVersionAndRollback(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binary is a shell script")
+	}
+
+	release := newFakeRelease(t, fakeCLIScript)
+	cli := newDownloadTestCLI(t, release)
+
+	if err := os.MkdirAll(filepath.Dir(cli.binPath), 0755); err != nil {
+		t.Fatalf("Failed to create wakatime dir: %v", err)
+	}
+	if err := os.WriteFile(cli.binPath, []byte("#!/bin/sh\necho v1.0.0\n"), 0755); err != nil {
+		t.Fatalf("Failed to write existing binary: %v", err)
+	}
+
+	if err := cli.InstallVersion("2.5.0"); err == nil {
+		t.Error("Expected an unknown release to fail")
+	}
+
+	if err := cli.InstallVersion("1.99.0"); err != nil {
+		t.Fatalf("InstallVersion() failed: %v", err)
+	}
+	if version, _ := cli.Version(); version != "v1.99.0" {
+		t.Fatalf("Expected v1.99.0 after install, got %q", version)
+	}
+
+	version, err := cli.Rollback()
+	if err != nil || version != "v1.0.0" {
+		t.Fatalf("Expected rollback to v1.0.0, got %q (err %v)", version, err)
+	}
+
+	// Rolling back again restores the newer binary
+	version, err = cli.Rollback()
+	if err != nil || version != "v1.99.0" {
+		t.Fatalf("Expected second rollback to v1.99.0, got %q (err %v)", version, err)
+	}
+
+	os.Remove(cli.PreviousBinaryPath())
+	if _, err := cli.Rollback(); err == nil {
+		t.Error("Expected rollback without a previous binary to fail")
+	}
+}
+
+func TestPinnedVersionIsEnforced(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binary is a shell script")
+	}
+
+	release := newFakeRelease(t, fakeCLIScript)
+	cli := newDownloadTestCLI(t, release)
+	cli.config.WakaTimeCLIVersion = "1.99.0"
+
+	if err := os.MkdirAll(filepath.Dir(cli.binPath), 0755); err != nil {
+		t.Fatalf("Failed to create wakatime dir: %v", err)
+	}
+	if err := os.WriteFile(cli.binPath, []byte("#!/bin/sh\necho v1.0.0\n"), 0755); err != nil {
+		t.Fatalf("Failed to write existing binary: %v", err)
+	}
+
+	// The pin applies even though the daily update check just ran
+	cli.saveLastUpdateCheck()
+	if err := cli.EnsureInstalled(); err != nil {
+		t.Fatalf("EnsureInstalled() failed: %v", err)
+	}
+	if version, _ := cli.Version(); version != "v1.99.0" {
+		t.Errorf("Expected pinned v1.99.0, got %q", version)
+	}
+}
+
+func TestListReleases(t *testing.T) {
+	release := newFakeRelease(t, fakeCLIScript)
+	cli := newDownloadTestCLI(t, release)
+
+	releases, err := cli.ListReleases(10)
+	if err != nil {
+		t.Fatalf("ListReleases() failed: %v", err)
+	}
+	if len(releases) != 2 || !releases[0].PreRelease || releases[1].TagName != "v1.99.0" {
+		t.Errorf("Unexpected releases %+v", releases)
+	}
+
+	if releases, _ := cli.ListReleases(1); len(releases) != 1 {
+		t.Errorf("Expected the limit to apply, got %d releases", len(releases))
+	}
+}
+
+func TestNormalizeVersion(t *testing.T) {
+	tests := map[string]string{
+		"":         "",
+		"latest":   "",
+		"LATEST":   "",
+		"1.98.0":   "v1.98.0",
+		"v1.98.0":  "v1.98.0",
+		" v1.2.3 ": "v1.2.3",
+	}
+
+	for input, expected := range tests {
+		if got := NormalizeVersion(input); got != expected {
+			t.Errorf("NormalizeVersion(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
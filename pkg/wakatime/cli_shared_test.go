@@ -0,0 +1,199 @@
+package wakatime
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hackclub/terminal-wakatime/pkg/config"
+)
+
+func newSharedTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	originalPath := os.Getenv("PATH")
+	t.Cleanup(func() {
+		os.Setenv("HOME", originalHome)
+		os.Setenv("PATH", originalPath)
+	})
+	os.Setenv("HOME", tempDir)
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() failed: %v", err)
+	}
+	return cfg
+}
+
+func writeScript(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestEnsureInstalledReusesSharedInstall(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binary is a shell script")
+	}
+
+	cfg := newSharedTestConfig(t)
+
+	// Layout left by an editor plugin that installed its own copy
+	pluginBinary := filepath.Join(cfg.WakaTimeDir(), "plugin", "wakatime-cli-real")
+	writeScript(t, pluginBinary, "#!/bin/sh\necho v1.80.0\n")
+	if err := os.Symlink(pluginBinary, filepath.Join(cfg.WakaTimeDir(), SharedBinaryName)); err != nil {
+		t.Fatalf("Failed to create shared link: %v", err)
+	}
+
+	cli := NewCLI(cfg)
+
+	// Creating a CLI, as read-only commands do, changes nothing
+	if _, err := os.Lstat(cli.BinaryPath()); !os.IsNotExist(err) {
+		t.Fatalf("Expected NewCLI() not to link %s", cli.BinaryPath())
+	}
+
+	if err := cli.EnsureInstalled(); err != nil {
+		t.Fatalf("EnsureInstalled() failed: %v", err)
+	}
+	if !cli.IsInstalled() || !cli.IsSystemBinary() {
+		t.Fatalf("Expected the shared install to be adopted")
+	}
+	if target, err := os.Readlink(cli.BinaryPath()); err != nil || target != pluginBinary {
+		t.Errorf("Expected %s to link to %s, got %q (err %v)", cli.BinaryPath(), pluginBinary, target, err)
+	}
+	if version, _ := cli.Version(); version != "v1.80.0" {
+		t.Errorf("Expected v1.80.0 from the shared install, got %q", version)
+	}
+}
+
+func TestEnsureInstalledReusesWakaTimeCLIOnPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binary is a shell script")
+	}
+
+	tests := []struct {
+		name    string
+		script  string
+		adopted bool
+	}{
+		{"working binary", "#!/bin/sh\necho v1.90.0\n", true},
+		{"broken binary", "#!/bin/sh\nexit 1\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newSharedTestConfig(t)
+
+			binDir := t.TempDir()
+			writeScript(t, filepath.Join(binDir, "wakatime-cli"), tt.script)
+			os.Setenv("PATH", binDir)
+
+			// Nothing to download when the binary isn't adopted
+			cfg.CLIReleasesURL = "http://127.0.0.1:1/releases/latest"
+			cli := NewCLI(cfg)
+			cli.install()
+			if cli.IsSystemBinary() != tt.adopted {
+				t.Errorf("Expected adopted=%t, got %t", tt.adopted, cli.IsSystemBinary())
+			}
+		})
+	}
+}
+
+func TestUnrecordedLinkIsNotSystemBinary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Symlinks need extra privileges on Windows")
+	}
+
+	cfg := newSharedTestConfig(t)
+	cli := NewCLI(cfg)
+
+	// A link somebody else put at the binary path is updated like our own
+	other := filepath.Join(cfg.WakaTimeDir(), "other", "wakatime-cli")
+	writeScript(t, other, "#!/bin/sh\necho v1.70.0\n")
+	if err := os.Symlink(other, cli.BinaryPath()); err != nil {
+		t.Fatalf("Failed to create link: %v", err)
+	}
+	if cli.IsSystemBinary() {
+		t.Error("Expected a link without an adoption record not to count as a system binary")
+	}
+
+	if _, err := cli.UseSystem(other); err != nil {
+		t.Fatalf("UseSystem() failed: %v", err)
+	}
+	if !cli.IsSystemBinary() {
+		t.Error("Expected UseSystem() to record the adoption")
+	}
+}
+
+func TestReinstallDownloadsInsteadOfAdopting(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binary is a shell script")
+	}
+
+	release := newFakeRelease(t, fakeCLIScript)
+	cli := newDownloadTestCLI(t, release)
+	originalPath := os.Getenv("PATH")
+	t.Cleanup(func() { os.Setenv("PATH", originalPath) })
+
+	// The broken wakatime-cli that was adopted and is being replaced
+	binDir := t.TempDir()
+	broken := filepath.Join(binDir, "wakatime-cli")
+	writeScript(t, broken, "#!/bin/sh\necho v1.50.0\n")
+	os.Setenv("PATH", binDir)
+	if _, err := cli.UseSystem(broken); err != nil {
+		t.Fatalf("UseSystem() failed: %v", err)
+	}
+
+	if err := cli.Reinstall(); err != nil {
+		t.Fatalf("Reinstall() failed: %v", err)
+	}
+	if downloads := release.downloads.Load(); downloads != 1 {
+		t.Errorf("Expected a download, got %d", downloads)
+	}
+	if cli.IsSystemBinary() {
+		t.Error("Expected the adopted binary to be replaced")
+	}
+	if info, err := os.Lstat(cli.BinaryPath()); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("Expected a downloaded binary, got %v (err %v)", info, err)
+	}
+	if version, _ := cli.Version(); version != "v1.99.0" {
+		t.Errorf("Expected v1.99.0 to be installed, got %q", version)
+	}
+}
+
+func TestInstallCreatesSharedLink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Symlinks need extra privileges on Windows")
+	}
+
+	release := newFakeRelease(t, fakeCLIScript)
+	cli := newDownloadTestCLI(t, release)
+
+	if err := cli.install(); err != nil {
+		t.Fatalf("install() failed: %v", err)
+	}
+
+	target, err := os.Readlink(cli.SharedBinaryPath())
+	if err != nil || target != cli.BinaryPath() {
+		t.Errorf("Expected %s to link to %s, got %q (err %v)", cli.SharedBinaryPath(), cli.BinaryPath(), target, err)
+	}
+
+	// A regular file at the shared path belongs to someone else
+	os.Remove(cli.SharedBinaryPath())
+	writeScript(t, cli.SharedBinaryPath(), "#!/bin/sh\necho v1.0.0\n")
+
+	if err := cli.InstallVersion("v1.99.0"); err != nil {
+		t.Fatalf("InstallVersion() failed: %v", err)
+	}
+	if info, err := os.Lstat(cli.SharedBinaryPath()); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("Expected the existing file to be left alone, got %v (err %v)", info, err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hackclub/terminal-wakatime/pkg/config"
 )
@@ -170,13 +171,44 @@ exit 0
 
 	// Test sending heartbeat (will only work on Unix systems)
 	if runtime.GOOS != "windows" {
-		err := cli.SendHeartbeat("/path/to/file.go", "file", "coding", "go", "test-project", "main", false, nil, nil, nil, nil, nil)
+		err := cli.SendHeartbeat("/path/to/file.go", "file", "coding", "go", "test-project", "main", "", time.Time{}, false, false, nil, nil, nil, nil, nil)
 		if err != nil {
 			t.Logf("SendHeartbeat failed (expected in test environment): %v", err)
 		}
 	}
 }
 
+func TestSendHeartbeatTime(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binary is a shell script")
+	}
+
+	cli := NewCLI(newSharedTestConfig(t))
+	argsFile := filepath.Join(t.TempDir(), "args")
+	writeScript(t, cli.BinaryPath(), "#!/bin/sh\necho \"$@\" > "+argsFile+"\n")
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{"now", time.Time{}, ""},
+		{"earlier", time.Unix(1700000000, 250*int64(time.Millisecond)), "--time 1700000000.250"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := cli.SendHeartbeat("/srv/app/main.go", "file", "coding", "", "", "", "", tt.at, false, true, nil, nil, nil, nil, nil); err != nil {
+				t.Fatalf("SendHeartbeat() failed: %v", err)
+			}
+			args, _ := os.ReadFile(argsFile)
+			if got := strings.Contains(string(args), "--time"); got != (tt.want != "") || !strings.Contains(string(args), tt.want) {
+				t.Errorf("Expected %q in the arguments, got %q", tt.want, args)
+			}
+		})
+	}
+}
+
 func TestTestConnection(t *testing.T) {
 	tempDir := t.TempDir()
 	cfg := &config.Config{}
@@ -232,7 +264,7 @@ func TestGetLatestRelease(t *testing.T) {
 	cfg := &config.Config{}
 	cli := NewCLI(cfg)
 
-	release, err := cli.getLatestRelease()
+	release, err := cli.getRelease("")
 	if err != nil {
 		t.Logf("Failed to get latest release (network issue): %v", err)
 		return
@@ -0,0 +1,202 @@
+package wakatime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/watcher"
+)
+
+const (
+	// InstallLockFile is held while a process installs or updates wakatime-cli
+	InstallLockFile = "wakatime-cli.lock"
+
+	// InstallFailureFile records the last failed install for backoff
+	InstallFailureFile = "wakatime-cli_install_failure.json"
+
+	// A lock older than this whose process is gone is left over from a
+	// crash
+	staleLockAge = 10 * time.Minute
+
+	// How long a process waits for another one's install to finish
+	installLockWait = 2 * time.Minute
+
+	lockPollInterval = 200 * time.Millisecond
+
+	installBackoffBase = 5 * time.Minute
+	installBackoffMax  = 24 * time.Hour
+)
+
+// errInstallLocked is returned when another process holds the install lock
+var errInstallLocked = errors.New("another wakatime-cli install is in progress")
+
+// installFailure is the content of InstallFailureFile
+type installFailure struct {
+	Time     time.Time `json:"time"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+}
+
+// retryAt is when the next install may be attempted: the backoff doubles
+// with every consecutive failure, up to installBackoffMax
+func (f *installFailure) retryAt() time.Time {
+	backoff := installBackoffBase
+	for i := 1; i < f.Attempts && backoff < installBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > installBackoffMax {
+		backoff = installBackoffMax
+	}
+
+	return f.Time.Add(backoff)
+}
+
+// installOnce installs wakatime-cli unless another process already did or
+// a recent attempt failed. Parallel shells on a fresh machine all end up
+// here; only one of them downloads.
+func (c *CLI) installOnce() error {
+	return c.installGuarded(installLockWait, func() bool { return !c.IsInstalled() })
+}
+
+// installGuarded runs install under the install lock, honouring the
+// failure backoff. needed is checked again once the lock is held, since
+// the previous holder may have done the work already.
+func (c *CLI) installGuarded(wait time.Duration, needed func() bool) error {
+	if failure := c.loadInstallFailure(); failure != nil && time.Now().Before(failure.retryAt()) {
+		return fmt.Errorf("wakatime-cli install failed recently, retrying after %s: %s",
+			failure.retryAt().Format(time.Kitchen), failure.Error)
+	}
+
+	return c.withInstallLock(wait, func() error {
+		if !needed() {
+			return nil
+		}
+
+		return c.recordInstall(c.install())
+	})
+}
+
+// recordInstall updates the backoff marker with the outcome of an install
+func (c *CLI) recordInstall(err error) error {
+	path := filepath.Join(c.config.WakaTimeDir(), InstallFailureFile)
+
+	if err == nil {
+		os.Remove(path)
+		return nil
+	}
+
+	failure := installFailure{Time: time.Now(), Attempts: 1, Error: err.Error()}
+	if previous := c.loadInstallFailure(); previous != nil {
+		failure.Attempts = previous.Attempts + 1
+	}
+
+	if data, jsonErr := json.Marshal(failure); jsonErr == nil {
+		os.WriteFile(path, data, 0644)
+	}
+
+	return err
+}
+
+func (c *CLI) loadInstallFailure() *installFailure {
+	data, err := os.ReadFile(filepath.Join(c.config.WakaTimeDir(), InstallFailureFile))
+	if err != nil {
+		return nil
+	}
+
+	var failure installFailure
+	if err := json.Unmarshal(data, &failure); err != nil {
+		return nil
+	}
+
+	return &failure
+}
+
+// InstallBackoff returns when the next automatic install is allowed and
+// why the last one failed, or a zero time if there is no pending backoff
+func (c *CLI) InstallBackoff() (time.Time, string) {
+	failure := c.loadInstallFailure()
+	if failure == nil || !time.Now().Before(failure.retryAt()) {
+		return time.Time{}, ""
+	}
+
+	return failure.retryAt(), failure.Error
+}
+
+// ResetInstallBackoff forgets earlier failed installs so the next
+// EnsureInstalled tries again right away
+func (c *CLI) ResetInstallBackoff() {
+	os.Remove(filepath.Join(c.config.WakaTimeDir(), InstallFailureFile))
+}
+
+// withInstallLock runs fn while holding the inter-process install lock,
+// waiting up to wait for another holder. The lock is a file created with
+// O_EXCL, which works the same on every platform, holding the pid of its
+// process.
+func (c *CLI) withInstallLock(wait time.Duration, fn func() error) error {
+	if err := os.MkdirAll(c.config.WakaTimeDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create wakatime directory: %w", err)
+	}
+
+	path := filepath.Join(c.config.WakaTimeDir(), InstallLockFile)
+	deadline := time.Now().Add(wait)
+
+	for {
+		lock, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			lock.WriteString(strconv.Itoa(os.Getpid()))
+			lock.Close()
+			defer os.Remove(path)
+			return fn()
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("failed to create install lock: %w", err)
+		}
+
+		if breakStaleLock(path) {
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return errInstallLocked
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// breakStaleLock removes the lock at path if a crashed process left it
+// behind: it is older than staleLockAge and the process whose pid it holds
+// is gone. It is read again right before the removal, so a lock another
+// waiter has just broken and taken is left alone.
+func breakStaleLock(path string) bool {
+	info, content, ok := readLock(path)
+	if !ok || time.Since(info.ModTime()) <= staleLockAge {
+		return false
+	}
+	if pid, err := strconv.Atoi(strings.TrimSpace(content)); err == nil && watcher.ProcessAlive(pid) {
+		return false
+	}
+
+	again, againContent, ok := readLock(path)
+	if !ok || !again.ModTime().Equal(info.ModTime()) || againContent != content {
+		return false
+	}
+	return os.Remove(path) == nil
+}
+
+func readLock(path string) (os.FileInfo, string, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", false
+	}
+	return info, string(data), true
+}
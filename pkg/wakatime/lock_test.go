@@ -0,0 +1,175 @@
+package wakatime
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParallelInstallsDownloadOnce(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binary is a shell script")
+	}
+
+	release := newFakeRelease(t, fakeCLIScript)
+	first := newDownloadTestCLI(t, release)
+
+	// Every shell builds its own CLI; they only share the WakaTime directory
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cli := NewCLI(first.config)
+			cli.releasesURL = first.releasesURL
+			errs <- cli.EnsureInstalled()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("EnsureInstalled() failed: %v", err)
+		}
+	}
+
+	if downloads := release.downloads.Load(); downloads != 1 {
+		t.Errorf("Expected a single download, got %d", downloads)
+	}
+	if version, _ := first.Version(); version != "v1.99.0" {
+		t.Errorf("Expected v1.99.0 to be installed, got %q", version)
+	}
+	if _, err := os.Stat(filepath.Join(first.config.WakaTimeDir(), InstallLockFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected the install lock to be released, got %v", err)
+	}
+}
+
+func TestFailedInstallBacksOff(t *testing.T) {
+	release := newFakeRelease(t, fakeCLIScript)
+	release.assets = release.assets[:1] // no checksums, so every install fails
+	cli := newDownloadTestCLI(t, release)
+
+	if err := cli.EnsureInstalled(); err == nil {
+		t.Fatal("Expected the install to fail")
+	}
+	if lookups := release.lookups.Load(); lookups != 1 {
+		t.Fatalf("Expected one release lookup, got %d", lookups)
+	}
+
+	err := cli.EnsureInstalled()
+	if err == nil || !strings.Contains(err.Error(), "failed recently") {
+		t.Errorf("Expected the backoff to stop the retry, got %v", err)
+	}
+	if lookups := release.lookups.Load(); lookups != 1 {
+		t.Errorf("Expected no new release lookup during backoff, got %d", lookups)
+	}
+
+	if retryAt, reason := cli.InstallBackoff(); retryAt.IsZero() || !strings.Contains(reason, "refusing to install") {
+		t.Errorf("Expected a pending backoff with the failure reason, got %v %q", retryAt, reason)
+	}
+
+	cli.ResetInstallBackoff()
+	cli.EnsureInstalled()
+	if lookups := release.lookups.Load(); lookups != 2 {
+		t.Errorf("Expected a retry after ResetInstallBackoff, got %d lookups", lookups)
+	}
+}
+
+func TestInstallBackoffDoubles(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 5 * time.Minute},
+		{2, 10 * time.Minute},
+		{4, 40 * time.Minute},
+		{20, 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		failure := installFailure{Time: now, Attempts: tt.attempts}
+		if got := failure.retryAt().Sub(now); got != tt.expected {
+			t.Errorf("retryAt() after %d attempts = %v, want %v", tt.attempts, got, tt.expected)
+		}
+	}
+}
+
+func TestInstallLock(t *testing.T) {
+	release := newFakeRelease(t, fakeCLIScript)
+	cli := newDownloadTestCLI(t, release)
+
+	lockPath := filepath.Join(cli.config.WakaTimeDir(), InstallLockFile)
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		t.Fatalf("Failed to create wakatime dir: %v", err)
+	}
+	// The pid of a process that has exited
+	exited := exec.Command(os.Args[0], "-test.run=^$")
+	if err := exited.Run(); err != nil {
+		t.Skipf("Can't run a process: %v", err)
+	}
+	if err := os.WriteFile(lockPath, []byte(strconv.Itoa(exited.Process.Pid)), 0644); err != nil {
+		t.Fatalf("Failed to write lock: %v", err)
+	}
+
+	ran := false
+	err := cli.withInstallLock(0, func() error { ran = true; return nil })
+	if err != errInstallLocked || ran {
+		t.Errorf("Expected a held lock to be respected, got %v (ran %t)", err, ran)
+	}
+
+	// An old lock whose process still runs is still held
+	stale := time.Now().Add(-2 * staleLockAge)
+	os.WriteFile(lockPath, []byte(strconv.Itoa(os.Getpid())), 0644)
+	os.Chtimes(lockPath, stale, stale)
+
+	err = cli.withInstallLock(0, func() error { ran = true; return nil })
+	if err != errInstallLocked || ran {
+		t.Errorf("Expected an old lock of a running process to be respected, got %v (ran %t)", err, ran)
+	}
+
+	// A lock left behind by a crashed process is taken over
+	os.WriteFile(lockPath, []byte(strconv.Itoa(exited.Process.Pid)), 0644)
+	os.Chtimes(lockPath, stale, stale)
+
+	if err := cli.withInstallLock(0, func() error { ran = true; return nil }); err != nil || !ran {
+		t.Errorf("Expected a stale lock to be replaced, got %v (ran %t)", err, ran)
+	}
+}
+
+func TestBreakStaleLockLeavesReplacedLock(t *testing.T) {
+	exited := exec.Command(os.Args[0], "-test.run=^$")
+	if err := exited.Run(); err != nil {
+		t.Skipf("Can't run a process: %v", err)
+	}
+
+	lockPath := filepath.Join(t.TempDir(), InstallLockFile)
+	stale := time.Now().Add(-2 * staleLockAge)
+
+	// Two waiters saw the same stale lock; the first broke it and took the
+	// lock, which the second must not break in turn
+	os.WriteFile(lockPath, []byte(strconv.Itoa(exited.Process.Pid)), 0644)
+	os.Chtimes(lockPath, stale, stale)
+	if !breakStaleLock(lockPath) {
+		t.Fatal("Expected the stale lock to be broken")
+	}
+
+	if err := os.WriteFile(lockPath, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		t.Fatalf("Failed to write lock: %v", err)
+	}
+	if breakStaleLock(lockPath) {
+		t.Error("Expected the new holder's lock to be left alone")
+	}
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Errorf("Expected the new lock to remain: %v", err)
+	}
+}
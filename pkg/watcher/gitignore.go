@@ -0,0 +1,204 @@
+package watcher
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Gitignore matches paths against .gitignore rules. Paths are relative to
+// the repository root and use forward slashes.
+type Gitignore struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	base    string // directory of the .gitignore the rule came from
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// AddFile reads the rules of a .gitignore (or info/exclude) file that
+// applies below base. A missing file adds nothing.
+func (g *Gitignore) AddFile(file, base string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	g.AddPatterns(base, lines)
+	return nil
+}
+
+// AddPatterns adds gitignore lines that apply below base
+func (g *Gitignore) AddPatterns(base string, lines []string) {
+	base = strings.Trim(base, "/")
+	if base == "." {
+		base = ""
+	}
+
+	for _, line := range lines {
+		if rule, ok := parseIgnoreLine(line); ok {
+			rule.base = base
+			g.rules = append(g.rules, rule)
+		}
+	}
+}
+
+// Ignored reports whether rel is ignored. As in git, nothing inside an
+// ignored directory can be re-included, so parents are checked first.
+func (g *Gitignore) Ignored(rel string, isDir bool) bool {
+	if g == nil || len(g.rules) == 0 {
+		return false
+	}
+
+	rel = strings.Trim(rel, "/")
+	if rel == "" || rel == "." {
+		return false
+	}
+
+	for i := 0; i < len(rel); i++ {
+		if rel[i] == '/' && g.match(rel[:i], true) {
+			return true
+		}
+	}
+	return g.match(rel, isDir)
+}
+
+// match applies the rules to one path; the last matching rule wins
+func (g *Gitignore) match(rel string, isDir bool) bool {
+	ignored := false
+
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		sub := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			sub = rel[len(rule.base)+1:]
+		}
+
+		if rule.re.MatchString(sub) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+// parseIgnoreLine compiles one gitignore line, or returns false for blank
+// lines and comments
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, "\r")
+
+	// Trailing spaces are dropped unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// A slash anywhere but the end anchors the pattern to its .gitignore;
+	// otherwise it matches a name at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	expr := globToRegexp(line)
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+
+	return rule, true
+}
+
+// globToRegexp translates gitignore glob syntax: * and ? stop at slashes,
+// ** spans directories and [...] is a character class
+func globToRegexp(glob string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				if i+2 < len(glob) && glob[i+2] == '/' {
+					b.WriteString("(?:.*/)?") // **/ matches zero or more directories
+					i += 2
+				} else {
+					b.WriteString(".*")
+					i++
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,60 @@
+package watcher
+
+import "testing"
+
+func TestGitignore(t *testing.T) {
+	g := &Gitignore{}
+	g.AddPatterns("", []string{
+		"# build output",
+		"*.log",
+		"!keep.log",
+		"/dist",
+		"build/",
+		"docs/**/*.pdf",
+		"**/tmp",
+		`\#literal`,
+		"",
+	})
+	g.AddPatterns("web", []string{"*.map", "/cache"})
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"main.go", false, false},
+		{"debug.log", false, true},
+		{"src/deep/debug.log", false, true},
+		{"keep.log", false, false},
+		{"dist", true, true},
+		{"src/dist", true, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"build/out.go", false, true},
+		{"src/build/out.go", false, true},
+		{"docs/a/b/manual.pdf", false, true},
+		{"docs/manual.pdf", false, true},
+		{"manual.pdf", false, false},
+		{"a/b/tmp", true, true},
+		{"#literal", false, true},
+		{"web/app.js.map", false, true},
+		{"app.js.map", false, false},
+		{"web/cache/x", false, true},
+		{"web/src/cache", true, false},
+	}
+
+	for _, tt := range tests {
+		if got := g.Ignored(tt.path, tt.isDir); got != tt.ignored {
+			t.Errorf("Ignored(%q, %t) = %t, expected %t", tt.path, tt.isDir, got, tt.ignored)
+		}
+	}
+}
+
+func TestGitignoreCannotReincludeInIgnoredDirectory(t *testing.T) {
+	g := &Gitignore{}
+	g.AddPatterns("", []string{"logs/", "!logs/important.txt"})
+
+	if !g.Ignored("logs/important.txt", false) {
+		t.Error("Expected a file inside an ignored directory to stay ignored")
+	}
+}
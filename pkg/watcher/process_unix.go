@@ -0,0 +1,24 @@
+//go:build !windows
+
+package watcher
+
+import (
+	"os"
+	"syscall"
+)
+
+// ProcessAlive reports whether a process with pid exists
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	// Signal 0 checks for existence; EPERM means it exists as another user
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package watcher
+
+import "os"
+
+// ProcessAlive reports whether a process with pid exists
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	// On Windows FindProcess opens the process, which fails once it exits
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
@@ -0,0 +1,192 @@
+// Package watcher reports the files saved under a project root while an
+// editor runs, so sessions such as `vim .` are credited to the files
+// actually edited instead of the editor itself.
+package watcher
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// maxDirectories bounds the inotify watches spent on one large tree
+	maxDirectories = 4096
+
+	// saveSettle is how long a file must be quiet before its save is
+	// reported, so a save's create, write and chmod events count once and
+	// the heartbeat sees the finished file
+	saveSettle = 500 * time.Millisecond
+)
+
+// Directories that are never watched, ignored by git or not
+var skipDirs = map[string]bool{
+	".git":         true,
+	".hg":          true,
+	".svn":         true,
+	".jj":          true,
+	"node_modules": true,
+}
+
+// Swap and backup files vim, emacs and friends write next to the file
+var editorTempFile = regexp.MustCompile(`(^4913$|~$|^\.#|^#.*#$|\.sw[a-p]$|\.tmp$)`)
+
+// Watcher watches a project tree for saved files
+type Watcher struct {
+	root     string
+	ignore   *Gitignore
+	excluded func(path string) bool
+	fs       *fsnotify.Watcher
+
+	dirs       int
+	gitignores map[string]bool
+	pending    map[string]time.Time
+}
+
+// New watches root and every directory below it that isn't ignored by
+// git. excluded, if set, filters reported files by absolute path.
+func New(root string, excluded func(path string) bool) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		root:       filepath.Clean(root),
+		ignore:     &Gitignore{},
+		excluded:   excluded,
+		fs:         fsw,
+		gitignores: make(map[string]bool),
+		pending:    make(map[string]time.Time),
+	}
+
+	w.ignore.AddFile(filepath.Join(w.root, ".git", "info", "exclude"), "")
+	w.addTree(w.root)
+
+	return w, nil
+}
+
+// Root returns the watched directory
+func (w *Watcher) Root() string {
+	return w.root
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	return w.fs.Close()
+}
+
+// Run calls onSave with the absolute path of each saved file until ctx is
+// done. Saves still settling when ctx ends are reported before returning.
+func (w *Watcher) Run(ctx context.Context, onSave func(path string)) error {
+	ticker := time.NewTicker(saveSettle / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.flush(time.Time{}, onSave)
+			return nil
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return nil
+			}
+			w.handle(event)
+		case _, ok := <-w.fs.Errors:
+			// Overflows lose events but the watch is still good
+			if !ok {
+				return nil
+			}
+		case now := <-ticker.C:
+			w.flush(now.Add(-saveSettle), onSave)
+		}
+	}
+}
+
+func (w *Watcher) handle(event fsnotify.Event) {
+	if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+		return
+	}
+
+	info, err := os.Lstat(event.Name)
+	if err != nil {
+		return
+	}
+
+	if info.IsDir() {
+		if event.Has(fsnotify.Create) {
+			w.addTree(event.Name)
+		}
+		return
+	}
+
+	if !info.Mode().IsRegular() || editorTempFile.MatchString(filepath.Base(event.Name)) {
+		return
+	}
+	if w.ignore.Ignored(w.rel(event.Name), false) {
+		return
+	}
+	if w.excluded != nil && w.excluded(event.Name) {
+		return
+	}
+
+	w.pending[event.Name] = time.Now()
+}
+
+// flush reports the pending saves last touched before cutoff; the zero
+// time reports all of them
+func (w *Watcher) flush(cutoff time.Time, onSave func(path string)) {
+	for path, touched := range w.pending {
+		if cutoff.IsZero() || touched.Before(cutoff) {
+			delete(w.pending, path)
+			onSave(path)
+		}
+	}
+}
+
+// addTree watches dir and the directories below it, loading their
+// .gitignore files on the way down
+func (w *Watcher) addTree(dir string) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+
+		rel := w.rel(path)
+		if rel != "" && (skipDirs[d.Name()] || w.ignore.Ignored(rel, true)) {
+			return filepath.SkipDir
+		}
+		if w.dirs >= maxDirectories {
+			return filepath.SkipAll
+		}
+
+		if !w.gitignores[path] {
+			w.gitignores[path] = true
+			w.ignore.AddFile(filepath.Join(path, ".gitignore"), rel)
+		}
+
+		// Out of inotify watches or permission denied: leave this branch out
+		if err := w.fs.Add(path); err != nil {
+			return filepath.SkipDir
+		}
+		w.dirs++
+
+		return nil
+	})
+}
+
+// rel returns path relative to the root with forward slashes, "" for the
+// root itself
+func (w *Watcher) rel(path string) string {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
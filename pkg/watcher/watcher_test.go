@@ -0,0 +1,101 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatcherReportsSavedFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"src", "vendor/lib", ".git/info"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\nvendor/\n"), 0644)
+	os.WriteFile(filepath.Join(root, ".git", "info", "exclude"), []byte("scratch.txt\n"), 0644)
+
+	w, err := New(root, func(path string) bool { return strings.Contains(path, "secret") })
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer w.Close()
+
+	var mu sync.Mutex
+	var saved []string
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Run(ctx, func(path string) {
+			mu.Lock()
+			defer mu.Unlock()
+			rel, _ := filepath.Rel(root, path)
+			saved = append(saved, filepath.ToSlash(rel))
+		})
+	}()
+
+	write := func(rel, content string) {
+		path := filepath.Join(root, rel)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("src/main.go", "package main\n")
+	write("src/main.go", "package main\n\nfunc main() {}\n")
+	write("debug.log", "ignored\n")
+	write("scratch.txt", "ignored\n")
+	write("vendor/lib/lib.go", "ignored\n")
+	write("src/.main.go.swp", "swap\n")
+	write("src/4913", "")
+	write("secret.env", "excluded\n")
+
+	// A directory created while watching is picked up
+	os.Mkdir(filepath.Join(root, "pkg"), 0755)
+	time.Sleep(100 * time.Millisecond)
+	write("pkg/util.go", "package pkg\n")
+
+	time.Sleep(2 * saveSettle)
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Strings(saved)
+
+	expected := []string{"pkg/util.go", "src/main.go"}
+	if strings.Join(saved, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected saves %v, got %v", expected, saved)
+	}
+}
+
+func TestEditorTempFile(t *testing.T) {
+	for _, name := range []string{".main.go.swp", ".main.go.swo", "main.go~", "4913", ".#main.go", "#main.go#"} {
+		if !editorTempFile.MatchString(name) {
+			t.Errorf("Expected %q to be an editor temp file", name)
+		}
+	}
+	for _, name := range []string{"main.go", "swap.go", "notes.md"} {
+		if editorTempFile.MatchString(name) {
+			t.Errorf("Expected %q to be a real file", name)
+		}
+	}
+}
+
+func TestProcessAlive(t *testing.T) {
+	if !ProcessAlive(os.Getpid()) {
+		t.Error("Expected the test process to be alive")
+	}
+	if ProcessAlive(0) {
+		t.Error("Expected pid 0 to be rejected")
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/hackclub/terminal-wakatime/pkg/shell"
 )
 
 // ShellTestSuite runs comprehensive integration tests for each supported shell
@@ -780,6 +782,173 @@ func TestEditorDetection(t *testing.T) {
 	}
 }
 
+// TestShellBashBuiltinPreexec runs an interactive bash without bash-preexec and
+// verifies that the built-in DEBUG trap reports exactly one command per prompt
+func TestShellBashBuiltinPreexec(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping shell integration tests in short mode")
+	}
+
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Fatalf("Required shell bash not found in PATH - please install bash to run shell integration tests")
+	}
+
+	testDir := t.TempDir()
+	trackLog := filepath.Join(testDir, "track.log")
+	userTrapLog := filepath.Join(testDir, "user-trap.log")
+
+	// Stand-in for terminal-wakatime that records every track invocation
+	fakeBinary := filepath.Join(testDir, "fake-terminal-wakatime")
+	fakeScript := fmt.Sprintf("#!/bin/sh\nprintf '%%s\\n' \"$3\" >> %q\n", trackLog)
+	if err := os.WriteFile(fakeBinary, []byte(fakeScript), 0755); err != nil {
+		t.Fatalf("Failed to create fake binary: %v", err)
+	}
+
+	hooks := shell.NewIntegrationForShellWithConfig(fakeBinary, "bash", 0).GenerateHooks()
+
+	// A pre-existing DEBUG trap and array PROMPT_COMMAND must keep working
+	rcContent := fmt.Sprintf(`trap 'echo trapped >> %q' DEBUG
+PROMPT_COMMAND=(": first" ": second")
+%s
+`, userTrapLog, hooks)
+	rcFile := filepath.Join(testDir, "rc.bash")
+	if err := os.WriteFile(rcFile, []byte(rcContent), 0644); err != nil {
+		t.Fatalf("Failed to write rc file: %v", err)
+	}
+
+	input := strings.Join([]string{
+		"sleep 1",
+		"helper() { true; true; true; }",
+		"helper",
+		"",
+		"echo piped | cat && true",
+		"set -x; true; set +x",
+		"exit",
+	}, "\n") + "\n"
+
+	cmd := exec.Command("bash", "--noprofile", "--rcfile", rcFile, "-i")
+	cmd.Dir = testDir
+	cmd.Env = append(os.Environ(), "HOME="+testDir, "HISTFILE=/dev/null")
+	cmd.Stdin = strings.NewReader(input)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Interactive bash failed: %v\nOutput: %s", err, output)
+	}
+
+	if strings.Contains(string(output), "__terminal_wakatime_preexec \"$BASH_COMMAND\"") {
+		t.Errorf("set -x output should not be rewritten through PS4:\n%s", output)
+	}
+
+	// Tracking happens in the background; wait for the log to settle
+	expected := []string{
+		"sleep 1",
+		"helper",
+		"echo piped | cat && true",
+		"set -x; true; set +x",
+	}
+
+	var tracked []string
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		tracked, _ = readLogLines(trackLog)
+		if len(tracked) >= len(expected) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	tracked, _ = readLogLines(trackLog)
+
+	if len(tracked) != len(expected) {
+		t.Fatalf("Expected %d tracked commands, got %d: %q\nShell output:\n%s", len(expected), len(tracked), tracked, output)
+	}
+
+	trackedSet := make(map[string]bool)
+	for _, command := range tracked {
+		trackedSet[command] = true
+	}
+	for _, command := range expected {
+		if !trackedSet[command] {
+			t.Errorf("Expected command %q to be tracked, got %q", command, tracked)
+		}
+	}
+
+	userTraps, _ := readLogLines(userTrapLog)
+	if len(userTraps) == 0 {
+		t.Error("Expected the pre-existing DEBUG trap to keep running")
+	}
+}
+
+// TestShellBashBuiltinPreexecHistControl checks that commands kept out of
+// history by HISTCONTROL are tracked as themselves, not as the previous
+// history entry
+func TestShellBashBuiltinPreexecHistControl(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping shell integration tests in short mode")
+	}
+
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Fatalf("Required shell bash not found in PATH - please install bash to run shell integration tests")
+	}
+
+	testDir := t.TempDir()
+	trackLog := filepath.Join(testDir, "track.log")
+
+	fakeBinary := filepath.Join(testDir, "fake-terminal-wakatime")
+	fakeScript := fmt.Sprintf("#!/bin/sh\nprintf '%%s\\n' \"$3\" >> %q\n", trackLog)
+	if err := os.WriteFile(fakeBinary, []byte(fakeScript), 0755); err != nil {
+		t.Fatalf("Failed to create fake binary: %v", err)
+	}
+
+	rcFile := filepath.Join(testDir, "rc.bash")
+	hooks := shell.NewIntegrationForShellWithConfig(fakeBinary, "bash", 0).GenerateHooks()
+	if err := os.WriteFile(rcFile, []byte(hooks+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write rc file: %v", err)
+	}
+
+	input := strings.Join([]string{
+		"echo first",
+		"echo first",   // a duplicate, not added to history
+		" echo hidden", // starts with a space, not added to history
+		"true",
+		"exit",
+	}, "\n") + "\n"
+
+	cmd := exec.Command("bash", "--noprofile", "--rcfile", rcFile, "-i")
+	cmd.Dir = testDir
+	cmd.Env = append(os.Environ(), "HOME="+testDir, "HISTFILE=/dev/null", "HISTCONTROL=ignoreboth")
+	cmd.Stdin = strings.NewReader(input)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Interactive bash failed: %v\nOutput: %s", err, output)
+	}
+
+	expected := []string{"echo first", "echo first", "echo hidden", "true"}
+
+	var tracked []string
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		tracked, _ = readLogLines(trackLog)
+		if len(tracked) >= len(expected) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	tracked, _ = readLogLines(trackLog)
+
+	// Tracking runs in the background, so the order isn't guaranteed
+	counts := map[string]int{}
+	for _, command := range tracked {
+		counts[command]++
+	}
+	if len(tracked) != len(expected) || counts["echo first"] != 2 || counts["echo hidden"] != 1 || counts["true"] != 1 {
+		t.Errorf("Expected %q to be tracked, got %q\nShell output:\n%s", expected, tracked, output)
+	}
+}
+
 // readLogLines reads log file and returns lines, handling missing files gracefully
 func readLogLines(path string) ([]string, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {